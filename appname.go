@@ -0,0 +1,39 @@
+package pq
+
+import "context"
+
+// SetApplicationName changes the application_name of the session, which
+// pg_stat_activity and the server log show, e.g. to label the unit of work
+// a job runner is executing. The cached server parameters, as in
+// ConnInfo.ParameterStatus, are updated too. The name is kept when the
+// connection goes back to a pool; a SET within a transaction that is rolled
+// back is undone by the server.
+func (c *Conn) SetApplicationName(ctx context.Context, name string) error {
+	cn := c.cn
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if cn.inCopy {
+		return errCopyInProgress
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+	cn.serverParamsMu.Lock()
+	old, ok := cn.serverParams["application_name"]
+	cn.serverParamsMu.Unlock()
+	if _, _, err := cn.simpleExec("SET application_name = " + QuoteLiteralConn(cn, name)); err != nil {
+		return contextErr(ctx, err)
+	}
+	cn.serverParamsMu.Lock()
+	defer cn.serverParamsMu.Unlock()
+	// the ParameterStatus the server reports, if any, has the name as stored,
+	// e.g. truncated to 63 bytes
+	if v, reported := cn.serverParams["application_name"]; !reported || (ok && v == old) {
+		if cn.serverParams == nil {
+			cn.serverParams = make(map[string]string)
+		}
+		cn.serverParams["application_name"] = name
+	}
+	return nil
+}
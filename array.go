@@ -9,16 +9,23 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var typeByteSlice = reflect.TypeOf([]byte{})
 var typeDriverValuer = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
 var typeSQLScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+var typeTime = reflect.TypeOf(time.Time{})
 
 // Array returns the optimal driver.Valuer and sql.Scanner for an array or
 // slice of any dimension.
 // Scanning multi-dimensional arrays is not supported.  Arrays where the lower
 // bound is not one (such as `[0:0]={1}') are not supported.
+//
+// To handle NULL elements, use a slice of pointers, such as []*int64, or of
+// sql.Scanner values, such as []sql.NullString: NULL is scanned into a nil
+// pointer or an invalid value, and nil pointers and invalid values are sent
+// as NULL.
 func Array(a interface{}) interface {
 	driver.Valuer
 	sql.Scanner
@@ -268,10 +275,10 @@ func (a Float64Array) Value() (driver.Value, error) {
 		b := make([]byte, 1, 1+2*n)
 		b[0] = '{'
 
-		b = strconv.AppendFloat(b, a[0], 'f', -1, 64)
+		b = appendFloat(b, a[0], 'f', 64)
 		for i := 1; i < n; i++ {
 			b = append(b, ',')
-			b = strconv.AppendFloat(b, a[i], 'f', -1, 64)
+			b = appendFloat(b, a[i], 'f', 64)
 		}
 
 		return string(append(b, '}')), nil
@@ -334,10 +341,10 @@ func (a Float32Array) Value() (driver.Value, error) {
 		b := make([]byte, 1, 1+2*n)
 		b[0] = '{'
 
-		b = strconv.AppendFloat(b, float64(a[0]), 'f', -1, 32)
+		b = appendFloat(b, float64(a[0]), 'f', 32)
 		for i := 1; i < n; i++ {
 			b = append(b, ',')
-			b = strconv.AppendFloat(b, float64(a[i]), 'f', -1, 32)
+			b = appendFloat(b, float64(a[i]), 'f', 32)
 		}
 
 		return string(append(b, '}')), nil
@@ -347,36 +354,16 @@ func (a Float32Array) Value() (driver.Value, error) {
 }
 
 // GenericArray implements the driver.Valuer and sql.Scanner interfaces for
-// an array or slice of any dimension.
+// an array or slice of any dimension. It scans into elements that implement
+// sql.Scanner, are of a basic type such as string, int or bool, are
+// time.Time values, or are pointers to any of these.
 type GenericArray struct{ A interface{} }
 
 func (GenericArray) evaluateDestination(rt reflect.Type) (reflect.Type, func([]byte, reflect.Value) error, string) {
-	var assign func([]byte, reflect.Value) error
 	var del = ","
 
-	// TODO calculate the assign function for other types
 	// TODO repeat this section on the element type of arrays or slices (multidimensional)
-	{
-		if reflect.PtrTo(rt).Implements(typeSQLScanner) {
-			// dest is always addressable because it is an element of a slice.
-			assign = func(src []byte, dest reflect.Value) (err error) {
-				ss := dest.Addr().Interface().(sql.Scanner)
-				if len(src) == 0 {
-					err = ss.Scan(nil)
-				} else {
-					err = ss.Scan(src)
-				}
-				return
-			}
-			goto FoundType
-		}
-
-		assign = func([]byte, reflect.Value) error {
-			return fmt.Errorf("pq: scanning to %s is not implemented; only sql.Scanner", rt)
-		}
-	}
-
-FoundType:
+	assign := arrayElementAssigner(rt)
 
 	if ad, ok := reflect.Zero(rt).Interface().(ArrayDelimiter); ok {
 		del = ad.ArrayDelimiter()
@@ -385,6 +372,87 @@ FoundType:
 	return rt, assign, del
 }
 
+// arrayElementAssigner returns the function which sets an element of type
+// rt, which is always addressable, to an array element in text format, nil
+// being NULL. NULL can only be scanned into a sql.Scanner or a pointer.
+func arrayElementAssigner(rt reflect.Type) func([]byte, reflect.Value) error {
+	if reflect.PtrTo(rt).Implements(typeSQLScanner) {
+		return func(src []byte, dest reflect.Value) error {
+			ss := dest.Addr().Interface().(sql.Scanner)
+			if src == nil {
+				return ss.Scan(nil)
+			}
+			return ss.Scan(src)
+		}
+	}
+	if rt.Kind() == reflect.Ptr {
+		assign := arrayElementAssigner(rt.Elem())
+		return func(src []byte, dest reflect.Value) error {
+			if src == nil {
+				dest.Set(reflect.Zero(rt))
+				return nil
+			}
+			v := reflect.New(rt.Elem())
+			if err := assign(src, v.Elem()); err != nil {
+				return err
+			}
+			dest.Set(v)
+			return nil
+		}
+	}
+
+	var parse func(s string, dest reflect.Value) error
+	switch {
+	case rt == typeTime:
+		parse = func(s string, dest reflect.Value) error {
+			t, err := ParseTimestamp(nil, s)
+			if err == nil {
+				dest.Set(reflect.ValueOf(t))
+			}
+			return err
+		}
+	case rt.Kind() == reflect.String:
+		parse = func(s string, dest reflect.Value) error {
+			dest.SetString(s)
+			return nil
+		}
+	case rt.Kind() == reflect.Bool:
+		parse = func(s string, dest reflect.Value) error {
+			b, err := strconv.ParseBool(s)
+			dest.SetBool(b)
+			return err
+		}
+	case rt.Kind() >= reflect.Int && rt.Kind() <= reflect.Int64:
+		parse = func(s string, dest reflect.Value) error {
+			n, err := strconv.ParseInt(s, 10, rt.Bits())
+			dest.SetInt(n)
+			return err
+		}
+	case rt.Kind() >= reflect.Uint && rt.Kind() <= reflect.Uint64:
+		parse = func(s string, dest reflect.Value) error {
+			n, err := strconv.ParseUint(s, 10, rt.Bits())
+			dest.SetUint(n)
+			return err
+		}
+	case rt.Kind() == reflect.Float32 || rt.Kind() == reflect.Float64:
+		parse = func(s string, dest reflect.Value) error {
+			f, err := strconv.ParseFloat(s, rt.Bits())
+			dest.SetFloat(f)
+			return err
+		}
+	default:
+		return func([]byte, reflect.Value) error {
+			return fmt.Errorf("pq: scanning to %s is not implemented; only sql.Scanner, pointers and basic types", rt)
+		}
+	}
+	return func(src []byte, dest reflect.Value) error {
+		if src == nil {
+			return fmt.Errorf("pq: cannot scan NULL into %s; use a pointer or a sql.Scanner such as sql.NullString", rt)
+		}
+		return parse(string(src), dest)
+	}
+}
+
 // Scan implements the sql.Scanner interface.
 func (a GenericArray) Scan(src interface{}) error {
 	dpv := reflect.ValueOf(a.A)
@@ -909,3 +977,39 @@ func scanLinearArray(src, del []byte, typ string) (elems [][]byte, err error) {
 	}
 	return elems, err
 }
+
+// sliceArray returns the array literal for the slice types accepted as query
+// arguments without pq.Array, see conn.CheckNamedValue.
+func sliceArray(v interface{}) (driver.Value, bool, error) {
+	var valuer driver.Valuer
+	switch v := v.(type) {
+	case []bool, []float64, []float32, []int64, []int32, []string:
+		valuer = Array(v)
+	case []int:
+		if v == nil {
+			return nil, true, nil
+		}
+		a := make(Int64Array, len(v))
+		for i, n := range v {
+			a[i] = int64(n)
+		}
+		valuer = a
+	case []time.Time:
+		if v == nil {
+			return nil, true, nil
+		}
+		b := make([]byte, 1, 1+len(v)*32)
+		b[0] = '{'
+		for i, t := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendArrayQuotedBytes(b, formatTs(t))
+		}
+		return string(append(b, '}')), true, nil
+	default:
+		return nil, false, nil
+	}
+	dv, err := valuer.Value()
+	return dv, true, err
+}
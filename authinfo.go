@@ -0,0 +1,83 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+)
+
+// Authentication methods reported in AuthInfo.Method.
+const (
+	AuthMethodTrust      = "trust"     // no authentication was requested
+	AuthMethodPassword   = "password"  // cleartext password
+	AuthMethodMD5        = "md5"       // md5 of the password
+	AuthMethodSHA256     = "sha256"    // the RFC 5802 based sha256 method of openGauss
+	AuthMethodMD5SHA256  = "md5sha256" // md5 of a sha256 verifier
+	AuthMethodSASLPrefix = "SASL "     // followed by the name of the mechanism
+)
+
+// Default bounds of the iteration count of sha256 and SCRAM authentication.
+const (
+	DefaultMinAuthIterations = 1000
+	DefaultMaxAuthIterations = 10000000
+)
+
+// AuthInfo describes how a connection authenticated, e.g. for audit logs.
+type AuthInfo struct {
+	// Method is one of the AuthMethod constants, or AuthMethodSASLPrefix
+	// followed by the name of the SASL mechanism, e.g.
+	// "SASL SCRAM-SHA-256".
+	Method string
+	// Iterations is the iteration count the server asked the password to
+	// be hashed with, for sha256 and SCRAM authentication, and 0 otherwise.
+	Iterations int
+}
+
+// GetAuthInfo returns how the given connection authenticated. A runtime
+// panic occurs if c is not a pq connection.
+func GetAuthInfo(c driver.Conn) AuthInfo {
+	return c.(*conn).authInfo
+}
+
+// AuthInfo returns how the connection authenticated, see the package
+// function GetAuthInfo.
+func (c *Conn) AuthInfo() AuthInfo {
+	return c.cn.authInfo
+}
+
+// authIterationBounds returns the range of iteration counts accepted from
+// the server.
+func (c *Config) authIterationBounds() (min, max int) {
+	min, max = c.MinAuthIterations, c.MaxAuthIterations
+	if min <= 0 {
+		min = DefaultMinAuthIterations
+	}
+	if max <= 0 {
+		max = DefaultMaxAuthIterations
+	}
+	return min, max
+}
+
+// checkSHA256Request checks the salt, token and iteration count of a sha256
+// authentication request, so that a server cannot make the client hash the
+// password for a long time, or with next to no iterations.
+func checkSHA256Request(salt, token string, iterations, min, max int) error {
+	if len(salt) != 64 || !isHex(salt) {
+		return fmt.Errorf("server sent an invalid sha256 salt: %q", salt)
+	}
+	if len(token) != 8 || !isHex(token) {
+		return fmt.Errorf("server sent an invalid sha256 token: %q", token)
+	}
+	if iterations < min {
+		return fmt.Errorf("server sent a sha256 iteration count of %d, below the minimum of %d", iterations, min)
+	}
+	if iterations > max {
+		return fmt.Errorf("server sent a sha256 iteration count of %d, above the maximum of %d", iterations, max)
+	}
+	return nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,39 @@
+package pq
+
+import "context"
+
+// AuthTokenProvider supplies ephemeral authentication tokens, such as the IAM
+// tokens accepted as passwords by managed openGauss and GaussDB services.
+// AuthToken is called on every connection attempt for which the server
+// requests cleartext or sha256 password authentication; md5 authentication
+// still uses the password.
+//
+// Tokens sent with cleartext authentication are only protected by TLS, so
+// providers should be used with sslmode=verify-full.
+type AuthTokenProvider interface {
+	AuthToken(ctx context.Context, req AuthTokenRequest) (string, error)
+}
+
+// AuthTokenRequest describes the connection a token is requested for.
+type AuthTokenRequest struct {
+	Host     string
+	Port     uint16
+	User     string
+	Database string
+}
+
+// AuthTokenFunc adapts a function to the AuthTokenProvider interface.
+type AuthTokenFunc func(ctx context.Context, req AuthTokenRequest) (string, error)
+
+// AuthToken calls f(ctx, req).
+func (f AuthTokenFunc) AuthToken(ctx context.Context, req AuthTokenRequest) (string, error) {
+	return f(ctx, req)
+}
+
+func (cn *conn) authTokenRequest() AuthTokenRequest {
+	req := AuthTokenRequest{User: cn.config.User, Database: cn.config.Database}
+	if cn.fallbackConfig != nil {
+		req.Host, req.Port = cn.fallbackConfig.Host, cn.fallbackConfig.Port
+	}
+	return req
+}
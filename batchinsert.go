@@ -0,0 +1,288 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// batchInsertMaxRows bounds the rows of a rewritten INSERT statement, so that
+// large batches do not produce statements the server takes long to parse.
+const batchInsertMaxRows = 1000
+
+// splitBatchInsert splits q, a single INSERT statement with one VALUES row,
+// into the text before the row, the row and the text after it, e.g. an ON
+// CONFLICT clause. It reports false for other statements.
+func splitBatchInsert(q string, backslashEscapes bool) (head, row, tail string, ok bool) {
+	const (
+		expectInsert = iota
+		expectValues
+		expectRow
+		inRow
+		afterRow
+		ended
+	)
+	state := expectInsert
+	depth := 0
+	rowStart, rowEnd := 0, 0
+	pos := 0
+	for _, seg := range splitSQL(q, backslashEscapes) {
+		s := seg.text
+		if !seg.code {
+			pos += len(s)
+			if isComment(s) || state == expectValues || state == inRow || state == afterRow {
+				continue
+			}
+			return "", "", "", false
+		}
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if state != inRow && isSpace(c) {
+				continue
+			}
+			switch state {
+			case expectInsert:
+				if !hasKeywordAt(s, i, "INSERT") {
+					return "", "", "", false
+				}
+				i += len("INSERT") - 1
+				state = expectValues
+			case expectValues:
+				switch {
+				case c == '(':
+					depth++
+				case c == ')':
+					depth--
+				case c == ';':
+					return "", "", "", false
+				case depth == 0 && hasKeywordAt(s, i, "VALUES"):
+					i += len("VALUES") - 1
+					state = expectRow
+				}
+			case expectRow:
+				if c != '(' {
+					return "", "", "", false
+				}
+				rowStart = pos + i
+				depth = 1
+				state = inRow
+			case inRow:
+				switch c {
+				case '(':
+					depth++
+				case ')':
+					depth--
+					if depth == 0 {
+						rowEnd = pos + i + 1
+						state = afterRow
+					}
+				case ';':
+					return "", "", "", false
+				}
+			case afterRow:
+				switch {
+				case c == ';':
+					state = ended
+				case c == ',' && strings.TrimSpace(q[rowEnd:pos+i]) == "":
+					// a second row
+					return "", "", "", false
+				}
+			case ended:
+				return "", "", "", false
+			}
+		}
+		pos += len(s)
+	}
+	if state != afterRow && state != ended {
+		return "", "", "", false
+	}
+	return q[:rowStart], q[rowStart:rowEnd], q[rowEnd:], true
+}
+
+// hasKeywordAt reports whether s has the keyword kw, in any case, as a word
+// of its own at i.
+func hasKeywordAt(s string, i int, kw string) bool {
+	return (i == 0 || !isIdentChar(s[i-1])) && len(s) >= i+len(kw) &&
+		strings.EqualFold(s[i:i+len(kw)], kw) && (len(s) == i+len(kw) || !isIdentChar(s[i+len(kw)]))
+}
+
+// renumberPlaceholders returns row with the number of every $n parameter
+// increased by offset.
+func renumberPlaceholders(row string, offset int, backslashEscapes bool) string {
+	var sb strings.Builder
+	for _, seg := range splitSQL(row, backslashEscapes) {
+		s := seg.text
+		if !seg.code {
+			sb.WriteString(s)
+			continue
+		}
+		for i := 0; i < len(s); i++ {
+			if s[i] != '$' || (i > 0 && isIdentChar(s[i-1])) {
+				sb.WriteByte(s[i])
+				continue
+			}
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(s[i+1 : j])
+			if err != nil {
+				sb.WriteByte(s[i])
+				continue
+			}
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n + offset))
+			i = j - 1
+		}
+	}
+	return sb.String()
+}
+
+// execBatchedInsert runs a batch of v, a multiple of the parameters of st,
+// as INSERT statements with several VALUES rows if Config.ReWriteBatchedInserts
+// is set and st is an INSERT statement with a single row. It reports false
+// if the batch is to be run as is.
+func (st *stmt) execBatchedInsert(v []driver.Value) (driver.Result, bool, error) {
+	cn := st.cn
+	n := len(st.paramTypes)
+	if !cn.config.ReWriteBatchedInserts || cn.pgconn != nil || n == 0 ||
+		len(v) <= n || len(v)%n != 0 || st.colFmts != nil {
+		return nil, false, nil
+	}
+	head, row, tail, ok := splitBatchInsert(transferPlaceholder(st.sql), cn.backslashEscapes())
+	// every parameter must be in the row, which is repeated
+	if !ok || maxPlaceholder(row, cn.backslashEscapes()) != n ||
+		maxPlaceholder(head, cn.backslashEscapes()) != 0 || maxPlaceholder(tail, cn.backslashEscapes()) != 0 {
+		return nil, false, nil
+	}
+	// the same checks as st.exec makes for other batches
+	if err := st.reprepare(); err != nil {
+		return nil, true, fmt.Errorf("cannot prepare deallocated statement: %w", err)
+	}
+	if err := checkColTypes(st.paramTypes, v); err != nil {
+		return nil, true, fmt.Errorf("colType error : %w", err)
+	}
+	if err := checkPacketLength(v, st); err != nil {
+		return nil, true, err
+	}
+
+	// an error may be in any of the statements, so it is not located
+	cn.stmtQuery = ""
+
+	perStmt := MaxBindParameters / n
+	if perStmt > batchInsertMaxRows {
+		perStmt = batchInsertMaxRows
+	}
+	rows := make([]string, 0, perStmt)
+	var w *writeBuf
+	for start := 0; start < len(v); start += perStmt * n {
+		end := start + perStmt*n
+		if end > len(v) {
+			end = len(v)
+		}
+		args := v[start:end]
+
+		rows = rows[:0]
+		for k := 0; k < len(args)/n; k++ {
+			rows = append(rows, renumberPlaceholders(row, k*n, cn.backslashEscapes()))
+		}
+		q, err := cn.clientString(head + strings.Join(rows, ", ") + tail)
+		if err != nil {
+			return nil, true, err
+		}
+
+		if w == nil {
+			w = cn.writeBuf('P')
+		} else {
+			w.next('P')
+		}
+		w.byte(0) // unnamed statement
+		w.string(q)
+		w.int16(len(args))
+		for i := range args {
+			w.int32(int(st.paramTypes[i%n]))
+		}
+
+		w.next('B')
+		w.int16(0) // unnamed portal and statement
+		if cn.binaryParameters {
+			if err := cn.sendBinaryParameters("", w, args); err != nil {
+				return nil, true, fmt.Errorf("cannot send binary parameters: %w", err)
+			}
+		} else {
+			w.int16(0)
+			w.int16(len(args))
+			for i, x := range args {
+				if x == nil {
+					w.int32(-1)
+					continue
+				}
+				b, err := encode(&cn.parameterStatus, x, st.paramTypes[i%n])
+				if err != nil {
+					return nil, true, fmt.Errorf("cannot encode: %w", err)
+				}
+				w.int32(len(b))
+				w.bytes(b)
+			}
+		}
+		w.bytes(colFmtDataAllText)
+
+		w.next('E')
+		w.byte(0)
+		w.int32(0)
+	}
+	// a single Sync runs all statements in one implicit transaction, as
+	// the batch would have been
+	w.next('S')
+	if err := cn.send(w); err != nil {
+		return nil, true, fmt.Errorf("fail to send: %w", err)
+	}
+
+	var (
+		tags     []CommandTag
+		affected int64
+		err      error
+	)
+	for {
+		t, r, recvErr := cn.recv1()
+		if recvErr != nil {
+			cn.setBad()
+			return nil, true, fmt.Errorf("cannot recv from conn: %w", recvErr)
+		}
+		switch t {
+		case '1', '2':
+		case 'C':
+			// errors are returned once the server is ready for the next
+			// query, so the connection stays usable
+			s, serr := r.string()
+			if serr != nil {
+				if err == nil {
+					err = fmt.Errorf("cannot get string from read buf: %w", serr)
+				}
+				continue
+			}
+			res, _, cerr := cn.parseComplete(s)
+			if cerr != nil {
+				if err == nil {
+					err = fmt.Errorf("cannot parse complete: %w", cerr)
+				}
+				continue
+			}
+			n, _ := res.RowsAffected()
+			affected += n
+			tags = append(tags, CommandTag(s))
+		case 'E':
+			err = parseError(r, cn)
+		case 'Z':
+			cn.processReadyForQuery(r)
+			if err != nil {
+				return nil, true, err
+			}
+			return &Result{tags: tags, rows: driver.RowsAffected(affected)}, true, nil
+		default:
+			cn.setBad()
+			return nil, true, fmt.Errorf("unexpected batched INSERT response: %q", t)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"math/bits"
+	"sync"
 
 	"github.com/trymesoft/openGauss-connector-go-pq/oid"
 )
@@ -96,3 +98,84 @@ func (b *writeBuf) next(c byte) {
 	b.pos = len(b.buf) + 1
 	b.buf = append(b.buf, c, 0, 0, 0, 0)
 }
+
+// Messages that do not fit into the scratch buffer of a connection are read
+// into buffers shared between connections through pools, one per power of two
+// from 1KB up to maxPooledReadBuffer. Larger messages get a buffer of their
+// own, which is left to the garbage collector.
+const (
+	minPooledReadBufferShift = 10
+	maxPooledReadBufferShift = 20
+	maxPooledReadBuffer      = 1 << maxPooledReadBufferShift
+
+	// number of consecutive messages fitting into a quarter of a
+	// connection's message buffer after which the buffer is released
+	msgBufShrinkAfter = 256
+
+	defaultReadBufferSize = 8192
+)
+
+var readBufferPools [maxPooledReadBufferShift - minPooledReadBufferShift + 1]sync.Pool
+
+// readBufferClass returns the index of the smallest pool holding buffers of
+// at least n bytes.
+func readBufferClass(n int) int {
+	if n <= 1<<minPooledReadBufferShift {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minPooledReadBufferShift
+}
+
+// getReadBuffer returns a buffer of at least n bytes, n being at most
+// maxPooledReadBuffer.
+func getReadBuffer(n int) []byte {
+	class := readBufferClass(n)
+	if b, ok := readBufferPools[class].Get().(*[]byte); ok {
+		return *b
+	}
+	return make([]byte, 1<<(class+minPooledReadBufferShift))
+}
+
+// putReadBuffer returns a buffer obtained from getReadBuffer to its pool.
+func putReadBuffer(b []byte) {
+	if cap(b) == 0 || cap(b) > maxPooledReadBuffer {
+		return
+	}
+	b = b[:cap(b)]
+	readBufferPools[readBufferClass(cap(b))].Put(&b)
+}
+
+// messageBuffer returns a buffer for reading a message of n bytes, valid
+// until the next message is read. The connection keeps a pooled buffer sized
+// for the largest recent message, and releases it once the messages have
+// been much smaller for a while.
+func (cn *conn) messageBuffer(n int) []byte {
+	if cn.msgBuf != nil && n <= cap(cn.msgBuf)/4 {
+		if cn.msgBufIdle++; cn.msgBufIdle >= msgBufShrinkAfter {
+			putReadBuffer(cn.msgBuf)
+			cn.msgBuf = nil
+			cn.msgBufIdle = 0
+		}
+	} else {
+		cn.msgBufIdle = 0
+	}
+
+	if n <= len(cn.scratch) {
+		return cn.scratch[:n]
+	}
+	if n > cap(cn.msgBuf) {
+		if n > maxPooledReadBuffer {
+			return make([]byte, n)
+		}
+		putReadBuffer(cn.msgBuf)
+		cn.msgBuf = getReadBuffer(n)
+	}
+	return cn.msgBuf[:n]
+}
+
+func (cn *conn) readBufferSize() int {
+	if n := cn.config.MinReadBufferSize; n > 0 {
+		return n
+	}
+	return defaultReadBufferSize
+}
@@ -0,0 +1,97 @@
+package pq
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BackendPID returns the process ID of the server backend of the given
+// connection, which is the pid column of its row in pg_stat_activity. A
+// runtime panic occurs if c is not a pq connection.
+func BackendPID(c driver.Conn) int {
+	return c.(*conn).processID
+}
+
+// CancelKey holds what is needed to send a cancel request for a connection
+// from elsewhere, e.g. from another process: the address of its server, the
+// process ID of its backend and the secret key the server gave it. It can be
+// passed around as text with MarshalText and UnmarshalText.
+type CancelKey struct {
+	network, address string
+	processID        int
+	secretKey        int
+}
+
+// GetCancelKey returns the cancel key of the given connection. A runtime
+// panic occurs if c is not a pq connection.
+func GetCancelKey(ctx context.Context, c driver.Conn) (CancelKey, error) {
+	cn := c.(*conn)
+	network, address, err := cn.cancelAddr(ctx)
+	if err != nil {
+		return CancelKey{}, fmt.Errorf("cannot resolve cancel address: %w", err)
+	}
+	return CancelKey{network: network, address: address, processID: cn.processID, secretKey: cn.secretKey}, nil
+}
+
+// ProcessID returns the process ID of the backend the key cancels commands
+// of.
+func (k CancelKey) ProcessID() int {
+	return k.processID
+}
+
+// Cancel sends a cancel request for the connection of the key, as
+// CancelRequest does. The request is sent using the DialFunc and, if set,
+// the TLSConfig of cfg.
+func (k CancelKey) Cancel(ctx context.Context, cfg *Config) error {
+	if k.address == "" {
+		return fmt.Errorf("pq: invalid cancel key")
+	}
+	dial := cfg.DialFunc
+	if dial == nil {
+		dial = makeDefaultDialer().DialContext
+	}
+	var tlsConfig *tls.Config
+	if cfg.TLSConfig != nil {
+		tlsConfig = cfg.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+			tlsConfig.ServerName = cfg.Host
+		}
+	}
+	return sendCancelRequest(ctx, dial, k.network, k.address, tlsConfig, k.processID, k.secretKey, nil)
+}
+
+// MarshalText encodes the key as text. The secret key is part of it, so the
+// text must be kept as private as the connection.
+func (k CancelKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%d@%s/%s", k.processID, k.secretKey, k.network, k.address)), nil
+}
+
+// UnmarshalText decodes a key encoded by MarshalText.
+func (k *CancelKey) UnmarshalText(text []byte) error {
+	s := string(text)
+	ids, addr, ok := strings.Cut(s, "@")
+	if !ok {
+		return fmt.Errorf("pq: invalid cancel key %q", s)
+	}
+	pid, key, ok := strings.Cut(ids, ".")
+	if !ok {
+		return fmt.Errorf("pq: invalid cancel key %q", s)
+	}
+	network, address, ok := strings.Cut(addr, "/")
+	if !ok || network == "" || address == "" {
+		return fmt.Errorf("pq: invalid cancel key %q", s)
+	}
+	var err error
+	if k.processID, err = strconv.Atoi(pid); err != nil {
+		return fmt.Errorf("pq: invalid cancel key %q: %w", s, err)
+	}
+	if k.secretKey, err = strconv.Atoi(key); err != nil {
+		return fmt.Errorf("pq: invalid cancel key %q: %w", s, err)
+	}
+	k.network, k.address = network, address
+	return nil
+}
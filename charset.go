@@ -0,0 +1,89 @@
+package pq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// clientEncodings maps the server's names for the client encodings the
+// driver can transcode, after normalization by alnumLowerASCII, to their
+// implementation. UTF8 and SQL_ASCII need no conversion and are not listed.
+var clientEncodings = map[string]encoding.Encoding{
+	"gbk":     simplifiedchinese.GBK,
+	"gb18030": simplifiedchinese.GB18030,
+	"euccn":   simplifiedchinese.GBK, // GBK is a superset of EUC-CN
+	"big5":    traditionalchinese.Big5,
+	"eucjp":   japanese.EUCJP,
+	"sjis":    japanese.ShiftJIS,
+	"euckr":   korean.EUCKR,
+	"latin1":  charmap.ISO8859_1,
+	"latin2":  charmap.ISO8859_2,
+	"latin9":  charmap.ISO8859_15,
+	"win1250": charmap.Windows1250,
+	"win1251": charmap.Windows1251,
+	"win1252": charmap.Windows1252,
+	"koi8r":   charmap.KOI8R,
+}
+
+// setClientEncoding is called when the server reports the client_encoding
+// parameter. Text is transcoded between UTF-8 and that encoding from then
+// on.
+func (cn *conn) setClientEncoding(name string) {
+	if isUTF8(name) || strings.EqualFold(name, "SQL_ASCII") {
+		cn.parameterStatus.clientEncoding = nil
+		return
+	}
+	enc, ok := clientEncodings[strings.Map(alnumLowerASCII, name)]
+	if !ok {
+		cn.log(context.Background(), LogLevelWarn, "unsupported client_encoding, text is passed through unconverted",
+			map[string]interface{}{"client_encoding": name})
+	}
+	cn.parameterStatus.clientEncoding = enc
+}
+
+// toServer converts s from UTF-8 to the client encoding. Characters that
+// cannot be represented are an error, as the server would otherwise receive
+// them garbled.
+func (p *parameterStatus) toServer(s string) (string, error) {
+	if p.clientEncoding == nil {
+		return s, nil
+	}
+	return p.clientEncoding.NewEncoder().String(s)
+}
+
+// fromServer converts b from the client encoding to UTF-8. Invalid input is
+// replaced with the Unicode replacement character.
+func (p *parameterStatus) fromServer(b []byte) []byte {
+	if p.clientEncoding == nil {
+		return b
+	}
+	out, err := p.clientEncoding.NewDecoder().Bytes(b)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+func (p *parameterStatus) fromServerString(s string) string {
+	if p.clientEncoding == nil {
+		return s
+	}
+	return string(p.fromServer([]byte(s)))
+}
+
+// clientString converts a query string to the client encoding for sending.
+func (cn *conn) clientString(q string) (string, error) {
+	s, err := cn.parameterStatus.toServer(q)
+	if err != nil {
+		return "", fmt.Errorf("cannot convert to client_encoding: %w", err)
+	}
+	return s, nil
+}
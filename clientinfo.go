@@ -0,0 +1,41 @@
+package pq
+
+import (
+	"encoding/json"
+	"os/user"
+	"runtime"
+)
+
+// driverVersion is the version of the driver reported in connection_info.
+// Keep it in sync with version/version.go.
+const driverVersion = "3.0.0"
+
+// defaultConnectionInfo returns the Config.ConnectionInfo ParseConfig fills
+// in when connection_info is to be sent. With extra set, the name of the operating system user is included too, like
+// the JDBC driver does with connectionExtraInfo.
+func defaultConnectionInfo(extra bool) map[string]string {
+	info := map[string]string{
+		"driver_name":    "go",
+		"driver_version": driverVersion,
+		"platform":       runtime.GOOS + "/" + runtime.GOARCH + " " + runtime.Version(),
+	}
+	if extra {
+		if u, err := user.Current(); err == nil {
+			info["os_user"] = u.Username
+		}
+	}
+	return info
+}
+
+// connectionInfoParam returns the value of the connection_info startup
+// parameter, or false if it is not to be sent.
+func (c *Config) connectionInfoParam() (string, bool) {
+	if _, ok := c.RuntimeParams["connection_info"]; ok || len(c.ConnectionInfo) == 0 {
+		return "", false
+	}
+	b, err := json.Marshal(c.ConnectionInfo)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
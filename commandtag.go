@@ -0,0 +1,114 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// CommandTag is the tag the server reports for a completed statement, such
+// as "INSERT 0 5", "UPDATE 3" or "CREATE TABLE".
+type CommandTag string
+
+func (t CommandTag) String() string {
+	return string(t)
+}
+
+// Verb returns the command name, i.e. the tag without its trailing
+// numbers, e.g. "INSERT" or "CREATE TABLE".
+func (t CommandTag) Verb() string {
+	s := string(t)
+	for {
+		i := strings.LastIndexByte(s, ' ')
+		if i < 0 || !isDigits(s[i+1:]) {
+			return s
+		}
+		s = s[:i]
+	}
+}
+
+// RowsAffected returns the number of rows the statement processed, or 0 for
+// commands whose tag does not report one.
+func (t CommandTag) RowsAffected() int64 {
+	switch t.Verb() {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "FETCH", "MOVE", "COPY":
+	default:
+		return 0
+	}
+	s := string(t)
+	n, _ := strconv.ParseInt(s[strings.LastIndexByte(s, ' ')+1:], 10, 64)
+	return n
+}
+
+// OID returns the OID of the inserted row reported by an INSERT of a single
+// row into a table with OIDs, and 0 otherwise.
+func (t CommandTag) OID() oid.Oid {
+	f := strings.Fields(string(t))
+	if len(f) != 3 || f[0] != "INSERT" {
+		return 0
+	}
+	n, _ := strconv.ParseUint(f[1], 10, 32)
+	return oid.Oid(n)
+}
+
+// onCommandComplete calls Config.OnCommandComplete, if set, for tag.
+func (cn *conn) onCommandComplete(tag string) {
+	if f := cn.config.OnCommandComplete; f != nil {
+		f(cn.connInfo(), CommandTag(tag))
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Result is the driver.Result returned by Exec. It can be reached through
+// sql.Conn.Raw, e.g. to get the outcome of each statement of a query
+// containing several:
+//
+//	err := conn.Raw(func(dc interface{}) error {
+//		res, err := dc.(driver.ExecerContext).ExecContext(ctx, "UPDATE a ...; DELETE FROM b ...", nil)
+//		if err != nil {
+//			return err
+//		}
+//		tags = res.(*pq.Result).CommandTags()
+//		return nil
+//	})
+type Result struct {
+	tags []CommandTag
+	rows driver.RowsAffected
+
+	// the key of the first inserted row, see Config.LastInsertIDColumn
+	insertID *int64
+}
+
+// CommandTags returns the command tags of the executed statements in order.
+// Queries without parameters may contain several statements, which are all
+// reported.
+func (r *Result) CommandTags() []CommandTag {
+	return r.tags
+}
+
+// RowsAffected returns the number of rows affected by the last statement.
+func (r *Result) RowsAffected() (int64, error) {
+	return r.rows.RowsAffected()
+}
+
+// LastInsertId returns the value of Config.LastInsertIDColumn in the first
+// row inserted by an INSERT statement. It is not supported otherwise.
+func (r *Result) LastInsertId() (int64, error) {
+	if r.insertID != nil {
+		return *r.insertID, nil
+	}
+	return r.rows.LastInsertId()
+}
@@ -0,0 +1,103 @@
+package pq
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+)
+
+// parseCompression validates the compression setting.
+func parseCompression(s string) (string, error) {
+	switch s {
+	case "", "off":
+		return "", nil
+	case "zlib":
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported compression %q", s)
+	}
+}
+
+// processCompressionAck handles the CompressionAck message a server or proxy
+// offering compression sends right after the startup packet. It holds the
+// algorithm selected, 0 for zlib, or -1 if none. The messages following it
+// are compressed. The driver does not request compression with a
+// _pq_.compression startup parameter, as no openGauss server supports it.
+func (cn *conn) processCompressionAck(r *readBuf) error {
+	if cn.config.Compression == "" {
+		return fmt.Errorf("unexpected CompressionAck")
+	}
+	switch i := int8(r.byte()); i {
+	case -1:
+		cn.log(context.Background(), LogLevelInfo, "compression was not accepted by the server", nil)
+		return nil
+	case 0:
+	default:
+		return fmt.Errorf("server selected unknown compression algorithm %d", i)
+	}
+
+	// The buffered reader may already hold compressed bytes.
+	buffered, err := cn.buf.Peek(cn.buf.Buffered())
+	if err != nil {
+		return err
+	}
+	cc := &compressedConn{
+		Conn: cn.c,
+		src:  io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), cn.c),
+	}
+	cc.w = zlib.NewWriter(cn.c)
+	cn.c = cc
+	cn.buf = bufio.NewReaderSize(cc, cn.readBufferSize())
+	cn.compressed = true
+	return nil
+}
+
+// processNegotiateProtocolVersion handles the NegotiateProtocolVersion
+// message listing the protocol extension parameters the server does not
+// support, which it ignores.
+func (cn *conn) processNegotiateProtocolVersion(r *readBuf) {
+	r.int32() // newest minor protocol version supported
+	var unsupported []string
+	for n := r.int32(); n > 0; n-- {
+		s, err := r.string()
+		if err != nil {
+			break
+		}
+		unsupported = append(unsupported, s)
+	}
+	cn.log(context.Background(), LogLevelInfo, "protocol options not supported by the server",
+		map[string]interface{}{"options": unsupported})
+}
+
+// compressedConn compresses what is written to the connection and
+// decompresses what is read, with zlib streams flushed after every write.
+type compressedConn struct {
+	net.Conn
+	src io.Reader
+	r   io.ReadCloser
+	w   *zlib.Writer
+}
+
+func (c *compressedConn) Read(b []byte) (int, error) {
+	if c.r == nil {
+		// zlib.NewReader reads the stream header, so wait for the first read
+		r, err := zlib.NewReader(c.src)
+		if err != nil {
+			return 0, err
+		}
+		c.r = r
+	}
+	return c.r.Read(b)
+}
+
+func (c *compressedConn) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql/driver"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net"
@@ -25,20 +27,248 @@ import (
 type Config struct {
 	Host                   string // host (e.g. localhost) or absolute path to unix domain socket directory (e.g. /private/tmp)
 	Port                   uint16
+	HostAddr               string // IP address to connect to instead of resolving Host, see FallbackConfig.HostAddr
 	Database               string
 	User                   string
 	Password               string
 	TLSConfig              *tls.Config // nil disables TLS
 	EnableClientEncryption string      // client encryption
 	EnableAutoSendToken    bool        // Indicates whether to automatically send token when connection startup.
-	ConnectTimeout         time.Duration
-	DialFunc               DialFunc   // e.g. net.Dialer.DialContext
-	LookupFunc             LookupFunc // e.g. net.Resolver.LookupHost
+	// LocalKMSFilePath is the directory holding the keys of client master
+	// keys created with KEY_STORE = localkms. The client encryption library
+	// only reads it from the LOCALKMS_FILE_PATH environment variable, so the
+	// first connection setting it sets the variable for the whole process,
+	// and connections giving another directory fail rather than change it
+	// under the others.
+	LocalKMSFilePath string
+	ConnectTimeout   time.Duration
+	// CancelTimeout bounds sending a cancel request when the context of a
+	// statement is done, including dialing with DialFunc. If zero,
+	// ConnectTimeout is used, or 10 seconds if that is zero too.
+	CancelTimeout time.Duration
+	// ReadTimeout and WriteTimeout, if positive, bound every read from and
+	// write to the network connection, so that a hung server or a
+	// connection dropped by the network fails with a timeout even when no
+	// context deadline is set; the connection is then discarded.
+	// ReadTimeout must exceed the run time of the longest statement. It
+	// does not apply while a Listener or CopyBoth waits for messages.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// LoadBalanceHosts is the order in which the hosts of the connection
+	// string are tried for each connection. It defaults to HostOrderListed,
+	// and does not apply with autoBalance, which has its own policies.
+	LoadBalanceHosts HostOrder
+	// PasswordFunc, if set, is called for the password whenever the server
+	// requests password authentication, once for each host tried, and
+	// takes precedence over Password. It allows short-lived credentials
+	// from a secret manager to be used. ctx is the context of the
+	// connection attempt.
+	PasswordFunc func(ctx context.Context) (string, error)
+	// AuthTokenProvider, if set, supplies the secret sent when the server
+	// requests cleartext or sha256 password authentication, in place of the
+	// password. See AuthTokenProvider.
+	AuthTokenProvider AuthTokenProvider
+	// DisableSASLprep sends the password as is in SCRAM and sha256
+	// authentication. By default it is normalized with SASLprep (RFC 4013)
+	// first, as the server does when storing it, so that passwords with
+	// non-ASCII characters, e.g. typed with a different Unicode
+	// normalization or with non-breaking spaces, match. Set it for servers
+	// that store such passwords without normalizing them.
+	DisableSASLprep bool
+	// MinAuthIterations and MaxAuthIterations bound the iteration count the
+	// server may ask the password to be hashed with in sha256 and SCRAM
+	// authentication, which otherwise fails: too few iterations weaken the
+	// hash sent over the wire, and too many keep the client busy for a long
+	// time. 0 means DefaultMinAuthIterations and DefaultMaxAuthIterations.
+	// Lower MinAuthIterations for servers configured with fewer iterations.
+	// ParseConfig rejects a MinAuthIterations above MaxAuthIterations. The
+	// authentication method used is reported by GetAuthInfo.
+	MinAuthIterations int
+	MaxAuthIterations int
+	// MinReadBufferSize is the size of the buffered reader wrapping the
+	// network connection. Messages that do not fit into it are still read
+	// in full. 0 means the default of 8192 bytes.
+	MinReadBufferSize int
+	// MaxRowBytes and MaxResultBytes, if positive, limit the size of a
+	// single row and of a whole result set as sent by the server. When a
+	// limit is exceeded, Rows.Next returns ErrRowTooLarge or
+	// ErrResultTooLarge, the query is canceled and the rest of the result is
+	// discarded, leaving the connection usable. Rows over MaxRowBytes are
+	// never buffered.
+	MaxRowBytes    int
+	MaxResultBytes int64
+	// MaxMessageSize is the largest message accepted from the server. A
+	// larger declared length breaks the connection with ErrMessageTooLarge
+	// instead of being allocated. 0 means the default of 1 GiB.
+	MaxMessageSize int
+	// Compression, if set to "zlib", accepts compressing the traffic of the
+	// connection when the server or a proxy in front of it offers it with a
+	// CompressionAck message, which pays off for large results over slow
+	// links. Compression is not requested in the startup packet, and
+	// connections to servers not offering it stay uncompressed.
+	Compression string
+	// KrbSrvName is the Kerberos service name the service principal of the
+	// server is made of, KrbSrvName/host; the default is "postgres".
+	// KrbSpn, if set, is the full service principal name instead. GSSLib
+	// selects the GSS library, "gssapi" or, on Windows, "sspi". They are
+	// used by GSSAPI authentication, which is not supported yet.
+	KrbSrvName string
+	KrbSpn     string
+	GSSLib     string
+	// PreferSimpleProtocol makes queries with arguments use the simple query
+	// protocol, with the arguments interpolated into the query as escaped
+	// literals, instead of the extended protocol. It is needed behind
+	// poolers and proxies that do not support the extended protocol.
+	// Statements prepared explicitly still use the extended protocol.
+	PreferSimpleProtocol bool
+	// RejectMultipleStatements makes queries sent with the simple query
+	// protocol fail if they contain several statements, outside string
+	// literals, quoted identifiers and comments, as a defense against SQL
+	// injection in applications that never send several statements at once.
+	RejectMultipleStatements bool
+	// ReWriteBatchedInserts makes batches of an INSERT statement with a
+	// single VALUES row, i.e. Exec with a multiple of its parameters, run
+	// as INSERT statements with many rows, e.g. INSERT INTO t VALUES ($1,
+	// $2), ($3, $4), ..., sent together, which the server executes faster
+	// than the rows one by one. Statements are split to stay within
+	// MaxBindParameters; RowsAffected is the total of the statements.
+	//
+	// Only such batches are rewritten. Separate Exec calls, e.g. the insert
+	// loops of ORMs, still run one by one: merging them would delay their
+	// results and errors until a later statement, so an application would
+	// see an INSERT succeed that then fails. Such loops have to pass their
+	// rows to a single Exec to benefit.
+	ReWriteBatchedInserts bool
+	// PingQuery is the statement run by Ping, e.g. "SELECT 1", to check
+	// more than that the server answers. By default Ping only exchanges a
+	// Sync message with the server, which runs no statement.
+	PingQuery string
+	// Replication makes the connection enter walsender mode, in which it
+	// accepts replication commands: "true" for physical replication only,
+	// "database" to also allow logical replication and SQL. Walsender
+	// connections do not support the extended protocol, so queries with
+	// arguments are sent as with PreferSimpleProtocol.
+	Replication string
+	// TextAsBytes makes char, varchar and text columns scan as []byte
+	// rather than string. Together with sql.RawBytes, this allows large
+	// values to be used without copying them out of the connection's read
+	// buffer; as with bytea values, a sql.RawBytes is only valid until the
+	// next call to Next.
+	TextAsBytes bool
+	// NumericAsString makes numeric and decimal columns scan as string, or
+	// as []byte with TextAsBytes, rather than as the []byte of any other
+	// type, and ColumnTypeScanType report it, so that applications and
+	// libraries converting values by their scan type never go through
+	// float64 and lose precision.
+	NumericAsString bool
+	// ValidateJSON makes json.RawMessage parameters be checked with
+	// json.Valid before they are sent, so that malformed documents fail in
+	// the client with the index of the parameter rather than on the server.
+	ValidateJSON bool
+	// ScanLocation, if set, is the location of time.Time values returned for
+	// timestamp, timestamptz and date columns. timestamptz values are
+	// converted to it; timestamp and date values, which carry no time zone,
+	// are interpreted as wall clock times in it. By default timestamptz
+	// values use the session TimeZone and the others UTC.
+	ScanLocation *time.Location
+	// DBCompatibility is the compatibility mode of the database, as set by
+	// DBCOMPATIBILITY when it was created: A, B, C or PG. B is the MySQL
+	// compatible mode of the dolphin extension. If empty, the server's
+	// sql_compatibility parameter is used when the server reports it.
+	DBCompatibility string
+	// RewriteQuery, if set, is called with every query before it is prepared
+	// or executed through QueryContext, ExecContext or PrepareContext, and
+	// may return a modified query and arguments, e.g. to add a schema
+	// prefix, routing hint or comment. args is nil for PrepareContext. The
+	// returned query is then processed like any other, so it may use the
+	// configured placeholder format or named arguments.
+	RewriteQuery func(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error)
+	// PlaceholderFormat is the syntax of parameter placeholders in queries.
+	// It defaults to PlaceholderDollar.
+	PlaceholderFormat PlaceholderFormat
+	// TimestampRounding is how time.Time parameters and COPY values with
+	// nanoseconds below a microsecond are sent. It defaults to
+	// TimestampRound.
+	TimestampRounding TimestampRounding
+	// SQLCommentTags are added to every statement run through QueryContext,
+	// ExecContext or PrepareContext as a comment in the sqlcommenter format,
+	// e.g. /*application='billing'*/, together with the tags of the context
+	// set by WithSQLCommentTags, so that the server's logs of the statement
+	// can be related to the client.
+	SQLCommentTags map[string]string
+	// SQLComment is where the comment of SQLCommentTags is placed. It
+	// defaults to SQLCommentAppend.
+	SQLComment SQLCommentPosition
+	// LastInsertIDColumn, if set, is the key column whose value
+	// Result.LastInsertId returns, as in MySQL. INSERT statements run
+	// through ExecContext without a RETURNING clause get RETURNING and the
+	// column appended, and the value of the first row inserted is kept; the
+	// tables inserted into must all have the column. Other statements,
+	// including prepared ones and INSERT statements run as a batch, are not
+	// changed.
+	LastInsertIDColumn string
+	// SearchPath is the schema search path of new sessions, in the syntax of
+	// SET search_path, e.g. `tenant_a, public`. Use QuoteSearchPath to build
+	// it from schema names that need quoting.
+	SearchPath string
+	// ByteaOutput, if set, is the bytea_output of new sessions, "hex" or
+	// "escape". bytea values are decoded in either format, whatever the
+	// setting of the server.
+	ByteaOutput string
+	// DefaultTransactionIsolation, if set, is the
+	// default_transaction_isolation of new sessions: "read uncommitted",
+	// "read committed", "repeatable read" or "serializable". It applies to
+	// the transactions begun with sql.LevelDefault and to statements run
+	// outside of transactions.
+	DefaultTransactionIsolation string
+	// DefaultTransactionReadOnly, if set, is the
+	// default_transaction_read_only of new sessions, "on" or "off". With
+	// "on", transactions begun without TxOptions.ReadOnly are read only too,
+	// rather than explicitly READ WRITE; those that write must run SET
+	// TRANSACTION READ WRITE first.
+	DefaultTransactionReadOnly string
+	// ClientMinMessages, if set, is the client_min_messages of new
+	// sessions, e.g. "warning", which keeps the server from sending less
+	// severe notices at all.
+	ClientMinMessages string
+	// MinNoticeSeverity, if set, drops the notices less severe than it
+	// before they reach the notice handler: "DEBUG", "LOG", "INFO",
+	// "NOTICE" or "WARNING", from the least severe. Unlike
+	// ClientMinMessages, it applies whatever the session sets, e.g. to
+	// the notices of functions that raise their own level. Dropped notices
+	// are counted in Stats. Notices are ranked by their unlocalized
+	// severity, or by their severity from servers not sending that, in
+	// which case notices in languages other than English always pass.
+	MinNoticeSeverity string
+	// Session timeouts sent in the startup packet when positive, in whole
+	// milliseconds. RuntimeParams entries of the same name take precedence.
+	StatementTimeout                time.Duration
+	LockTimeout                     time.Duration
+	IdleInTransactionSessionTimeout time.Duration
+	// DeadlineStatementTimeout makes QueryContext and ExecContext, those of
+	// prepared statements included, set the session statement_timeout to the
+	// time remaining until the context deadline before executing, so the
+	// server stops the statement even if the cancel request cannot reach it.
+	// This costs two extra round trips per statement with a deadline; the
+	// setting is restored to its previous value afterwards, e.g. one set
+	// with SET statement_timeout.
+	DeadlineStatementTimeout bool
+	DialFunc                 DialFunc   // e.g. net.Dialer.DialContext
+	LookupFunc               LookupFunc // e.g. net.Resolver.LookupHost
 	// BuildFrontend  BuildFrontendFunc
 	RuntimeParams map[string]string // Run-time parameters to set on connection as session default values (e.g. search_path or application_name)
 	Fallbacks     []*FallbackConfig
 	crlList       *pkix.CertificateList
 
+	// ConnectionInfo identifies the client to the server, which shows it in
+	// the connection_info column of pg_stat_activity. It is sent as a JSON
+	// object in the connection_info parameter, unless it is empty or
+	// RuntimeParams sets connection_info. It is empty by default, so
+	// nothing is sent; with send_connection_info or connectionExtraInfo set,
+	// ParseConfig fills in driver_name, driver_version and platform, and
+	// os_user for connectionExtraInfo. Entries may be changed or added.
+	ConnectionInfo map[string]string
+
 	targetSessionAttrs uint8
 	// ValidateConnect is called during a connection attempt after a successful authentication with the PostgreSQL server.
 	// It can be used to validate that the server is acceptable. If this returns an error the connection is closed and the next
@@ -56,6 +286,8 @@ type Config struct {
 	// OnNotification NotificationHandler
 
 	createdByParseConfig bool // Used to enforce created by ParseConfig rule.
+	// the settings the config was parsed from, for ConnString
+	settings map[string]string
 
 	// If set, this connection should never use the binary format when
 	// receiving query results from prepared statements.  Only provided for
@@ -65,6 +297,69 @@ type Config struct {
 
 	Logger   Logger
 	LogLevel LogLevel
+
+	// Tracer, if set, is notified around connect, query, exec, prepare, copy
+	// and transaction control operations. See the otelpq package for an
+	// OpenTelemetry implementation.
+	Tracer Tracer
+
+	// Stats, if set, collects connection and query statistics for every
+	// connection created from this config.
+	Stats *Stats
+
+	// SlowQueryThreshold enables slow query reporting when positive. Queries
+	// and execs that take longer are passed to OnSlowQuery, or logged at
+	// LogLevelWarn if OnSlowQuery is nil.
+	SlowQueryThreshold time.Duration
+	OnSlowQuery        func(ctx context.Context, q SlowQuery)
+
+	// HeartbeatPeriod, if positive, makes connections idle in the
+	// database/sql pool check the server every period with a Sync message,
+	// waiting at most period for the answer. A connection failing the check
+	// is marked bad, so the pool discards it instead of handing it out, e.g.
+	// after a network partition.
+	HeartbeatPeriod time.Duration
+
+	// IdleKeepalive, if positive, makes connections idle in the database/sql
+	// pool or a Pool for longer than IdleKeepalive send a Sync message and
+	// wait for the answer, so that load balancers and firewalls dropping
+	// idle TCP sessions do not sever sessions holding temporary tables or
+	// advisory locks. The Sync is sent at most a quarter of IdleKeepalive
+	// after it is due, and a connection not answering it within
+	// HeartbeatPeriod, or IdleKeepalive if that is zero, is marked bad.
+	IdleKeepalive time.Duration
+
+	// Interceptors wrap query, exec and prepare calls on every connection.
+	// See Interceptor and Connector.Use.
+	Interceptors []Interceptor
+
+	// WireTrace, if set, receives a line for every protocol message sent or
+	// received, starting with the startup packet. See SetWireTrace.
+	WireTrace io.Writer
+
+	// Connection lifecycle callbacks. OnConnect is called once a connection
+	// has been established, OnClose when it is closed (reason is nil for a
+	// normal close), OnBad when it is marked bad and will be discarded by
+	// database/sql, and OnCancel after a cancel request has been sent for it.
+	// They are called synchronously and must not use the connection.
+	OnConnect func(ctx context.Context, info ConnInfo)
+	OnClose   func(info ConnInfo, reason error)
+	OnBad     func(info ConnInfo)
+	OnCancel  func(info ConnInfo)
+
+	// OnCommandComplete, if set, is called with the command tag of every
+	// statement completed on a connection, including each statement of a
+	// multi-statement query and COPY, e.g. to keep an audit trail or to
+	// check what a maintenance script did. It is called synchronously as
+	// results are read and must not use the connection.
+	OnCommandComplete func(info ConnInfo, tag CommandTag)
+
+	// ResolveCancelAddr, if set, returns the address cancel requests for a
+	// connection are sent to. By default they go to info.RemoteAddr, the
+	// address the session connected to, which in a distributed deployment
+	// is the CN running the query. Use it when cancel requests must take a
+	// different route, e.g. through a proxy.
+	ResolveCancelAddr func(ctx context.Context, info ConnInfo) (network, address string, err error)
 }
 
 // Copy returns a deep copy of the config that is safe to use and modify.
@@ -82,6 +377,15 @@ func (c *Config) Copy() *Config {
 			newConf.RuntimeParams[k] = v
 		}
 	}
+	if newConf.ConnectionInfo != nil {
+		newConf.ConnectionInfo = make(map[string]string, len(c.ConnectionInfo))
+		for k, v := range c.ConnectionInfo {
+			newConf.ConnectionInfo[k] = v
+		}
+	}
+	if newConf.Interceptors != nil {
+		newConf.Interceptors = append([]Interceptor(nil), c.Interceptors...)
+	}
 	if newConf.Fallbacks != nil {
 		newConf.Fallbacks = make([]*FallbackConfig, len(c.Fallbacks))
 		for i, fallback := range c.Fallbacks {
@@ -112,6 +416,24 @@ type FallbackConfig struct {
 	Host      string // host (e.g. localhost) or path to unix domain socket directory (e.g. /private/tmp)
 	Port      uint16
 	TLSConfig *tls.Config // nil disables TLS
+	// HostAddr, if set, is the IP address connected to instead of the
+	// addresses Host resolves to. Host is still used to verify the server
+	// certificate with sslmode=verify-full.
+	HostAddr string
+	// DialFunc, if set, is used instead of Config.DialFunc to connect to
+	// this host, e.g. through an SSH tunnel. Host is then passed to it
+	// as is rather than resolved with Config.LookupFunc first.
+	DialFunc DialFunc
+
+	// addresses of a host name resolving to both IPv4 and IPv6 addresses,
+	// to be raced by dialHappyEyeballs
+	addrs []string
+
+	// set by ParseConfig on the second config of a host with sslmode allow
+	// and prefer, which retries the first with the other TLS setting
+	sslFallback bool
+	// the sslFallback config retrying this one, see foldSSLFallbacks
+	sslRetry *FallbackConfig
 }
 
 // NetworkAddress converts a PostgreSQL host and port into network and address suitable for use with
@@ -230,6 +552,16 @@ type DistConfig struct {
 
 	isUsingEip bool
 	tlsCfgs    []*tls.Config
+
+	// interval at which the standbys of a primary/standby cluster are
+	// discovered, see refreshHosts; 0 disables discovery
+	refreshHostsInterval time.Duration
+
+	// number of times all CNs are tried again, cnRetryInterval apart at
+	// first, when none could be connected to because they are unavailable,
+	// e.g. restarting; see isCNUnavailable
+	cnRetries       int
+	cnRetryInterval time.Duration
 }
 
 const (
@@ -237,6 +569,86 @@ const (
 	maxRefreshCNsIntervalSec int = 60
 )
 
+// runtimeParamPrefix marks connection string parameters that are sent to
+// the server as run-time parameters, without the prefix, whatever their name.
+const runtimeParamPrefix = "param_"
+
+// notRuntimeParams are the settings interpreted by the driver rather than
+// sent to the server as run-time parameters.
+var notRuntimeParams = map[string]struct{}{
+	"host":                                struct{}{},
+	"hostaddr":                            struct{}{},
+	"krbsrvname":                          struct{}{},
+	"krbspn":                              struct{}{},
+	"gsslib":                              struct{}{},
+	"port":                                struct{}{},
+	"database":                            struct{}{},
+	"user":                                struct{}{},
+	"password":                            struct{}{},
+	"connect_timeout":                     struct{}{},
+	"cancel_timeout":                      struct{}{},
+	"autoBalance":                         struct{}{},
+	"recheckTime":                         struct{}{},
+	"usingEip":                            struct{}{},
+	"refreshHostsInterval":                struct{}{},
+	"loadBalanceHosts":                    struct{}{},
+	"cnRetries":                           struct{}{},
+	"cnRetryInterval":                     struct{}{},
+	"enable_ce":                           struct{}{},
+	"localkms_file_path":                  struct{}{},
+	"auto_sendtoken":                      struct{}{},
+	"sslmode":                             struct{}{},
+	"sslkey":                              struct{}{},
+	"sslpassword":                         struct{}{},
+	"sslcert":                             struct{}{},
+	"sslrootcert":                         struct{}{},
+	"sslcrl":                              struct{}{},
+	"target_session_attrs":                struct{}{},
+	"min_read_buffer_size":                struct{}{},
+	"disable_prepared_binary_result":      struct{}{},
+	"binary_parameters":                   struct{}{},
+	"loggerLevel":                         struct{}{},
+	"slow_query_threshold":                struct{}{},
+	"heartbeatPeriod":                     struct{}{},
+	"idle_keepalive":                      struct{}{},
+	"read_timeout":                        struct{}{},
+	"disable_saslprep":                    struct{}{},
+	"min_auth_iterations":                 struct{}{},
+	"max_auth_iterations":                 struct{}{},
+	"write_timeout":                       struct{}{},
+	"search_path":                         struct{}{},
+	"default_transaction_isolation":       struct{}{},
+	"default_transaction_read_only":       struct{}{},
+	"client_min_messages":                 struct{}{},
+	"notice_min_severity":                 struct{}{},
+	"bytea_output":                        struct{}{},
+	"scan_location":                       struct{}{},
+	"text_as_bytes":                       struct{}{},
+	"numeric_as_string":                   struct{}{},
+	"sql_comment":                         struct{}{},
+	"last_insert_id_column":               struct{}{},
+	"validate_json":                       struct{}{},
+	"prefer_simple_protocol":              struct{}{},
+	"allow_multiple_statements":           struct{}{},
+	"max_row_bytes":                       struct{}{},
+	"max_message_size":                    struct{}{},
+	"compression":                         struct{}{},
+	"connectionExtraInfo":                 struct{}{},
+	"send_connection_info":                struct{}{},
+	"replication":                         struct{}{},
+	"ping_query":                          struct{}{},
+	"fallback_application_name":           struct{}{},
+	"max_result_bytes":                    struct{}{},
+	"dbcompatibility":                     struct{}{},
+	"placeholder_format":                  struct{}{},
+	"timestamp_rounding":                  struct{}{},
+	"statement_timeout":                   struct{}{},
+	"deadline_statement_timeout":          struct{}{},
+	"reWriteBatchedInserts":               struct{}{},
+	"lock_timeout":                        struct{}{},
+	"idle_in_transaction_session_timeout": struct{}{},
+}
+
 func ParseConfig(connString string) (*Config, *DistConfig, error) {
 	distCfg := &DistConfig{
 		refreshCNsIntervalSec: 10,
@@ -265,12 +677,24 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 	}
 
 	settings := mergeSettings(defSettings, envSettings, connStringSettings)
+	// fallback_application_name replaces the default of application_name,
+	// not one set by the user
+	if v, ok := settings["fallback_application_name"]; ok {
+		_, inEnv := envSettings["application_name"]
+		_, inConnString := connStringSettings["application_name"]
+		if !inEnv && !inConnString {
+			settings["application_name"] = v
+		}
+	}
 	encodePassword(&settings)
 	config := &Config{
 		createdByParseConfig: true,
+		settings:             settings,
 		Database:             settings["database"],
 		User:                 settings["user"],
 		Password:             settings["password"],
+		SearchPath:           settings["search_path"],
+		ByteaOutput:          settings["bytea_output"],
 		RuntimeParams:        make(map[string]string),
 	}
 
@@ -307,36 +731,26 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 			"Tried to enable automatically send token, but enable_ce=3 is not configured")
 	}
 
-	notRuntimeParams := map[string]struct{}{
-		"host":                           struct{}{},
-		"port":                           struct{}{},
-		"database":                       struct{}{},
-		"user":                           struct{}{},
-		"password":                       struct{}{},
-		"connect_timeout":                struct{}{},
-		"autoBalance":                    struct{}{},
-		"recheckTime":                    struct{}{},
-		"usingEip":                       struct{}{},
-		"enable_ce":                      struct{}{},
-		"auto_sendtoken":                 struct{}{},
-		"sslmode":                        struct{}{},
-		"sslkey":                         struct{}{},
-		"sslpassword":                    struct{}{},
-		"sslcert":                        struct{}{},
-		"sslrootcert":                    struct{}{},
-		"sslcrl":                         struct{}{},
-		"target_session_attrs":           struct{}{},
-		"min_read_buffer_size":           struct{}{},
-		"disable_prepared_binary_result": struct{}{},
-		"binary_parameters":              struct{}{},
-		"loggerLevel":                    struct{}{},
+	config.LocalKMSFilePath = settings["localkms_file_path"]
+	if config.LocalKMSFilePath != "" && config.EnableClientEncryption == "" {
+		return nil, nil, errors.New("CLIENT ERROR: " +
+			"Tried to set localkms_file_path, but enable_ce is not configured")
 	}
 
 	for k, v := range settings {
 		if _, present := notRuntimeParams[k]; present {
 			continue
 		}
-		config.RuntimeParams[k] = v
+		if !strings.HasPrefix(k, runtimeParamPrefix) {
+			config.RuntimeParams[k] = v
+		}
+	}
+	// prefixed parameters win, so that run-time parameters sharing their
+	// name with a connection parameter can be set too
+	for k, v := range settings {
+		if name := strings.TrimPrefix(k, runtimeParamPrefix); name != k && name != "" {
+			config.RuntimeParams[name] = v
+		}
 	}
 	if loggerLevel, ok := settings["loggerLevel"]; ok {
 		var err error
@@ -354,6 +768,14 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 
 	hosts := strings.Split(settings["host"], ",")
 	ports := strings.Split(settings["port"], ",")
+	var hostaddrs []string
+	if v, ok := settings["hostaddr"]; ok && v != "" {
+		hostaddrs = strings.Split(v, ",")
+		if len(hostaddrs) != len(hosts) {
+			return nil, nil, &parseConfigError{connString: connString,
+				msg: fmt.Sprintf("could not match %d host names to %d hostaddr values", len(hosts), len(hostaddrs))}
+		}
+	}
 
 	for i, host := range hosts {
 		var portStr string
@@ -381,11 +803,24 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 			}
 		}
 		distCfg.tlsCfgs = tlsConfigs
-		for _, tlsConfig := range tlsConfigs {
+		var hostaddr string
+		if hostaddrs != nil {
+			hostaddr = hostaddrs[i]
+			if hostaddr != "" && net.ParseIP(hostaddr) == nil {
+				return nil, nil, &parseConfigError{connString: connString, msg: "invalid hostaddr: " + hostaddr}
+			}
+		}
+		for j, tlsConfig := range tlsConfigs {
+			if tlsConfig != nil && tlsConfig.ServerName != "" {
+				// verify-full checks the certificate against this host
+				tlsConfig.ServerName = host
+			}
 			fallbacks = append(fallbacks, &FallbackConfig{
-				Host:      host,
-				Port:      port,
-				TLSConfig: tlsConfig,
+				Host:        host,
+				Port:        port,
+				TLSConfig:   tlsConfig,
+				HostAddr:    hostaddr,
+				sslFallback: j > 0,
 			})
 		}
 	}
@@ -393,6 +828,7 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 	config.Host = fallbacks[0].Host
 	config.Port = fallbacks[0].Port
 	config.TLSConfig = fallbacks[0].TLSConfig
+	config.HostAddr = fallbacks[0].HostAddr
 	config.Fallbacks = fallbacks[1:]
 
 	tryParseSslCrl(settings, config)
@@ -412,6 +848,296 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 		return nil, nil, &parseConfigError{connString: connString, msg: "invalid binary_parameters", err: err}
 	}
 
+	config.DeadlineStatementTimeout, err = parseBoolSettings("deadline_statement_timeout", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid deadline_statement_timeout", err: err}
+	}
+	config.ReWriteBatchedInserts, err = parseBoolSettings("reWriteBatchedInserts", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid reWriteBatchedInserts", err: err}
+	}
+	config.DisableSASLprep, err = parseBoolSettings("disable_saslprep", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid disable_saslprep", err: err}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"statement_timeout", &config.StatementTimeout},
+		{"lock_timeout", &config.LockTimeout},
+		{"idle_in_transaction_session_timeout", &config.IdleInTransactionSessionTimeout},
+	} {
+		if v, ok := settings[t.name]; ok {
+			*t.dst, err = parseDurationSetting(v, time.Millisecond)
+			if err != nil {
+				return nil, nil, &parseConfigError{connString: connString, msg: "invalid " + t.name, err: err}
+			}
+		}
+	}
+
+	if v, ok := settings["options"]; ok {
+		// options is passed through to the server as is, but check it here
+		// so a malformed value is reported before connecting
+		if _, err = parseOptionsSetting(v); err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid options", err: err}
+		}
+	}
+
+	if v, ok := settings["dbcompatibility"]; ok {
+		switch v = strings.ToUpper(v); v {
+		case "A", "B", "C", "PG":
+			config.DBCompatibility = v
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid dbcompatibility: " + v}
+		}
+	}
+
+	if v, ok := settings["placeholder_format"]; ok {
+		switch f := PlaceholderFormat(v); f {
+		case PlaceholderDollar, PlaceholderQuestion:
+			config.PlaceholderFormat = f
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid placeholder_format: " + v}
+		}
+	}
+
+	if v, ok := settings["timestamp_rounding"]; ok {
+		switch r := TimestampRounding(v); r {
+		case TimestampRound, TimestampTruncate, TimestampError:
+			config.TimestampRounding = r
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid timestamp_rounding: " + v}
+		}
+	}
+
+	if v, ok := settings["sql_comment"]; ok {
+		switch p := SQLCommentPosition(v); p {
+		case SQLCommentAppend, SQLCommentPrepend, SQLCommentOff:
+			config.SQLComment = p
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid sql_comment: " + v}
+		}
+	}
+
+	config.LastInsertIDColumn = settings["last_insert_id_column"]
+
+	if v, ok := settings["min_read_buffer_size"]; ok {
+		config.MinReadBufferSize, err = strconv.Atoi(v)
+		if err != nil || config.MinReadBufferSize < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid min_read_buffer_size", err: err}
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *int
+	}{
+		{"min_auth_iterations", &config.MinAuthIterations},
+		{"max_auth_iterations", &config.MaxAuthIterations},
+	} {
+		if v, ok := settings[t.name]; ok {
+			*t.dst, err = strconv.Atoi(v)
+			if err != nil || *t.dst < 0 {
+				return nil, nil, &parseConfigError{connString: connString, msg: "invalid " + t.name, err: err}
+			}
+		}
+	}
+	if min, max := config.authIterationBounds(); min > max {
+		return nil, nil, &parseConfigError{connString: connString,
+			msg: fmt.Sprintf("min_auth_iterations %d exceeds max_auth_iterations %d", min, max)}
+	}
+
+	if v, ok := settings["max_row_bytes"]; ok {
+		config.MaxRowBytes, err = strconv.Atoi(v)
+		if err != nil || config.MaxRowBytes < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid max_row_bytes", err: err}
+		}
+	}
+
+	sendInfo, err := parseBoolSettings("send_connection_info", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid send_connection_info", err: err}
+	}
+	extraInfo, err := parseBoolSettings("connectionExtraInfo", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid connectionExtraInfo", err: err}
+	}
+	if sendInfo || extraInfo {
+		config.ConnectionInfo = defaultConnectionInfo(extraInfo)
+	}
+
+	if config.Compression, err = parseCompression(settings["compression"]); err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid compression", err: err}
+	}
+
+	if v, ok := settings["max_message_size"]; ok {
+		config.MaxMessageSize, err = strconv.Atoi(v)
+		if err != nil || config.MaxMessageSize < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid max_message_size", err: err}
+		}
+	}
+
+	if v, ok := settings["max_result_bytes"]; ok {
+		config.MaxResultBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || config.MaxResultBytes < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid max_result_bytes", err: err}
+		}
+	}
+
+	config.KrbSrvName = settings["krbsrvname"]
+	config.KrbSpn = settings["krbspn"]
+	switch v := settings["gsslib"]; v {
+	case "", "gssapi", "sspi":
+		config.GSSLib = v
+	default:
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid gsslib: " + v}
+	}
+
+	config.PreferSimpleProtocol, err = parseBoolSettings("prefer_simple_protocol", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid prefer_simple_protocol", err: err}
+	}
+
+	allowMultipleStatements, err := parseBoolSettings("allow_multiple_statements", settings, true)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid allow_multiple_statements", err: err}
+	}
+	config.RejectMultipleStatements = !allowMultipleStatements
+
+	if config.Replication, err = parseReplication(settings["replication"]); err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid replication", err: err}
+	}
+	if config.Replication != "" {
+		config.PreferSimpleProtocol = true
+	}
+
+	switch config.ByteaOutput {
+	case "", "hex", "escape":
+	default:
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid bytea_output: " + config.ByteaOutput}
+	}
+
+	if v, ok := settings["default_transaction_isolation"]; ok {
+		// also accept the names with underscores or dashes, which need no
+		// quoting in connection strings
+		v = strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(v))
+		switch v {
+		case "", "read uncommitted", "read committed", "repeatable read", "serializable":
+			config.DefaultTransactionIsolation = v
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid default_transaction_isolation: " + settings["default_transaction_isolation"]}
+		}
+	}
+	if settings["default_transaction_read_only"] != "" {
+		readOnly, err := parseBoolSettings("default_transaction_read_only", settings, false)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid default_transaction_read_only", err: err}
+		}
+		config.DefaultTransactionReadOnly = "off"
+		if readOnly {
+			config.DefaultTransactionReadOnly = "on"
+		}
+	}
+
+	if v, ok := settings["client_min_messages"]; ok {
+		switch v = strings.ToLower(v); v {
+		case "", "debug", "debug1", "debug2", "debug3", "debug4", "debug5",
+			"log", "info", "notice", "warning", "error", "fatal", "panic":
+			config.ClientMinMessages = v
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid client_min_messages: " + settings["client_min_messages"]}
+		}
+	}
+	if v := settings["notice_min_severity"]; v != "" {
+		s, ok := parseNoticeSeverity(v)
+		if !ok {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid notice_min_severity: " + v}
+		}
+		config.MinNoticeSeverity = s
+	}
+
+	config.PingQuery = settings["ping_query"]
+
+	config.TextAsBytes, err = parseBoolSettings("text_as_bytes", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid text_as_bytes", err: err}
+	}
+
+	config.NumericAsString, err = parseBoolSettings("numeric_as_string", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid numeric_as_string", err: err}
+	}
+
+	config.ValidateJSON, err = parseBoolSettings("validate_json", settings, false)
+	if err != nil {
+		return nil, nil, &parseConfigError{connString: connString, msg: "invalid validate_json", err: err}
+	}
+
+	if v, ok := settings["scan_location"]; ok {
+		config.ScanLocation, err = time.LoadLocation(v)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid scan_location", err: err}
+		}
+	}
+
+	if v, ok := settings["slow_query_threshold"]; ok {
+		config.SlowQueryThreshold, err = parseDurationSetting(v, time.Millisecond)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid slow_query_threshold", err: err}
+		}
+	}
+
+	if v, ok := settings["cancel_timeout"]; ok {
+		config.CancelTimeout, err = parseDurationSetting(v, time.Second)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid cancel_timeout", err: err}
+		}
+	}
+
+	if v, ok := settings["heartbeatPeriod"]; ok {
+		config.HeartbeatPeriod, err = parseDurationSetting(v, time.Millisecond)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid heartbeatPeriod", err: err}
+		}
+	}
+
+	if v, ok := settings["idle_keepalive"]; ok {
+		config.IdleKeepalive, err = parseDurationSetting(v, time.Second)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid idle_keepalive", err: err}
+		}
+	}
+
+	if v, ok := settings["loadBalanceHosts"]; ok {
+		switch o := HostOrder(v); o {
+		case HostOrderListed, HostOrderRandom, HostOrderRandomPreferPrimary:
+			config.LoadBalanceHosts = o
+		case "false", "disable":
+			config.LoadBalanceHosts = HostOrderListed
+		case "true":
+			config.LoadBalanceHosts = HostOrderRandom
+		default:
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid loadBalanceHosts: " + v}
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"read_timeout", &config.ReadTimeout},
+		{"write_timeout", &config.WriteTimeout},
+	} {
+		if v, ok := settings[t.name]; ok {
+			*t.dst, err = parseDurationSetting(v, time.Second)
+			if err != nil {
+				return nil, nil, &parseConfigError{connString: connString, msg: "invalid " + t.name, err: err}
+			}
+		}
+	}
+
 	if balPol, ok := settings["autoBalance"]; ok {
 		distCfg.balancePolicy, err = parseBalancePolicy(balPol)
 		if err != nil {
@@ -434,6 +1160,27 @@ func ParseConfig(connString string) (*Config, *DistConfig, error) {
 			return nil, nil, &parseConfigError{connString: connString, msg: "invalid recheckTime", err: err}
 		}
 	}
+	if v, ok := settings["refreshHostsInterval"]; ok {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid refreshHostsInterval", err: err}
+		}
+		distCfg.refreshHostsInterval = time.Duration(sec) * time.Second
+	}
+	if v, ok := settings["cnRetries"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid cnRetries", err: err}
+		}
+		distCfg.cnRetries = n
+	}
+	distCfg.cnRetryInterval = defaultCNRetryInterval
+	if v, ok := settings["cnRetryInterval"]; ok {
+		distCfg.cnRetryInterval, err = parseDurationSetting(v, time.Millisecond)
+		if err != nil {
+			return nil, nil, &parseConfigError{connString: connString, msg: "invalid cnRetryInterval", err: err}
+		}
+	}
 	distCfg.isUsingEip, err = parseBoolSettings("usingEip", settings, true)
 	if err != nil {
 		return nil, nil, &parseConfigError{connString: connString, msg: "invalid usingEip", err: err}
@@ -480,17 +1227,34 @@ func tryParseSslCrl(settings map[string]string, config *Config) {
 func parseBoolSettings(key string, settings map[string]string, defaultVal bool) (val bool, err error) {
 	val = defaultVal
 	if value, ok := settings[key]; ok {
-		if value == "yes" {
+		switch value {
+		case "yes", "true", "on", "1":
 			val = true
-		} else if value == "no" {
+		case "no", "false", "off", "0":
 			val = false
-		} else if value != "" {
+		case "":
+		default:
 			return val, fmt.Errorf("unrecognized value %q for %s", value, key)
 		}
 	}
 	return val, nil
 }
 
+// parseReplication parses the replication setting, returning "" if the
+// connection is not to enter walsender mode.
+func parseReplication(s string) (string, error) {
+	switch s {
+	case "", "false", "off", "no", "0":
+		return "", nil
+	case "true", "on", "yes", "1":
+		return "true", nil
+	case "database":
+		return s, nil
+	default:
+		return "", fmt.Errorf("unrecognized value %q for replication", s)
+	}
+}
+
 func parseCeSettings(key string, settings map[string]string, defaultVal string) (val string, err error) {
 	val = defaultVal
 	if value, ok := settings[key]; ok {
@@ -620,6 +1384,9 @@ func parseEnvSettings() map[string]string {
 		"PGSSLCRL":             "sslcrl",
 		"PGTARGETSESSIONATTRS": "target_session_attrs",
 		"PGLOGGERLEVEL":        "loggerLevel",
+		"PGOPTIONS":            "options",
+		"PGKRBSRVNAME":         "krbsrvname",
+		"PGGSSLIB":             "gsslib",
 	}
 
 	for envname, realname := range nameMap {
@@ -792,6 +1559,91 @@ func parseConnectTimeoutSetting(s string) (time.Duration, error) {
 	return time.Duration(timeout) * time.Second, nil
 }
 
+// QuoteSearchPath returns a value for Config.SearchPath or the search_path
+// connection parameter listing the given schemas in order. Each name is
+// quoted, so it is used exactly as given, including its case.
+func QuoteSearchPath(schemas ...string) string {
+	quoted := make([]string, len(schemas))
+	for i, schema := range schemas {
+		quoted[i] = QuoteIdentifier(schema)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// parseOptionsSetting parses the value of the options startup parameter, a
+// list of command-line style settings such as "-c search_path=app
+// --statement_timeout=5s". As in the server, a backslash escapes the next
+// character, so "-c x=a\ b" sets x to "a b".
+func parseOptionsSetting(s string) (map[string]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case asciiSpace[c] == 1:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	params := make(map[string]string)
+	for i := 0; i < len(words); i++ {
+		var setting string
+		switch w := words[i]; {
+		case w == "-c":
+			if i+1 == len(words) {
+				return nil, errors.New("missing setting after -c")
+			}
+			i++
+			setting = words[i]
+		case strings.HasPrefix(w, "-c"):
+			setting = w[2:]
+		case strings.HasPrefix(w, "--"):
+			setting = w[2:]
+		default:
+			return nil, fmt.Errorf("unsupported option %q", w)
+		}
+		eq := strings.IndexByte(setting, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("setting %q must have the form name=value", setting)
+		}
+		params[strings.ReplaceAll(setting[:eq], "-", "_")] = setting[eq+1:]
+	}
+	return params, nil
+}
+
+// parseDurationSetting parses s either as a Go duration string such as "1.5s"
+// or as a plain integer in units of unit.
+func parseDurationSetting(s string, unit time.Duration) (time.Duration, error) {
+	var d time.Duration
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d = time.Duration(n) * unit
+	} else {
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if d < 0 {
+		return 0, errors.New("negative duration")
+	}
+	return d, nil
+}
+
 func makeConnectTimeoutDialFunc(timeout time.Duration) DialFunc {
 	d := makeDefaultDialer()
 	d.Timeout = timeout
@@ -8,10 +8,12 @@ import (
 	"database/sql/driver"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
@@ -21,6 +23,7 @@ import (
 	"unsafe"
 
 	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+	"golang.org/x/text/encoding"
 )
 
 // Common error types
@@ -59,6 +62,31 @@ type parameterStatus struct {
 	// the current location based on the TimeZone value of the session, if
 	// available
 	currentLocation *time.Location
+
+	// the client_encoding of the session, or nil if text needs no conversion
+	clientEncoding encoding.Encoding
+
+	// Config.ScanLocation
+	scanLocation *time.Location
+
+	// Config.TextAsBytes
+	textAsBytes bool
+
+	// Config.NumericAsString
+	numericAsString bool
+
+	// Config.TimestampRounding
+	timestampRounding TimestampRounding
+
+	// decoded bytea values of the current row, which stay valid until the
+	// next row is read
+	rowBuf []byte
+
+	// A, B, C or PG, or empty if unknown; see Config.DBCompatibility
+	dbCompatibility string
+
+	// the names of the dolphin types by OID, see loadDolphinTypes
+	dolphinTypes map[oid.Oid]string
 }
 
 type transactionStatus byte
@@ -82,6 +110,32 @@ func (s transactionStatus) String() (string, error) {
 	}
 }
 
+// TxStatus is the transaction status of a connection as reported by the
+// server in its last ReadyForQuery message.
+type TxStatus byte
+
+const (
+	TxStatusIdle                TxStatus = TxStatus(txnStatusIdle)
+	TxStatusInTransaction       TxStatus = TxStatus(txnStatusIdleInTransaction)
+	TxStatusInFailedTransaction TxStatus = TxStatus(txnStatusInFailedTransaction)
+)
+
+func (s TxStatus) String() string {
+	str, err := transactionStatus(s).String()
+	if err != nil {
+		return fmt.Sprintf("unknown (%q)", byte(s))
+	}
+	return str
+}
+
+// TransactionStatus returns the transaction status of the given connection.
+// A runtime panic occurs if c is not a pq connection. It must not be called
+// while the connection is in use, e.g. use it from database/sql's Conn.Raw.
+// A connection returned to a pool should be in TxStatusIdle.
+func TransactionStatus(c driver.Conn) TxStatus {
+	return TxStatus(c.(*conn).txnStatus)
+}
+
 // connErr wraps driver.ErrBadConn for sql retry
 type connErr struct {
 	msg string
@@ -110,21 +164,46 @@ type conn struct {
 	scratch        [512]byte
 	txnStatus      transactionStatus
 	txnFinish      func()
+
+	// counts the transactions that have ended, so that state belonging to
+	// a transaction, e.g. a Cursor, can tell whether it is still current
+	txnGen uint64
+
+	// buffer for messages that do not fit into scratch, see messageBuffer
+	msgBuf     []byte
+	msgBufIdle int
+
 	// Save connection arguments to use during CancelRequest.
 	dialer Dialer
 
 	// Cancellation key data for use with CancelRequest messages.
 	processID int
 	secretKey int
+	// how the connection authenticated, see GetAuthInfo
+	authInfo AuthInfo
+	// the address the session is actually connected to, which is where
+	// cancel requests are sent
+	remoteAddr net.Addr
 
 	parameterStatus parameterStatus
 
+	// all run-time parameters reported by the server, see ConnInfo. The
+	// mutex is needed as callbacks may read them from the cancel goroutine.
+	serverParams   map[string]string
+	serverParamsMu sync.Mutex
+
+	// 1 between a successful connect and Close, see the lifecycle callbacks
+	// in Config
+	established int32
+
 	saveMessageType   byte
 	saveMessageBuffer []byte
 
 	// If true, this connection is bad and all public-facing functions should
 	// return ErrBadConn.
 	bad *atomic.Value
+	// the badCause that made the connection bad, see setBadErr
+	badErr atomic.Value
 
 	// If set, this connection should never use the binary format when
 	// receiving query results from prepared statements.  Only provided for
@@ -147,6 +226,72 @@ type conn struct {
 
 	// mutex to safe-guard pgconn_free()
 	pgconnMutex sync.RWMutex
+
+	// if set, every protocol message is written to it; see SetWireTrace
+	wireTrace *wireTracer
+
+	// command tag of the most recent CommandComplete, reported to the Tracer
+	lastCommandTag string
+
+	// context passed to BeginTx, used as the parent of commit/rollback spans
+	txnCtx context.Context
+
+	// names of the savepoints established in the current transaction
+	savepoints []string
+
+	// set by PrepareTransaction; the next Commit or Rollback only finishes
+	// the transaction on the database/sql side
+	txnPrepared bool
+	// set by PrepareTransaction when it ended the transaction without
+	// preparing it; returned by the next Commit
+	txnEndedErr error
+
+	// the query of the statement being run, to locate the Position of
+	// errors, see Error.QueryPosition
+	stmtQuery string
+
+	// set once the role or session authorization of the session was
+	// changed, see setRole; ResetSession resets them
+	roleSet bool
+
+	// checks the connection while idle, nil unless Config.HeartbeatPeriod
+	// is set
+	hb *heartbeat
+
+	// contexts watched for cancellation, see watchCancel
+	watchMu    sync.Mutex
+	watches    []*ctxWatch
+	ctxWatcher *ctxWatcher
+
+	// set by Hijack; the network connection is no longer the driver's
+	hijacked bool
+
+	// whether the server accepted Config.Compression
+	compressed bool
+
+	// whether the Query message sent next holds a password, which the wire
+	// trace must not show, see setRole
+	redactQuery bool
+
+	// whether the server asked for authentication during startup, after
+	// which a failed connection is not retried with the other TLS setting
+	authStarted bool
+
+	// applies Config.ReadTimeout and Config.WriteTimeout, nil unless
+	// either is set
+	timeouts *timeoutConn
+
+	// incremented by DeallocateAll; statements prepared under an earlier
+	// value are prepared again before they are executed
+	stmtGen uint64
+}
+
+// dialFunc returns the function used to connect to the host of cn.
+func (cn *conn) dialFunc() DialFunc {
+	if fc := cn.fallbackConfig; fc != nil && fc.DialFunc != nil {
+		return fc.DialFunc
+	}
+	return cn.config.DialFunc
 }
 
 func (cn *conn) LockReaderMutex() {
@@ -174,11 +319,23 @@ func (cn *conn) UnlockWriterMutex() {
 }
 
 func (cn *conn) ResetSession(ctx context.Context) error {
+	// database/sql checks the connection out here, without sending anything
+	// first: wait for a heartbeat check in progress
+	cn.setIdle(false)
+	if cn.getBad() {
+		return driver.ErrBadConn
+	}
 	cn.LockReaderMutex()
 	defer cn.UnlockReaderMutex()
 	if cn.pgconn != nil {
 		pgconn_reset(cn.pgconn)
 	}
+	if cn.roleSet {
+		if err := cn.resetRole(ctx); err != nil {
+			// do not hand out a session acting as another user
+			return badConn(err)
+		}
+	}
 	return nil
 }
 
@@ -201,15 +358,17 @@ func (cn *conn) log(ctx context.Context, lvl LogLevel, msg string, data map[stri
 }
 
 func (cn *conn) startTLS(tlsConfig *tls.Config) (err error) {
+	cn.traceUntyped("SSLRequest", 8, "")
 	if err = binary.Write(cn.c, binary.BigEndian, []int32{8, 80877103}); err != nil {
 		return fmt.Errorf("cannot write binary: %w", err)
 	}
 
 	response := make([]byte, 1)
 	if _, err = io.ReadFull(cn.c, response); err != nil {
+		cn.setBadErr(err)
 		return connErr{
-			msg: fmt.Sprintf("fail to read: %v", err),
-			err: driver.ErrBadConn, // for database/sql errors.Is and retry
+			msg: "fail to read",
+			err: badConn(err), // for database/sql errors.Is and retry
 		}
 	}
 
@@ -217,7 +376,18 @@ func (cn *conn) startTLS(tlsConfig *tls.Config) (err error) {
 		return ErrSSLNotSupported
 	}
 
-	cn.c = tls.Client(cn.c, tlsConfig)
+	tlsConn := tls.Client(cn.c, tlsConfig)
+	cn.c = tlsConn
+
+	// The handshake would otherwise happen implicitly on the first write;
+	// perform it here so that its duration can be measured.
+	start := time.Now()
+	if err = tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("tls handshake: %w", err)
+	}
+	if cn.config != nil {
+		cn.config.Stats.observeTLSHandshake(time.Since(start))
+	}
 
 	return nil
 }
@@ -238,9 +408,39 @@ func (cn *conn) isInTransaction() bool {
 }
 
 func (cn *conn) setBad() {
-	if cn.bad != nil {
-		cn.bad.Store(true)
+	if cn.bad != nil && cn.bad.CompareAndSwap(false, true) {
+		if f := cn.config.OnBad; f != nil && atomic.LoadInt32(&cn.established) == 1 {
+			f(cn.connInfo())
+		}
+	}
+}
+
+// badCause is the error that made a connection bad.
+type badCause struct {
+	err error
+}
+
+// setBadErr marks the connection bad because of err, which errBadConn
+// reports from then on. Only the first cause is kept.
+func (cn *conn) setBadErr(err error) {
+	if err != nil {
+		var bc *badConnError
+		if errors.As(err, &bc) {
+			err = bc.err
+		}
+		cn.badErr.CompareAndSwap(nil, badCause{err: err})
+	}
+	cn.setBad()
+}
+
+// errBadConn returns the error of the calls made on a bad connection: it
+// satisfies errors.Is(err, driver.ErrBadConn) and wraps the error that made
+// the connection bad, if known.
+func (cn *conn) errBadConn() error {
+	if c, ok := cn.badErr.Load().(badCause); ok {
+		return badConn(c.err)
 	}
+	return driver.ErrBadConn
 }
 
 func (cn *conn) getBad() bool {
@@ -266,7 +466,7 @@ func (cn *conn) Begin() (_ driver.Tx, err error) {
 
 func (cn *conn) begin(mode string) (_ driver.Tx, err error) {
 	if cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, cn.errBadConn()
 	}
 
 	if err = cn.checkIsInTransaction(false); err != nil {
@@ -284,10 +484,15 @@ func (cn *conn) begin(mode string) (_ driver.Tx, err error) {
 		cn.setBad()
 		return nil, fmt.Errorf("unexpected transaction status %v", cn.txnStatus)
 	}
+	cn.savepoints = nil
 	return cn, nil
 }
 
 func (cn *conn) closeTxn() {
+	cn.txnCtx = nil
+	cn.savepoints = nil
+	cn.txnPrepared = false
+	cn.txnEndedErr = nil
 	if finish := cn.txnFinish; finish != nil {
 		finish()
 	}
@@ -297,8 +502,16 @@ func (cn *conn) Commit() (err error) {
 	cn.LockReaderMutex()
 	defer cn.UnlockReaderMutex()
 	defer cn.closeTxn()
+	span := cn.traceStart(cn.txnCtx, TraceOpCommit, "", 0)
+	defer func() { span.end("", err) }()
 	if cn.getBad() {
-		return driver.ErrBadConn
+		return cn.errBadConn()
+	}
+	if cn.txnPrepared {
+		return nil
+	}
+	if cn.txnEndedErr != nil {
+		return cn.txnEndedErr
 	}
 
 	if err = cn.checkIsInTransaction(true); err != nil {
@@ -335,8 +548,13 @@ func (cn *conn) Rollback() (err error) {
 	cn.LockReaderMutex()
 	defer cn.UnlockReaderMutex()
 	defer cn.closeTxn()
+	span := cn.traceStart(cn.txnCtx, TraceOpRollback, "", 0)
+	defer func() { span.end("", err) }()
 	if cn.getBad() {
-		return driver.ErrBadConn
+		return cn.errBadConn()
+	}
+	if cn.txnPrepared || cn.txnEndedErr != nil {
+		return nil
 	}
 	return cn.rollback()
 }
@@ -388,6 +606,12 @@ func (cn *conn) simpleExec(q string) (driver.Result, string, error) {
 		}
 	}
 
+	cn.stmtQuery = q
+	q, err := cn.clientString(q)
+	if err != nil {
+		return nil, "", err
+	}
+
 	if cn.pgconn != nil {
 		runtime.LockOSThread()
 		var queryCstring *Cchar
@@ -422,6 +646,7 @@ func (cn *conn) simpleExec(q string) (driver.Result, string, error) {
 	var (
 		res    driver.Result
 		cmdTag string
+		tags   []CommandTag
 	)
 
 	for {
@@ -440,6 +665,8 @@ func (cn *conn) simpleExec(q string) (driver.Result, string, error) {
 			if err != nil {
 				return nil, "", fmt.Errorf("cannot parse complete: %w", err)
 			}
+			tags = append(tags, CommandTag(s))
+			res = &Result{tags: tags, rows: res.(driver.RowsAffected)}
 		case 'E':
 			err = parseError(r, cn)
 			{
@@ -465,6 +692,8 @@ func (cn *conn) simpleExec(q string) (driver.Result, string, error) {
 			res = emptyRows
 		case 'T', 'D':
 			// ignore any results
+		case 'W':
+			return nil, "", cn.abortCopyBoth()
 		default:
 			cn.setBad()
 			return nil, "", fmt.Errorf("unknown response for simple query: %q", t)
@@ -504,6 +733,10 @@ func (cn *conn) replaceQuery(stmtName string, query string) (new_query string, q
 }
 
 func (cn *conn) simpleQuery(q string) (res *rows, err error) { // TODO: named return value
+	cn.stmtQuery = q
+	if q, err = cn.clientString(q); err != nil {
+		return nil, err
+	}
 	if cn.pgconn != nil {
 		var queryCstring *Cchar
 		runtime.LockOSThread()
@@ -588,7 +821,7 @@ func (cn *conn) simpleQuery(q string) (res *rows, err error) { // TODO: named re
 			// CommandComplete, but that's fine; just overwrite it
 			res = &rows{cn: cn}
 
-			des, err := parsePortalRowDescribe(r)
+			des, err := parsePortalRowDescribe(&cn.parameterStatus, r)
 			if err != nil {
 				return nil, fmt.Errorf("cannot parse protal row describe: %w", err)
 			}
@@ -596,6 +829,8 @@ func (cn *conn) simpleQuery(q string) (res *rows, err error) { // TODO: named re
 
 			// To work around a bug in QueryRow in Go 1.2 and earlier, wait
 			// until the first DataRow has been received.
+		case 'W':
+			return nil, cn.abortCopyBoth()
 		default:
 			cn.setBad()
 			return nil, fmt.Errorf("unknown response for simple query: %q", t)
@@ -676,7 +911,14 @@ func decideColumnFormats(colTyps []fieldDesc, forceText bool) (colFmts []format,
 }
 
 func (cn *conn) prepareTo(q, stmtName string) (st *stmt, err error) {
-	st = &stmt{cn: cn, name: stmtName}
+	st = &stmt{cn: cn, name: stmtName, sql: q, gen: cn.stmtGen}
+	if err := cn.checkPlaceholders(q); err != nil {
+		return nil, err
+	}
+	cn.stmtQuery = q
+	if q, err = cn.clientString(q); err != nil {
+		return nil, err
+	}
 
 	if cn.pgconn != nil {
 		var queryCstring *Cchar
@@ -759,7 +1001,7 @@ func (cn *conn) Prepare(q string) (_ driver.Stmt, err error) {
 
 func (cn *conn) prepare(q string, check_resend bool) (stmt driver.Stmt, err error) {
 	if cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, cn.errBadConn()
 	}
 	if check_resend {
 		defer cn.prepareResend(q, &stmt, &err)
@@ -812,9 +1054,33 @@ func pgconnNilSetter(pgconn **PGconn) {
 	*pgconn = nil
 }
 
+// terminateTimeout bounds sending the Terminate message in Close.
+const terminateTimeout = 5 * time.Second
+
+// Close sends the Terminate message, waiting at most terminateTimeout for it
+// to be written, and closes the network connection.
 func (cn *conn) Close() (err error) {
+	return cn.close(context.Background())
+}
+
+// CloseContext closes the given connection as its Close method does, but
+// stops waiting for the Terminate message to be written when ctx is done.
+// Terminating the session cleanly keeps the server from logging an
+// unexpected EOF on client connection. A runtime panic occurs if c is not a
+// pq connection.
+func CloseContext(ctx context.Context, c driver.Conn) error {
+	return c.(*conn).close(ctx)
+}
+
+func (cn *conn) close(ctx context.Context) (err error) {
+	if cn.hijacked {
+		return nil
+	}
 	cn.LockWriterMutex()
 	defer cn.UnlockWriterMutex()
+	defer func() { cn.closed(err) }()
+	cn.stopHeartbeat()
+	cn.stopWatcher()
 	// Ensure that cn.c.Close is always run. Since error handling is done with
 	// cn.errRecover, the Close must be in a defer.
 	defer cn.c.Close()
@@ -832,6 +1098,22 @@ func (cn *conn) Close() (err error) {
 		defer pgconnNilSetter(&cn.pgconn)
 		defer pgconn_free(cn.pgconn)
 	}
+	deadline := time.Now().Add(terminateTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = cn.c.SetWriteDeadline(deadline)
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				_ = cn.c.SetWriteDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+	}
 	// Don't go through send(); ListenerConn relies on us not scribbling on the
 	// scratch buffer of this connection.
 	return cn.sendSimpleMessage('X')
@@ -846,7 +1128,7 @@ func (cn *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 
 func (cn *conn) query(query string, args []driver.Value, check_resend bool) (res *rows, err error) {
 	if cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, cn.errBadConn()
 	}
 	if cn.inCopy {
 		return nil, errCopyInProgress
@@ -858,6 +1140,9 @@ func (cn *conn) query(query string, args []driver.Value, check_resend bool) (res
 	// Check to see if we can use the "simpleQuery" interface, which is
 	// *much* faster than going through prepare/exec
 	if len(args) == 0 {
+		if err := cn.checkSingleStatement(query); err != nil {
+			return nil, err
+		}
 		return cn.simpleQuery(query)
 	}
 
@@ -932,7 +1217,7 @@ func (cn *conn) Exec(query string, args []driver.Value) (res driver.Result, err
 // Exec Implement the optional "Execer" interface for one-shot queries
 func (cn *conn) exec(query string, args []driver.Value, check_resend bool) (res driver.Result, err error) {
 	if cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, cn.errBadConn()
 	}
 	if check_resend {
 		defer cn.execResend(query, args, &res, &err)
@@ -941,6 +1226,9 @@ func (cn *conn) exec(query string, args []driver.Value, check_resend bool) (res
 	// Check to see if we can use the "simpleExec" interface, which is
 	// *much* faster than going through prepare/exec
 	if len(args) == 0 {
+		if err := cn.checkSingleStatement(query); err != nil {
+			return nil, err
+		}
 		// ignore commandTag, our caller doesn't care
 		r, _, err := cn.simpleExec(query)
 		if err != nil {
@@ -1024,14 +1312,19 @@ func (se *safeRetryError) Unwrap() error {
 }
 
 func (cn *conn) send(m *writeBuf) error {
-	n, err := cn.c.Write(m.wrap())
+	cn.setIdle(false)
+	msg := m.wrap()
+	cn.traceFrontend(msg)
+	n, err := cn.c.Write(msg)
 	if err != nil {
 		if n == 0 || err == io.EOF {
-			return &safeRetryError{Err: fmt.Errorf("fail to write %v: %w", err, driver.ErrBadConn)}
+			cn.setBadErr(err)
+			return &safeRetryError{Err: fmt.Errorf("fail to write: %w", badConn(err))}
 		}
+		cn.setBadErr(err)
 		return &connErr{
-			msg: fmt.Sprintf("fail to write %v", err),
-			err: driver.ErrBadConn,
+			msg: "fail to write",
+			err: badConn(err),
 		}
 	}
 
@@ -1042,10 +1335,13 @@ func (cn *conn) send(m *writeBuf) error {
 }
 
 func (cn *conn) sendStartupPacket(m *writeBuf) error {
-	if _, err := cn.c.Write((m.wrap())[1:]); err != nil {
+	msg := (m.wrap())[1:]
+	cn.traceUntyped("StartupMessage", len(msg), "")
+	if _, err := cn.c.Write(msg); err != nil {
+		cn.setBadErr(err)
 		return connErr{
-			msg: fmt.Sprintf("fail to write: %v", err),
-			err: driver.ErrBadConn,
+			msg: "fail to write",
+			err: badConn(err),
 		}
 	}
 	return nil
@@ -1055,10 +1351,14 @@ func (cn *conn) sendStartupPacket(m *writeBuf) error {
 // message should have no payload.  This method does not use the scratch
 // buffer.
 func (cn *conn) sendSimpleMessage(typ byte) (err error) {
-	if _, err = cn.c.Write([]byte{typ, '\x00', '\x00', '\x00', '\x04'}); err != nil {
+	cn.setIdle(false)
+	msg := []byte{typ, '\x00', '\x00', '\x00', '\x04'}
+	cn.traceFrontend(msg)
+	if _, err = cn.c.Write(msg); err != nil {
+		cn.setBadErr(err)
 		return connErr{
-			msg: fmt.Sprintf("fail to write: %v", err),
-			err: driver.ErrBadConn,
+			msg: "fail to write",
+			err: badConn(err),
 		}
 	}
 	return nil
@@ -1093,27 +1393,45 @@ func (cn *conn) recvMessage(r *readBuf) (byte, error) {
 
 	x := cn.scratch[:5]
 	if _, err := io.ReadFull(cn.buf, x); err != nil {
+		cn.setBadErr(err)
 		return 0, connErr{
-			msg: fmt.Sprintf("fail to read: %v", err),
-			err: driver.ErrBadConn, // for database/sql errors.Is and retry
+			msg: "fail to read",
+			err: badConn(err), // for database/sql errors.Is and retry
 		}
 	}
 
 	// read the type and length of the message that follows
 	t := x[0]
 	n := int(binary.BigEndian.Uint32(x[1:])) - 4
-	var y []byte
-	if n <= len(cn.scratch) {
-		y = cn.scratch[:n]
-	} else {
-		y = make([]byte, n)
+	if n < 0 {
+		cn.setBad()
+		return 0, fmt.Errorf("invalid length %d of message %q", n+4, t)
 	}
+	if n > cn.maxMessageSize() {
+		cn.setBad()
+		return 0, fmt.Errorf("%w: message %q of %d bytes", ErrMessageTooLarge, t, n)
+	}
+	if t == 'D' && cn.config != nil && cn.config.MaxRowBytes > 0 && n > cn.config.MaxRowBytes {
+		// leave it to rows.Next to report the row as too large
+		if _, err := io.CopyN(io.Discard, cn.buf, int64(n)); err != nil {
+			cn.setBadErr(err)
+			return 0, connErr{
+				msg: "fail to read",
+				err: badConn(err),
+			}
+		}
+		*r = nil
+		return t, nil
+	}
+	y := cn.messageBuffer(n)
 	if _, err := io.ReadFull(cn.buf, y); err != nil {
+		cn.setBadErr(err)
 		return 0, connErr{
-			msg: fmt.Sprintf("fail to read: %v", err),
-			err: driver.ErrBadConn, // for database/sql errors.Is and retry
+			msg: "fail to read",
+			err: badConn(err), // for database/sql errors.Is and retry
 		}
 	}
+	cn.traceBackend(t, y)
 	*r = y
 	return t, nil
 }
@@ -1133,12 +1451,10 @@ func (cn *conn) recv() (t byte, r *readBuf, err error) {
 		case 'E':
 			return 0, nil, parseError(r, cn)
 		case 'N':
-			if n := cn.noticeHandler; n != nil {
-				n(parseError(r, cn))
-			}
+			cn.handleNotice(r)
 		case 'A':
 			if n := cn.notificationHandler; n != nil {
-				not, err := recvNotification(r)
+				not, err := recvNotification(&cn.parameterStatus, r)
 				if err != nil {
 					return 0, nil, fmt.Errorf("cannot recv notification: %w", err)
 				}
@@ -1162,16 +1478,14 @@ func (cn *conn) recv1Buf(r *readBuf) (byte, error) {
 		switch t {
 		case 'A':
 			if n := cn.notificationHandler; n != nil {
-				not, err := recvNotification(r)
+				not, err := recvNotification(&cn.parameterStatus, r)
 				if err != nil {
 					return 0, fmt.Errorf("cannot recv notification: %w", err)
 				}
 				n(not)
 			}
 		case 'N':
-			if n := cn.noticeHandler; n != nil {
-				n(parseError(r, cn))
-			}
+			cn.handleNotice(r)
 		case 'S': // ParameterStatus
 			if err := cn.processParameterStatus(r); err != nil {
 				return 0, fmt.Errorf("cannot process parameter status: %w", err)
@@ -1206,7 +1520,13 @@ func (cn *conn) checkCertificate(tlsConn *tls.Conn) error {
 	return nil
 }
 
-func (cn *conn) startup() error {
+func (cn *conn) startup(ctx context.Context) error {
+	cn.parameterStatus.scanLocation = cn.config.ScanLocation
+	cn.parameterStatus.textAsBytes = cn.config.TextAsBytes
+	cn.parameterStatus.numericAsString = cn.config.NumericAsString
+	cn.parameterStatus.timestampRounding = cn.config.TimestampRounding
+	cn.parameterStatus.dbCompatibility = cn.config.DBCompatibility
+
 	w := cn.writeBuf(0)
 	w.int32(196659)
 	// Send the backend the name of the database we want to connect to, and the
@@ -1222,6 +1542,52 @@ func (cn *conn) startup() error {
 			application_name = v
 		}
 	}
+	if info, ok := cn.config.connectionInfoParam(); ok {
+		w.string("connection_info")
+		w.string(info)
+	}
+	if _, ok := cn.config.RuntimeParams["replication"]; !ok && cn.config.Replication != "" {
+		w.string("replication")
+		w.string(cn.config.Replication)
+	}
+	if _, ok := cn.config.RuntimeParams["search_path"]; !ok && cn.config.SearchPath != "" {
+		w.string("search_path")
+		w.string(cn.config.SearchPath)
+	}
+	if _, ok := cn.config.RuntimeParams["bytea_output"]; !ok && cn.config.ByteaOutput != "" {
+		w.string("bytea_output")
+		w.string(cn.config.ByteaOutput)
+	}
+	if _, ok := cn.config.RuntimeParams["default_transaction_isolation"]; !ok && cn.config.DefaultTransactionIsolation != "" {
+		w.string("default_transaction_isolation")
+		w.string(cn.config.DefaultTransactionIsolation)
+	}
+	if _, ok := cn.config.RuntimeParams["default_transaction_read_only"]; !ok && cn.config.DefaultTransactionReadOnly != "" {
+		w.string("default_transaction_read_only")
+		w.string(cn.config.DefaultTransactionReadOnly)
+	}
+	if _, ok := cn.config.RuntimeParams["client_min_messages"]; !ok && cn.config.ClientMinMessages != "" {
+		w.string("client_min_messages")
+		w.string(cn.config.ClientMinMessages)
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"statement_timeout", cn.config.StatementTimeout},
+		{"lock_timeout", cn.config.LockTimeout},
+		{"idle_in_transaction_session_timeout", cn.config.IdleInTransactionSessionTimeout},
+	} {
+		if _, ok := cn.config.RuntimeParams[t.name]; ok || t.d <= 0 {
+			continue
+		}
+		ms := t.d.Milliseconds()
+		if ms == 0 {
+			ms = 1 // zero would disable the timeout
+		}
+		w.string(t.name)
+		w.string(strconv.FormatInt(ms, 10))
+	}
 	if cn.config.Database != "" {
 		w.string("database")
 		w.string(cn.config.Database)
@@ -1242,6 +1608,11 @@ func (cn *conn) startup() error {
 		if err != nil {
 			return fmt.Errorf("cannot convey int from string: %w", err)
 		}
+		if p := cn.config.LocalKMSFilePath; p != "" {
+			if err := useLocalKMSFilePath(p); err != nil {
+				return err
+			}
+		}
 		c_int := Cint(res)
 		if (cn.cn_ptr == nil) || ((cn.cn_ptr != nil) &&
 			(unsafe.Pointer(cn) != unsafe.Pointer(getPointer(cn.cn_ptr).(*conn)))) {
@@ -1266,7 +1637,7 @@ func (cn *conn) startup() error {
 		return fmt.Errorf("cannot send startup packet: %w", err)
 	}
 
-	tlsConn, ok := cn.c.(*tls.Conn)
+	tlsConn, ok := unwrapConn(cn.c).(*tls.Conn)
 	if ok {
 		if err := cn.checkCertificate(tlsConn); err != nil {
 			if err := cn.c.Close(); err != nil {
@@ -1282,6 +1653,12 @@ func (cn *conn) startup() error {
 			return fmt.Errorf("cannot recv from conn: %w", err)
 		}
 		switch t {
+		case 'z':
+			if err := cn.processCompressionAck(r); err != nil {
+				return fmt.Errorf("cannot process compression ack: %w", err)
+			}
+		case 'v':
+			cn.processNegotiateProtocolVersion(r)
 		case 'K':
 			cn.processBackendKeyData(r)
 		case 'S':
@@ -1289,9 +1666,12 @@ func (cn *conn) startup() error {
 				return fmt.Errorf("cannot process parameter status: %w", err)
 			}
 		case 'R':
-			if err := cn.auth(r); err != nil {
+			cn.authStarted = true
+			start := time.Now()
+			if err := cn.auth(ctx, r); err != nil {
 				return fmt.Errorf("fail to auth: %w", err)
 			}
+			cn.config.Stats.observeAuth(time.Since(start))
 		case 'Z':
 			cn.processReadyForQuery(r)
 			found, err := cn.ValidateConnect()
@@ -1324,6 +1704,12 @@ func (cn *conn) startup() error {
 					return fmt.Errorf("cannot close connect: %w", err)
 				}
 			} else if found {
+				if cn.parameterStatus.dbCompatibility == "B" && cn.config.Replication == "" {
+					if err := cn.loadDolphinTypes(); err != nil {
+						cn.log(ctx, LogLevelWarn, "cannot load dolphin types, their values are returned as []byte",
+							map[string]interface{}{"err": err})
+					}
+				}
 				return nil
 			}
 			return fmt.Errorf("ValidateConnect failed")
@@ -1333,9 +1719,19 @@ func (cn *conn) startup() error {
 	}
 }
 
-func (cn *conn) auth(r *readBuf) error {
+func (cn *conn) auth(ctx context.Context, r *readBuf) error {
 	var decodePwdByte []byte
 	getPwdPlain := func() (string, error) { // TODO: refactor
+		if f := cn.config.PasswordFunc; f != nil {
+			password, err := f(ctx)
+			if err != nil {
+				return "", fmt.Errorf("cannot get password: %w", err)
+			}
+			if password == "" {
+				return "", errors.New("the server requested password-based authentication, but PasswordFunc returned no password")
+			}
+			return password, nil
+		}
 		if len(cn.config.Password) == 0 {
 			return "", errors.New("the server requested password-based authentication, but no password was provided")
 		}
@@ -1346,14 +1742,36 @@ func (cn *conn) auth(r *readBuf) error {
 		}
 		return string(decodePwdByte), nil
 	}
+	// cleartext and sha256 authentication accept a token from
+	// AuthTokenProvider instead of the password
+	getAuthSecret := func() (string, error) {
+		p := cn.config.AuthTokenProvider
+		if p == nil {
+			return getPwdPlain()
+		}
+		token, err := p.AuthToken(ctx, cn.authTokenRequest())
+		if err != nil {
+			return "", fmt.Errorf("cannot get auth token: %w", err)
+		}
+		return token, nil
+	}
+	// SCRAM and sha256 authentication hash the password prepared with
+	// SASLprep
+	getPreparedSecret := func() (string, error) {
+		secret, err := getAuthSecret()
+		if err != nil {
+			return "", err
+		}
+		return cn.preparePassword(secret), nil
+	}
 
 	switch code := r.int32(); code {
 	case 0:
-		// OK
+		cn.authInfo = AuthInfo{Method: AuthMethodTrust}
 	case 3:
 		w := cn.writeBuf('p')
 
-		plain, err := getPwdPlain()
+		plain, err := getAuthSecret()
 		if err != nil {
 			return fmt.Errorf("cannot get pwd plain: %w", err)
 		}
@@ -1373,6 +1791,7 @@ func (cn *conn) auth(r *readBuf) error {
 		if r.int32() != 0 {
 			return fmt.Errorf("unexpected authentication response: %q", t)
 		}
+		cn.authInfo = AuthInfo{Method: AuthMethodPassword}
 	case 5:
 		s := string(r.next(4))
 		w := cn.writeBuf('p')
@@ -1396,47 +1815,26 @@ func (cn *conn) auth(r *readBuf) error {
 		if r.int32() != 0 {
 			return fmt.Errorf("unexpected authentication response: %q", t)
 		}
+		cn.authInfo = AuthInfo{Method: AuthMethodMD5}
 	case 7: // GSSAPI, startup
-		return fmt.Errorf("GSSAPI protocol not supported")
+		return fmt.Errorf("GSSAPI protocol not supported (service principal %s)", cn.krbSPN())
 	case 8: // GSSAPI continue
 		return fmt.Errorf("GSSAPI protocol not supported")
 
 	case 10:
+		// the AuthenticationSASL request of PostgreSQL, e.g. from a proxy,
+		// shares its code with sha256 authentication
+		if names, ok := parseSASLMechanisms(*r); ok {
+			if err := cn.authSASL(ctx, names, getPreparedSecret); err != nil {
+				return err
+			}
+			break
+		}
 		passwordStoredMethod := r.int32()
 		digest := ""
 		if passwordStoredMethod == 0 || passwordStoredMethod == 2 {
-			random64code := string(r.next(64))
-			token := string(r.next(8))
-			serverIteration := r.int32()
-			plain, err := getPwdPlain()
-			if err != nil {
-				return fmt.Errorf("cannot get pwd plain: %w", err)
-			}
-			result := RFC5802Algorithm(plain, random64code, token, "", serverIteration)
-			if len(result) == 0 {
-				return fmt.Errorf("invalid username/password,login denied")
-			}
-			w := cn.writeBuf('p')
-			w.buf = []byte("p")
-			w.pos = 1
-			w.int32(4 + len(result) + 1)
-			w.bytes(result)
-			w.byte(0)
-			if err = cn.send(w); err != nil {
-				return fmt.Errorf("fail to send: %w", err)
-			}
-
-			t, r, err := cn.recv()
-			if err != nil {
-				return fmt.Errorf("cannot recv from conn: %w", err)
-			}
-
-			if t != 'R' {
-				return fmt.Errorf("unexpected password response: %q", t)
-			}
-
-			if r.int32() != 0 {
-				return fmt.Errorf("unexpected authentication response: %q", t)
+			if err := cn.authSHA256(ctx, *r, getPreparedSecret); err != nil {
+				return err
 			}
 		} else if passwordStoredMethod == 1 {
 			s := string(r.next(4))
@@ -1463,6 +1861,7 @@ func (cn *conn) auth(r *readBuf) error {
 			if r.int32() != 0 {
 				return fmt.Errorf("unexpected authentication response: %q", t)
 			}
+			cn.authInfo = AuthInfo{Method: AuthMethodMD5}
 		} else {
 			return fmt.Errorf("The  password-stored method is not supported ,must be plain , md5 or sha256.")
 		}
@@ -1498,6 +1897,7 @@ func (cn *conn) auth(r *readBuf) error {
 		if r.int32() != 0 {
 			return fmt.Errorf("unexpected authentication response: %q", t)
 		}
+		cn.authInfo = AuthInfo{Method: AuthMethodMD5SHA256}
 
 	default:
 		return fmt.Errorf("unknown authentication response: %d", code)
@@ -1623,10 +2023,14 @@ func (c *colTypeErr) ColType() bool {
 type stmt struct {
 	cn   *conn
 	name string
+	sql  string
 	rowsHeader
 	colFmtData []byte
 	paramTypes []oid.Oid
 	closed     bool
+
+	// conn.stmtGen when the statement was prepared
+	gen uint64
 }
 
 func (st *stmt) Close() (err error) {
@@ -1634,7 +2038,7 @@ func (st *stmt) Close() (err error) {
 		return nil
 	}
 	if st.cn.getBad() {
-		return driver.ErrBadConn
+		return st.cn.errBadConn()
 	}
 
 	w := st.cn.writeBuf('C')
@@ -1679,7 +2083,7 @@ func (st *stmt) Query(v []driver.Value) (driver.Rows, error) {
 
 func (st *stmt) query(v []driver.Value) (r *rows, err error) {
 	if st.cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, st.cn.errBadConn()
 	}
 
 	err = st.exec(v, true)
@@ -1693,15 +2097,19 @@ func (st *stmt) query(v []driver.Value) (r *rows, err error) {
 	}, nil
 }
 
-const maxParamNum = 65536 // maximum number of parameters bound in a batch
-
 func (st *stmt) Exec(v []driver.Value) (driver.Result, error) {
 	var err error
 
 	if st.cn.getBad() {
-		return nil, driver.ErrBadConn
+		return nil, st.cn.errBadConn()
 	}
 
+	if res, ok, err := st.execBatchedInsert(v); ok {
+		if err != nil {
+			return nil, fmt.Errorf("fail to exec rewritten batch: %w", err)
+		}
+		return res, nil
+	}
 	if err = st.exec(v, true); err != nil {
 		return nil, fmt.Errorf("fail to exec, error: %w", err)
 	}
@@ -1746,7 +2154,7 @@ func findOid(v []driver.Value, idx int) []oid.Oid {
 		oid.T__regconfig, oid.T_name, oid.T__name, oid.T_circle, oid.T__circle, oid.T__point, oid.T_box,
 		oid.T__box, oid.T_lseg, oid.T_point, oid.T__lseg, oid.T_path, oid.T__path, oid.T__polygon, oid.T_polygon,
 		oid.T_cidr, oid.T_inet, oid.T__inet, oid.T_macaddr, oid.T__macaddr, oid.T__tsvector, oid.T_tsvector,
-		oid.T_tsquery, oid.T__tsquery, oid.T_uuid, oid.T__uuid, oid.T__json, oid.T_json, oid.T_hll, oid.T__hll,
+		oid.T_tsquery, oid.T__tsquery, oid.T_uuid, oid.T__uuid, oid.T__json, oid.T_json, oid.T_jsonb, oid.T__jsonb, oid.T_hll, oid.T__hll,
 		oid.T_int4range, oid.T_int8range, oid.T__int4range, oid.T__int8range, oid.T_daterange, oid.T__daterange,
 		oid.T_numrange, oid.T__numrange, oid.T_tsrange, oid.T__tsrange, oid.T_tstzrange, oid.T__tstzrange,
 		oid.T_hll_trans_type, oid.T__hll_trans_type, oid.T_tid, oid.T__tid, oid.T_xid, oid.T__xid, oid.T_cid,
@@ -1777,7 +2185,7 @@ func findOid(v []driver.Value, idx int) []oid.Oid {
 		return boolOid
 	case []byte:
 		return byteOid
-	case string:
+	case string, json.RawMessage:
 		return stringOid
 	case int64:
 		return intOid
@@ -1896,6 +2304,11 @@ func (st *stmt) send_clientlogic_parameters(pgconn *PGconn, writer *writeBuf,
 }
 
 func (st *stmt) exec(v []driver.Value, check_retry bool) error {
+	if err := st.reprepare(); err != nil {
+		return fmt.Errorf("cannot prepare deallocated statement: %w", err)
+	}
+	// errors are located in the query of st, not in the last one prepared
+	st.cn.stmtQuery = st.sql
 	if st.cn.pgconn != nil && check_retry {
 		defer st.exec_retry(v) //check & retry if we have client cache error
 	}
@@ -1908,8 +2321,8 @@ func (st *stmt) exec(v []driver.Value, check_retry bool) error {
 	} else if len(v) != 0 && len(st.paramTypes) != 0 && len(v)%len(st.paramTypes) == 0 {
 		// SELECT check argument size
 		if len(v) != len(st.paramTypes) && st.colFmts != nil {
-			return fmt.Errorf("got %d parameters but the statement may requires %d",
-				len(v), len(st.paramTypes))
+			return fmt.Errorf("got %d parameters but the statement requires %d; only statements "+
+				"returning no rows accept a multiple of it as a batch", len(v), len(st.paramTypes))
 		}
 
 		if len(v) == len(st.paramTypes) {
@@ -1919,7 +2332,8 @@ func (st *stmt) exec(v []driver.Value, check_retry bool) error {
 			}
 		} else {
 			if st.cn.pgconn != nil && checkHaveCeCol(st.paramTypes) {
-				return fmt.Errorf("got %d parameters but the statement may requires %d", len(v), len(st.paramTypes))
+				return fmt.Errorf("got %d parameters but the statement requires %d; batches are not "+
+					"supported for encrypted columns", len(v), len(st.paramTypes))
 			}
 
 			err := checkColTypes(st.paramTypes, v)
@@ -1936,8 +2350,8 @@ func (st *stmt) exec(v []driver.Value, check_retry bool) error {
 			return fmt.Errorf("got %d parameters but the statement requires 0",
 				len(v))
 		}
-		return fmt.Errorf("got %d parameters but the statement may requires %d",
-			len(v), len(v)-len(v)%len(st.paramTypes)+len(st.paramTypes))
+		return fmt.Errorf("got %d parameters but the statement requires %d, or a multiple of it as a batch",
+			len(v), len(st.paramTypes))
 	}
 
 	return nil
@@ -1962,9 +2376,8 @@ func checkHaveCeCol(types []oid.Oid) bool {
 }
 
 func (st *stmt) execSingle(v []driver.Value, check_retry bool) error {
-	// validate Max args length
-	if len(v) >= maxParamNum {
-		return fmt.Errorf("got %d parameters but PostgreSQL only supports 65535 parameters", len(v))
+	if len(v) > MaxBindParameters {
+		return errTooManyParameters(len(v))
 	}
 
 	cn := st.cn
@@ -2174,6 +2587,9 @@ func (st *stmt) NumInput() int {
 // identifying only the command that was executed, e.g. "ALTER TABLE".  If the
 // command tag could not be parsed, parseComplete returns error.
 func (cn *conn) parseComplete(cmdTag string) (driver.Result, string, error) {
+	cn.lastCommandTag = cmdTag
+	cn.config.Stats.commandCompleted(cmdTag)
+	cn.onCommandComplete(cmdTag)
 	commandsWithAffectedRows := []string{
 		"SELECT ",
 		// INSERT is handled below
@@ -2326,8 +2742,16 @@ func (cn *conn) sendBinaryParameters(stmt_name string, b *writeBuf, args []drive
 }
 
 func (cn *conn) sendBinaryModeQuery(q string, args []driver.Value) error {
-	if len(args) >= 65536 {
-		return fmt.Errorf("got %d parameters but PostgreSQL only supports 65535 parameters", len(args))
+	if len(args) > MaxBindParameters {
+		return errTooManyParameters(len(args))
+	}
+	if err := cn.checkPlaceholders(q); err != nil {
+		return err
+	}
+	cn.stmtQuery = q
+	q, err := cn.clientString(q)
+	if err != nil {
+		return err
 	}
 	if cn.pgconn != nil {
 		/*
@@ -2383,6 +2807,12 @@ func (cn *conn) processParameterStatus(r *readBuf) error {
 	}
 
 	cn.log(context.Background(), LogLevelInfo, "[connection parameter]", map[string]interface{}{param + ":": val})
+	cn.serverParamsMu.Lock()
+	if cn.serverParams == nil {
+		cn.serverParams = make(map[string]string)
+	}
+	cn.serverParams[param] = val
+	cn.serverParamsMu.Unlock()
 	switch param {
 	case "server_version":
 		var major1 int
@@ -2399,9 +2829,18 @@ func (cn *conn) processParameterStatus(r *readBuf) error {
 		}
 
 	case "TimeZone":
-		cn.parameterStatus.currentLocation, err = time.LoadLocation(val)
-		if err != nil {
-			cn.parameterStatus.currentLocation = nil
+		cn.parameterStatus.currentLocation = sessionLocation(val)
+		if cn.parameterStatus.currentLocation == nil {
+			cn.log(context.Background(), LogLevelWarn, "cannot resolve session TimeZone, timestamptz values keep the offset sent by the server",
+				map[string]interface{}{"TimeZone": val})
+		}
+
+	case "client_encoding":
+		cn.setClientEncoding(val)
+
+	case "sql_compatibility":
+		if cn.config.DBCompatibility == "" {
+			cn.parameterStatus.dbCompatibility = strings.ToUpper(val)
 		}
 
 	case "standard_conforming_strings":
@@ -2420,7 +2859,11 @@ func (cn *conn) processParameterStatus(r *readBuf) error {
 }
 
 func (cn *conn) processReadyForQuery(r *readBuf) {
-	cn.txnStatus = transactionStatus(r.byte())
+	status := transactionStatus(r.byte())
+	if status == txnStatusIdle && cn.txnStatus != txnStatusIdle {
+		cn.txnGen++
+	}
+	cn.txnStatus = status
 	/* if the pgconn is initialized, we can assume the client logic was turned on */
 	if cn.pgconn != nil {
 		/**
@@ -2502,7 +2945,7 @@ func (cn *conn) readStatementDescribeResponse() (paramTyps []oid.Oid, colNames [
 		case 'n': // NoData
 			return paramTyps, nil, nil, nil
 		case 'T': // RowDescription
-			colNames, colTyps, err = parseStatementRowDescribe(r)
+			colNames, colTyps, err = parseStatementRowDescribe(&cn.parameterStatus, r)
 			if err != nil {
 				return nil, nil, nil, fmt.Errorf("cannot parse statement row desceibe: %w", err)
 			}
@@ -2530,7 +2973,7 @@ func (cn *conn) readPortalDescribeResponse() (rowsHeader, error) {
 	}
 	switch t {
 	case 'T':
-		return parsePortalRowDescribe(r)
+		return parsePortalRowDescribe(&cn.parameterStatus, r)
 	case 'n':
 		return rowsHeader{}, nil
 	case 'E':
@@ -2630,6 +3073,7 @@ func (cn *conn) readExecuteResponse(protocolState string) (res driver.Result, cm
 			if err != nil {
 				return nil, "", fmt.Errorf("cannot parse complete: %w", err)
 			}
+			res = &Result{tags: []CommandTag{CommandTag(s)}, rows: res.(driver.RowsAffected)}
 		case 'Z':
 			cn.processReadyForQuery(r)
 			if res == nil && err == nil {
@@ -2650,7 +3094,7 @@ func (cn *conn) readExecuteResponse(protocolState string) (res driver.Result, cm
 	}
 }
 
-func parseStatementRowDescribe(r *readBuf) (colNames []string, colTyps []fieldDesc, err error) {
+func parseStatementRowDescribe(ps *parameterStatus, r *readBuf) (colNames []string, colTyps []fieldDesc, err error) {
 	n := r.int16()
 	colNames = make([]string, n)
 	colTyps = make([]fieldDesc, n)
@@ -2659,8 +3103,9 @@ func parseStatementRowDescribe(r *readBuf) (colNames []string, colTyps []fieldDe
 		if err != nil {
 			return nil, nil, fmt.Errorf("cannot get string from read buf: %w", err)
 		}
-		colNames[i] = s
-		r.next(6)
+		colNames[i] = ps.fromServerString(s)
+		colTyps[i].TableOID = r.oid()
+		colTyps[i].TableAttr = int(int16(r.int16()))
 		colTyps[i].OID = r.oid()
 		colTyps[i].Len = r.int16()
 		colTyps[i].Mod = r.int32()
@@ -2670,7 +3115,7 @@ func parseStatementRowDescribe(r *readBuf) (colNames []string, colTyps []fieldDe
 	return
 }
 
-func parsePortalRowDescribe(r *readBuf) (rowsHeader, error) {
+func parsePortalRowDescribe(ps *parameterStatus, r *readBuf) (rowsHeader, error) {
 	n := r.int16()
 	colNames := make([]string, n)
 	colFmts := make([]format, n)
@@ -2680,8 +3125,9 @@ func parsePortalRowDescribe(r *readBuf) (rowsHeader, error) {
 		if err != nil {
 			return rowsHeader{}, fmt.Errorf("cannot get string from read buf: %w", err)
 		}
-		colNames[i] = s
-		r.next(6)
+		colNames[i] = ps.fromServerString(s)
+		colTyps[i].TableOID = r.oid()
+		colTyps[i].TableAttr = int(int16(r.int16()))
 		colTyps[i].OID = r.oid()
 		colTyps[i].Len = r.int16()
 		colTyps[i].Mod = r.int32()
@@ -2751,6 +3197,30 @@ func (cn *conn) initEnclave() error {
 	return initErr
 }
 
+// localKMSMu serializes setting LOCALKMS_FILE_PATH, which libpq_ce reads from
+// the environment whenever it loads a localkms client master key.
+var localKMSMu sync.Mutex
+
+// useLocalKMSFilePath makes libpq_ce read localkms keys from dir. Since the
+// library only reads the environment, the variable is set once for the
+// process and never changed, as other connections may be loading keys; a
+// connection asking for another directory fails.
+func useLocalKMSFilePath(dir string) error {
+	localKMSMu.Lock()
+	defer localKMSMu.Unlock()
+	if cur, ok := os.LookupEnv("LOCALKMS_FILE_PATH"); ok {
+		if cur != dir {
+			return fmt.Errorf("pq: localkms_file_path %q differs from LOCALKMS_FILE_PATH %q, "+
+				"which applies to every connection of the process", dir, cur)
+		}
+		return nil
+	}
+	if err := os.Setenv("LOCALKMS_FILE_PATH", dir); err != nil {
+		return fmt.Errorf("cannot set LOCALKMS_FILE_PATH: %w", err)
+	}
+	return nil
+}
+
 // clear all cached ceks in trusted domain (Packet V4)
 func (cn *conn) clearEnclave() error {
 	err := cn.sendOrDestroyCekInfo(teeCleanKey, 0, 0, 0, nil)
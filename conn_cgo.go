@@ -45,9 +45,9 @@ func getResultSet(rows *rows, columns_count int) (resultset [][]string, err erro
 				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 					dest = strconv.FormatUint(rv.Uint(), 10)
 				case reflect.Float64:
-					dest = strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+					dest = string(appendFloat(nil, rv.Float(), 'g', 64))
 				case reflect.Float32:
-					dest = strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+					dest = string(appendFloat(nil, rv.Float(), 'g', 32))
 				case reflect.Bool:
 					dest = strconv.FormatBool(rv.Bool())
 				default:
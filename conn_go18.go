@@ -4,53 +4,141 @@ package pq
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
 	"sync/atomic"
 	"time"
 )
 
 // Implement the "QueryerContext" interface
 func (cn *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return cn.config.interceptQuery(cn.queryContext)(ctx, query, args)
+}
+
+func (cn *conn) queryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, args, err := cn.translateQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
 	list := make([]driver.Value, len(args))
 	for i, nv := range args {
 		list[i] = nv.Value
 	}
+	span := cn.traceStart(ctx, TraceOpQuery, query, len(args))
+	restore, err := cn.setDeadlineTimeout(ctx)
+	if err != nil {
+		span.end("", err)
+		return nil, err
+	}
 	finish := cn.watchCancel(ctx)
 	r, err := cn.query(query, list, true)
 	if err != nil {
 		if finish != nil {
 			finish()
 		}
+		restore()
+		err = contextErr(ctx, err)
+		span.end("", err)
 		return nil, err
 	}
-	r.finish = finish
+	r.finish = func() {
+		if finish != nil {
+			finish()
+		}
+		restore()
+	}
+	r.span = span
 	return r, nil
 }
 
 // Implement the "ExecerContext" interface
 func (cn *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return cn.config.interceptExec(cn.execContext)(ctx, query, args)
+}
+
+func (cn *conn) execContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, args, err := cn.translateQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
 	list := make([]driver.Value, len(args))
 	for i, nv := range args {
 		list[i] = nv.Value
 	}
 
+	restore, err := cn.setDeadlineTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
 	if finish := cn.watchCancel(ctx); finish != nil {
 		defer finish()
 	}
 
-	return cn.Exec(query, list)
+	span := cn.traceStart(ctx, TraceOpExec, query, len(args))
+	var res driver.Result
+	if q, ok := cn.insertReturning(query, len(list)); ok {
+		res, err = cn.execReturning(q, list)
+	} else {
+		res, err = cn.Exec(query, list)
+	}
+	err = contextErr(ctx, err)
+	span.end("", err)
+	return res, err
 }
 
 // Implement the "ConnPrepareContext" interface
 func (cn *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return cn.config.interceptPrepare(cn.prepareContext)(ctx, query)
+}
+
+func (cn *conn) prepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query, _, err := cn.translateQuery(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
 	if finish := cn.watchCancel(ctx); finish != nil {
 		defer finish()
 	}
-	return cn.Prepare(query)
+	op := TraceOpPrepare
+	if len(query) >= 4 && strings.EqualFold(query[:4], "COPY") {
+		op = TraceOpCopy
+	}
+	span := cn.traceStart(ctx, op, query, 0)
+	st, err := cn.Prepare(query)
+	err = contextErr(ctx, err)
+	if ci, ok := st.(*copyin); ok && err == nil {
+		// the COPY span covers the whole data transfer and ends in Close
+		ci.span = span
+		return st, nil
+	}
+	span.end("", err)
+	return st, err
+}
+
+type deferrableKey struct{}
+
+// WithDeferrable returns a context that makes BeginTx start a DEFERRABLE
+// transaction when the transaction is also serializable and read-only. Such a
+// transaction may block when it starts, but then runs without the risk of
+// serialization failures, which suits long-running reports. It has no effect
+// on other transactions.
+//
+//	tx, err := db.BeginTx(pq.WithDeferrable(ctx), &sql.TxOptions{
+//		Isolation: sql.LevelSerializable,
+//		ReadOnly:  true,
+//	})
+func WithDeferrable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferrableKey{}, true)
 }
 
 // Implement the "ConnBeginTx" interface
@@ -74,70 +162,240 @@ func (cn *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx,
 
 	if opts.ReadOnly {
 		mode += " READ ONLY"
-	} else {
+		if deferrable, _ := ctx.Value(deferrableKey{}).(bool); deferrable &&
+			sql.IsolationLevel(opts.Isolation) == sql.LevelSerializable {
+			mode += " DEFERRABLE"
+		}
+	} else if cn.config.DefaultTransactionReadOnly != "on" {
 		mode += " READ WRITE"
 	}
 
+	span := cn.traceStart(ctx, TraceOpBegin, "", 0)
 	tx, err := cn.begin(mode)
+	span.end("", err)
 	if err != nil {
 		return nil, err
 	}
+	cn.txnCtx = ctx
 	cn.txnFinish = cn.watchCancel(ctx)
 	return tx, nil
 }
 
+// CheckNamedValue implements the "NamedValueChecker" interface. It converts
+// the slices accepted without pq.Array to array literals, passes
+// json.RawMessage values through as they are and leaves other values to
+// driver.DefaultParameterConverter.
+func (cn *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case json.RawMessage:
+		return cn.checkJSON(nv, v)
+	case *json.RawMessage:
+		if v == nil {
+			nv.Value = nil
+			return nil
+		}
+		return cn.checkJSON(nv, *v)
+	}
+	v, ok, err := sliceArray(nv.Value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return driver.ErrSkip
+	}
+	nv.Value = v
+	return nil
+}
+
+// checkJSON sets nv to the JSON document v, or to NULL if v is nil, after
+// checking it is well-formed if Config.ValidateJSON is set.
+func (cn *conn) checkJSON(nv *driver.NamedValue, v json.RawMessage) error {
+	if v == nil {
+		nv.Value = nil
+		return nil
+	}
+	if cn.config.ValidateJSON && !json.Valid(v) {
+		return fmt.Errorf("pq: parameter %d is not a valid JSON document", nv.Ordinal)
+	}
+	nv.Value = v
+	return nil
+}
+
 func (cn *conn) Ping(ctx context.Context) error {
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
 	if finish := cn.watchCancel(ctx); finish != nil {
 		defer finish()
 	}
-	rows, err := cn.simpleQuery(";")
-	if err != nil {
-		return driver.ErrBadConn // https://golang.org/pkg/database/sql/driver/#Pinger
+	if q := cn.config.PingQuery; q != "" {
+		rows, err := cn.simpleQuery(q)
+		if err != nil {
+			return badConn(err) // https://golang.org/pkg/database/sql/driver/#Pinger
+		}
+		if err := rows.Close(); err != nil {
+			return badConn(err)
+		}
+		return nil
+	}
+	// a Sync is answered by ReadyForQuery without running anything, even
+	// in a failed transaction
+	if err := cn.sendSimpleMessage('S'); err != nil {
+		cn.setBadErr(err)
+		return cn.errBadConn()
+	}
+	if err := cn.awaitReadyForQuery(); err != nil {
+		cn.setBadErr(err)
+		return cn.errBadConn()
 	}
-	rows.Close()
 	return nil
 }
 
+// watchCancel sends a cancel request for cn once ctx is done, until the
+// returned function is called. Rather than starting a goroutine for every
+// call, it sets the deadline of the network connection to the one of ctx and
+// registers ctx with the watcher goroutine of cn; a read or write interrupted
+// by either sends the cancel request, see cancelExpired, and carries on, so
+// the statement ends with the server's error. If a cancel request was sent,
+// the returned function resynchronizes the connection, see
+// resyncAfterCancel.
 func (cn *conn) watchCancel(ctx context.Context) func() {
-	if done := ctx.Done(); done != nil {
-		finished := make(chan struct{}, 1)
-		go func() {
-			select {
-			case <-done:
-				select {
-				case finished <- struct{}{}:
-				default:
-					// We raced with the finish func, let the next query handle this with the
-					// context.
-					return
-				}
-
-				// Set the connection state to bad so it does not get reused.
-				cn.setBad()
+	if ctx.Done() == nil {
+		return nil
+	}
+	w := &ctxWatch{ctx: ctx, active: true}
+	cn.watchMu.Lock()
+	cn.watches = append(cn.watches, w)
+	cn.applyDeadlineLocked()
+	cn.watchMu.Unlock()
+	cn.watcher().send(watchMsg{w: w, add: true})
 
-				// At this point the function level context is canceled,
-				// so it must not be used for the additional network
-				// request to cancel the query.
-				// Create a new context to pass into the dial.
-				ctxCancel, cancel := context.WithTimeout(context.Background(), time.Second*10)
-				defer cancel()
-
-				if err := cn.cancel(ctxCancel); err != nil {
-					cn.log(ctx, LogLevelError, fmt.Sprintf("fail to cancel: %v", err), map[string]interface{}{})
-				}
-			case <-finished:
+	return func() {
+		cn.watchMu.Lock()
+		w.active = false
+		for i, x := range cn.watches {
+			if x == w {
+				cn.watches = append(cn.watches[:i], cn.watches[i+1:]...)
+				break
 			}
-		}()
-		return func() {
-			select {
-			case <-finished:
+		}
+		canceled := w.canceled
+		cn.watchMu.Unlock()
+		cn.watcher().send(watchMsg{w: w})
+
+		if canceled {
+			if err := cn.resyncAfterCancel(); err != nil {
+				cn.log(ctx, LogLevelWarn, "cannot resynchronize the connection after a cancel request",
+					map[string]interface{}{"error": err})
 				cn.setBad()
 				cn.Close()
-			case finished <- struct{}{}:
+				return
 			}
 		}
+		cn.watchMu.Lock()
+		cn.applyDeadlineLocked()
+		cn.watchMu.Unlock()
 	}
-	return nil
+}
+
+// resyncAfterCancel makes sure a cancel request sent for cn cannot affect
+// later statements. By the time it is called, the cancelled statement has
+// finished, with or without an error, and its results have been read; the
+// request, though, may still be delivered to the backend. A round trip
+// after the request was sent lets the backend process it, which it ignores
+// if no statement is running.
+func (cn *conn) resyncAfterCancel() error {
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if err := cn.c.SetDeadline(time.Now().Add(cn.cancelTimeout())); err != nil {
+		return err
+	}
+	defer func() { _ = cn.c.SetDeadline(time.Time{}) }()
+	if err := cn.sendSimpleMessage('S'); err != nil {
+		return err
+	}
+	return cn.awaitReadyForQuery()
+}
+
+// cancelTimeout implements Config.CancelTimeout.
+func (cn *conn) cancelTimeout() time.Duration {
+	if d := cn.config.CancelTimeout; d > 0 {
+		return d
+	}
+	if d := cn.config.ConnectTimeout; d > 0 {
+		return d
+	}
+	return time.Second * 10
+}
+
+// awaitReadyForQuery reads the answer to a Sync message.
+func (cn *conn) awaitReadyForQuery() (err error) {
+	var r readBuf
+	for {
+		t, rerr := cn.recv1Buf(&r)
+		if rerr != nil {
+			return rerr
+		}
+		switch t {
+		case 'Z':
+			cn.processReadyForQuery(&r)
+			return err
+		case 'E':
+			err = parseError(&r, cn)
+		default:
+			return fmt.Errorf("unexpected message %q in response to Sync", t)
+		}
+	}
+}
+
+// CancelRequest asks the server to cancel the command currently executing on
+// the given connection. A runtime panic occurs if c is not a pq connection.
+// The connection is not marked bad, and the cancelled command returns an error
+// with code query_canceled. A cancel request that arrives when no command is
+// running has no effect.
+func CancelRequest(ctx context.Context, c driver.Conn) error {
+	return c.(*conn).cancel(ctx)
+}
+
+// CancelFunc returns a function sending a cancel request for the given
+// connection, as CancelRequest does. Unlike the connection itself, the
+// returned function may be retained and called from any goroutine, e.g. one
+// enforcing a custom timeout while a query runs:
+//
+//	var cancel func(context.Context) error
+//	err := sqlConn.Raw(func(driverConn interface{}) error {
+//		cancel = pq.CancelFunc(driverConn.(driver.Conn))
+//		return nil
+//	})
+func CancelFunc(c driver.Conn) func(ctx context.Context) error {
+	return c.(*conn).cancel
+}
+
+// cancelAddr returns the address to send cancel requests to. With the
+// default dialer, that is the address the connection was made to, so the
+// request reaches the same server when the host name resolves to several.
+// Other dialers, e.g. proxies and tunnels, are given the configured host and
+// port, as when connecting.
+func (cn *conn) cancelAddr(ctx context.Context) (network, address string, err error) {
+	if f := cn.config.ResolveCancelAddr; f != nil {
+		return f(ctx, cn.connInfo())
+	}
+	if a := cn.remoteAddr; a != nil && a.String() != "" && isDefaultDialFunc(cn.dialFunc()) {
+		return a.Network(), a.String(), nil
+	}
+	network, address = NetworkAddress(cn.fallbackConfig.Host, cn.fallbackConfig.Port)
+	return network, address, nil
+}
+
+// defaultDialFuncPC identifies the DialContext method of net.Dialer, which
+// ParseConfig uses as the DialFunc.
+var defaultDialFuncPC = reflect.ValueOf((&net.Dialer{}).DialContext).Pointer()
+
+// isDefaultDialFunc reports whether f is the DialContext method of a
+// net.Dialer, which connects to exactly the address it is given.
+func isDefaultDialFunc(f DialFunc) bool {
+	return f != nil && reflect.ValueOf(f).Pointer() == defaultDialFuncPC
 }
 
 func (cn *conn) cancel(ctx context.Context) error {
@@ -146,8 +404,24 @@ func (cn *conn) cancel(ctx context.Context) error {
 	// cause a concurrent map write panic. This is necessary because cancel
 	// is called from a goroutine in watchCancel.
 
-	network, address := NetworkAddress(cn.fallbackConfig.Host, cn.fallbackConfig.Port)
-	c, err := cn.config.DialFunc(ctx, network, address)
+	network, address, err := cn.cancelAddr(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot resolve cancel address: %w", err)
+	}
+	return sendCancelRequest(ctx, cn.dialFunc(), network, address, cn.fallbackConfig.TLSConfig,
+		cn.processID, cn.secretKey, func() {
+			cn.config.Stats.cancelRequestSent()
+			if f := cn.config.OnCancel; f != nil {
+				f(cn.connInfo())
+			}
+		})
+}
+
+// sendCancelRequest sends a cancel request for the backend with the given
+// process ID and secret key to address, calling sent once it is written.
+func sendCancelRequest(ctx context.Context, dial DialFunc, network, address string, tlsConfig *tls.Config,
+	processID, secretKey int, sent func()) error {
+	c, err := dial(ctx, network, address)
 	if err != nil {
 		return fmt.Errorf("fail to dail: %w", err)
 	}
@@ -160,19 +434,23 @@ func (cn *conn) cancel(ctx context.Context) error {
 			c:   c,
 			bad: bad,
 		}
-		err = can.startTLS(cn.fallbackConfig.TLSConfig)
-		if err != nil {
-			return fmt.Errorf("cannot start TLS: %w", err)
+		if tlsConfig != nil {
+			if err = can.startTLS(tlsConfig); err != nil {
+				return fmt.Errorf("cannot start TLS: %w", err)
+			}
 		}
 
 		w := can.writeBuf(0)
 		w.int32(80877102) // cancel request code
-		w.int32(cn.processID)
-		w.int32(cn.secretKey)
+		w.int32(processID)
+		w.int32(secretKey)
 
 		if err = can.sendStartupPacket(w); err != nil {
 			return fmt.Errorf("canot send startup packet: %w", err)
 		}
+		if sent != nil {
+			sent()
+		}
 	}
 
 	// Read until EOF to ensure that the server received the cancel.
@@ -186,65 +464,69 @@ func (cn *conn) cancel(ctx context.Context) error {
 
 // Implement the "StmtQueryContext" interface
 func (st *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if hasNamedArgs(args) {
+		return nil, errNamedArgsStmt
+	}
 	list := make([]driver.Value, len(args))
 	for i, nv := range args {
 		list[i] = nv.Value
 	}
+	span := st.cn.traceStart(ctx, TraceOpQuery, st.sql, len(args))
+	restore, err := st.cn.setDeadlineTimeout(ctx)
+	if err != nil {
+		span.end("", err)
+		return nil, err
+	}
 	finish := st.watchCancel(ctx)
 	r, err := st.query(list)
 	if err != nil {
 		if finish != nil {
 			finish()
 		}
+		restore()
+		err = contextErr(ctx, err)
+		span.end("", err)
 		return nil, err
 	}
-	r.finish = finish
+	r.finish = func() {
+		if finish != nil {
+			finish()
+		}
+		restore()
+	}
+	r.span = span
 	return r, nil
 }
 
 // Implement the "StmtExecContext" interface
 func (st *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if hasNamedArgs(args) {
+		return nil, errNamedArgsStmt
+	}
 	list := make([]driver.Value, len(args))
 	for i, nv := range args {
 		list[i] = nv.Value
 	}
 
+	restore, err := st.cn.setDeadlineTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
 	if finish := st.watchCancel(ctx); finish != nil {
 		defer finish()
 	}
 
-	return st.Exec(list)
+	span := st.cn.traceStart(ctx, TraceOpExec, st.sql, len(args))
+	res, err := st.Exec(list)
+	err = contextErr(ctx, err)
+	span.end("", err)
+	return res, err
 }
 
-// watchCancel is implemented on stmt in order to not mark the parent conn as bad
 func (st *stmt) watchCancel(ctx context.Context) func() {
-	if done := ctx.Done(); done != nil {
-		finished := make(chan struct{})
-		go func() {
-			select {
-			case <-done:
-				// At this point the function level context is canceled,
-				// so it must not be used for the additional network
-				// request to cancel the query.
-				// Create a new context to pass into the dial.
-				ctxCancel, cancel := context.WithTimeout(context.Background(), time.Second*10)
-				defer cancel()
-
-				if err := st.cancel(ctxCancel); err != nil {
-					st.cn.log(ctx, LogLevelError, fmt.Sprintf("fail to cancel: %v", err), map[string]interface{}{})
-				}
-				finished <- struct{}{}
-			case <-finished:
-			}
-		}()
-		return func() {
-			select {
-			case <-finished:
-			case finished <- struct{}{}:
-			}
-		}
-	}
-	return nil
+	return st.cn.watchCancel(ctx)
 }
 
 func (st *stmt) cancel(ctx context.Context) error {
@@ -8,6 +8,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net"
@@ -16,6 +17,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -113,6 +115,8 @@ func (d defaultDialer) DialContext(ctx context.Context, network, address string)
 type Connector struct {
 	dialer connectorDialer
 	config *Config
+	// stops the goroutine refreshing the standbys, if any
+	stop context.CancelFunc
 }
 
 // Open opens a new connection to the database. dsn is a connection string.
@@ -128,6 +132,7 @@ func DialOpen(dsn string) (_ driver.Conn, err error) {
 	if err != nil {
 		return nil, err
 	}
+	defer c.Close()
 	return c.open(context.Background())
 }
 
@@ -143,15 +148,37 @@ func NewConnector(dsn string) (*Connector, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewConnectorConfig(cfg, distCfg)
+}
+
+// NewConnectorConfig returns a connector for the pq driver using a config
+// created by ParseConfig. It allows fields that cannot be expressed in a
+// connection string, such as Tracer, to be set before any connection is
+// established. distCfg may be nil, in which case no load balancing is used.
+func NewConnectorConfig(cfg *Config, distCfg *DistConfig) (*Connector, error) {
+	if !cfg.createdByParseConfig {
+		return nil, errors.New("config must be created by ParseConfig")
+	}
+	if distCfg == nil {
+		distCfg = &DistConfig{}
+	}
 
+	var err error
 	var balancer cnsBalancer
 	balPol := distCfg.balancePolicy
 	cn := &Connector{config: cfg}
 
 	if balPol == balanceNone { // single 模式
-		cn.dialer = &singleDialer{
+		sd := &singleDialer{
 			dialer: defaultDialer{},
 		}
+		cn.dialer = sd
+		if distCfg.refreshHostsInterval > 0 {
+			var ctx context.Context
+			ctx, cn.stop = context.WithCancel(context.Background())
+			sd.discovered = &discoveredHosts{}
+			go sd.discovered.refreshHosts(ctx, sql.OpenDB(cn), cfg, distCfg.refreshHostsInterval)
+		}
 		return cn, nil
 	}
 
@@ -188,6 +215,8 @@ func NewConnector(dsn string) (*Connector, error) {
 		logger:                cfg.Logger,
 		logLevel:              cfg.LogLevel,
 		tlsCfgs:               distCfg.tlsCfgs,
+		cnRetries:             distCfg.cnRetries,
+		cnRetryInterval:       distCfg.cnRetryInterval,
 	}
 
 	db := sql.OpenDB(cn) // TODO: move into refreshCNs method
@@ -223,6 +252,16 @@ func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
 	return c.open(ctx)
 }
 
+// Close stops refreshing the standbys found with refreshHostsInterval.
+// database/sql calls it when the DB opened with the connector is closed, so
+// the connector must not be shared by several DBs then.
+func (c *Connector) Close() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	return nil
+}
+
 // Driver returns the underlying driver of this Connector.
 func (c *Connector) Driver() driver.Driver {
 	return &Driver{}
@@ -232,7 +271,14 @@ func (c *Connector) open(ctx context.Context) (cn *conn, err error) {
 	if !c.config.createdByParseConfig {
 		return nil, errors.New("config must be created by ParseConfig")
 	}
-	return c.dialer.dial(ctx, c.config)
+	span := startSpan(ctx, c.config, c.config.Host, c.config.Port, TraceOpConnect, "", 0)
+	cn, err = c.dialer.dial(ctx, c.config)
+	span.end("", err)
+	c.config.Stats.connectionOpened(err)
+	if err == nil {
+		cn.connected(ctx)
+	}
+	return cn, err
 }
 
 type connectorDialer interface {
@@ -241,6 +287,9 @@ type connectorDialer interface {
 
 type singleDialer struct {
 	dialer Dialer
+
+	// standbys found by refreshHosts, nil if discovery is disabled
+	discovered *discoveredHosts
 }
 
 func (s *singleDialer) dial(ctx context.Context, config *Config) (cn *conn, err error) {
@@ -257,9 +306,15 @@ func (s *singleDialer) dial(ctx context.Context, config *Config) (cn *conn, err
 			Host:      config.Host,
 			Port:      config.Port,
 			TLSConfig: config.TLSConfig,
+			HostAddr:  config.HostAddr,
 		},
 	}
 	fallbackConfigs = append(fallbackConfigs, config.Fallbacks...)
+	if s.discovered != nil {
+		fallbackConfigs = s.discovered.appendNew(fallbackConfigs)
+	}
+	fallbackConfigs = foldSSLFallbacks(fallbackConfigs)
+	orderHosts(config.LoadBalanceHosts, fallbackConfigs)
 
 	fallbackConfigs, err = expandWithIPs(ctx, config.LookupFunc, fallbackConfigs)
 	if err != nil {
@@ -334,46 +389,150 @@ func (s *singleDialer) dial(ctx context.Context, config *Config) (cn *conn, err
 	return cn, nil
 }
 
-func connectFallbackConfig(ctx context.Context, config *Config, fallbackConfig *FallbackConfig) (cn *conn, err error) {
+// connectFallbackConfig connects to the host of fallbackConfig. With sslmode
+// allow and prefer, a connection failing to negotiate TLS, e.g. because
+// pg_hba.conf requires or rejects TLS for the client, is made again with the
+// other TLS setting before the next host is tried, as libpq does. Failures
+// once authentication started are not retried, so that a wrong password does
+// not count twice toward the account lockout of the server.
+func connectFallbackConfig(ctx context.Context, config *Config, fallbackConfig *FallbackConfig) (*conn, error) {
+	for {
+		cn, dialed, err := connectFallbackConfigTLS(ctx, config, fallbackConfig)
+		if err == nil || dialed == nil || ctx.Err() != nil || isAuthError(err) {
+			return cn, err
+		}
+		// with happy eyeballs, a failure once connected moves on to the
+		// other addresses, as when they are dialed one by one
+		fc := *fallbackConfig
+		fc.addrs = removeAddr(fallbackConfig.addrs, dialed)
+		if len(fc.addrs) == 0 || len(fc.addrs) == len(fallbackConfig.addrs) {
+			return cn, err
+		}
+		config.Log(ctx, LogLevelDebug, fmt.Sprintf(
+			"%s, trying the other addresses of %v", err.Error(), fallbackConfig.Host),
+			map[string]interface{}{})
+		fallbackConfig = &fc
+	}
+}
+
+// isAuthError reports whether err is the server rejecting the password or
+// the user, which other addresses of the same host would do as well.
+func isAuthError(err error) bool {
+	var pgErr *Error
+	return errors.As(err, &pgErr) && (pgErr.Code == "28P01" || pgErr.Code == "28000")
+}
+
+// removeAddr returns addrs without the IP address of a.
+func removeAddr(addrs []string, a net.Addr) []string {
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return addrs
+	}
+	ip := net.ParseIP(host)
+	rest := make([]string, 0, len(addrs))
+	for _, s := range addrs {
+		if !net.ParseIP(s).Equal(ip) {
+			rest = append(rest, s)
+		}
+	}
+	return rest
+}
+
+// connectFallbackConfigTLS connects to the host of fallbackConfig, with the
+// other TLS setting too if needed, see connectFallbackConfig. dialed is the
+// address of the last connection failing after happy eyeballs dialing.
+func connectFallbackConfigTLS(ctx context.Context, config *Config, fallbackConfig *FallbackConfig) (cn *conn, dialed net.Addr, err error) {
+	cn, dialed, tlsFailed, err := connectFallbackConfigOnce(ctx, config, fallbackConfig)
+	retry := fallbackConfig.sslRetry
+	if err == nil || !tlsFailed || retry == nil {
+		return cn, dialed, err
+	}
+	mode := "without TLS"
+	if retry.TLSConfig != nil {
+		mode = "with TLS"
+	}
+	config.Log(ctx, LogLevelDebug, fmt.Sprintf(
+		"%s, retrying %v:%v %s",
+		err.Error(),
+		fallbackConfig.Host,
+		fallbackConfig.Port,
+		mode),
+		map[string]interface{}{})
+	fc := *fallbackConfig
+	fc.TLSConfig = retry.TLSConfig
+	fc.sslRetry = nil
+	cn, dialed, _, err = connectFallbackConfigOnce(ctx, config, &fc)
+	return cn, dialed, err
+}
+
+// connectFallbackConfigOnce connects to the host of fallbackConfig. tlsFailed
+// reports whether the connection failed negotiating the TLS setting of
+// fallbackConfig: the TLS handshake failed, or the server refused the
+// connection before authentication, so that trying the other setting may
+// succeed. dialed is the address connected to if the connection failed
+// afterwards and was one of fallbackConfig.addrs.
+func connectFallbackConfigOnce(ctx context.Context, config *Config, fallbackConfig *FallbackConfig) (cn *conn, dialed net.Addr, tlsFailed bool, err error) {
 	cn = &conn{
 		config:         config,
 		logLevel:       config.LogLevel,
 		logger:         config.Logger,
 		fallbackConfig: fallbackConfig,
+		wireTrace:      newWireTracer(config.WireTrace),
+		bad:            &atomic.Value{},
 	}
+	cn.bad.Store(false)
 	cn.log(ctx, LogLevelInfo, fmt.Sprintf(
 		"Dialing server: (%v:%v)",
 		fallbackConfig.Host,
 		fallbackConfig.Port),
 		map[string]interface{}{})
-	network, address := NetworkAddress(fallbackConfig.Host, fallbackConfig.Port)
-	cn.c, err = config.DialFunc(ctx, network, address) // exact establish net connection
+	if len(fallbackConfig.addrs) > 0 {
+		cn.c, err = dialHappyEyeballs(ctx, cn.dialFunc(), fallbackConfig.addrs, fallbackConfig.Port)
+	} else {
+		network, address := NetworkAddress(fallbackConfig.Host, fallbackConfig.Port)
+		cn.c, err = cn.dialFunc()(ctx, network, address) // exact establish net connection
+	}
 	if err != nil {
-		return nil, &connectError{config: config, msg: "dial error", err: err}
+		return nil, nil, false, &connectError{config: config, msg: "dial error", err: err}
+	}
+	cn.remoteAddr = cn.c.RemoteAddr()
+	if len(fallbackConfig.addrs) > 0 {
+		dialed = cn.remoteAddr
 	}
+	cn.c = config.Stats.wrapConn(cn.c)
+	cn.wrapTimeouts()
 	if fallbackConfig.TLSConfig != nil {
-		if err := cn.startTLS(fallbackConfig.TLSConfig); err != nil {
+		err := cn.startTLS(fallbackConfig.TLSConfig)
+		if err == ErrSSLNotSupported && fallbackConfig.sslRetry != nil && fallbackConfig.sslRetry.TLSConfig == nil {
+			// sslmode=prefer: go on without TLS on the same connection,
+			// which need not be retried
+			fc := *fallbackConfig
+			fc.TLSConfig = nil
+			fc.sslRetry = nil
+			cn.fallbackConfig = &fc
+		} else if err != nil {
 			if err := cn.c.Close(); err != nil {
-				return nil, &connectError{config: config, msg: "close connect error", err: err}
+				return nil, dialed, true, &connectError{config: config, msg: "close connect error", err: err}
 			}
-			return nil, &connectError{config: config, msg: "tls error", err: err}
+			return nil, dialed, true, &connectError{config: config, msg: "tls error", err: err}
 		}
 	}
 
-	cn.buf = bufio.NewReader(cn.c)
-	if err = cn.startup(); err != nil {
+	cn.c = &watchedConn{Conn: cn.c, cn: cn}
+	cn.buf = bufio.NewReaderSize(cn.c, cn.readBufferSize())
+	if err = cn.startup(ctx); err != nil {
 		_ = cn.Close()
-		return nil, fmt.Errorf("fail to startup: %w", err)
+		return nil, dialed, !cn.authStarted && cn.fallbackConfig == fallbackConfig, fmt.Errorf("fail to startup: %w", err)
 	}
 
 	// reset the deadline, in case one was set (see dial)
 	if config.ConnectTimeout.Seconds() > 0 {
 		if err = cn.c.SetDeadline(time.Time{}); err != nil {
 			_ = cn.Close()
-			return nil, fmt.Errorf("cannot set deadline: %w", err)
+			return nil, dialed, false, fmt.Errorf("cannot set deadline: %w", err)
 		}
 	}
-	return cn, err
+	return cn, nil, false, nil
 }
 
 type validateError string
@@ -450,16 +609,46 @@ func deepCopyCnsInPrimaryCluster(ctx context.Context, cfg *Config, usingEip bool
 	return urlCNs, nil
 }
 
+// foldSSLFallbacks attaches the configs ParseConfig adds for the second
+// attempt of sslmode allow and prefer to the config they retry, so that
+// both attempts are made at an address before the next one is tried, and
+// the second only if the server was reached.
+func foldSSLFallbacks(fallbacks []*FallbackConfig) []*FallbackConfig {
+	folded := make([]*FallbackConfig, 0, len(fallbacks))
+	for _, fb := range fallbacks {
+		if n := len(folded); fb.sslFallback && n > 0 && folded[n-1].sslRetry == nil {
+			prev := *folded[n-1]
+			prev.sslRetry = fb
+			folded[n-1] = &prev
+			continue
+		}
+		folded = append(folded, fb)
+	}
+	return folded
+}
+
 func expandWithIPs(ctx context.Context, lookupFn LookupFunc, fallbacks []*FallbackConfig) ([]*FallbackConfig, error) {
 	var configs []*FallbackConfig
 
 	for _, fb := range fallbacks {
-		// skip resolve for unix sockets
-		if strings.HasPrefix(fb.Host, "/") {
+		if fb.HostAddr != "" {
+			configs = append(configs, &FallbackConfig{
+				Host:      fb.HostAddr,
+				Port:      fb.Port,
+				TLSConfig: fb.TLSConfig,
+				DialFunc:  fb.DialFunc,
+				sslRetry:  fb.sslRetry,
+			})
+			continue
+		}
+		// skip resolve for unix sockets and hosts with their own dialer
+		if strings.HasPrefix(fb.Host, "/") || fb.DialFunc != nil {
 			configs = append(configs, &FallbackConfig{
 				Host:      fb.Host,
 				Port:      fb.Port,
 				TLSConfig: fb.TLSConfig,
+				DialFunc:  fb.DialFunc,
+				sslRetry:  fb.sslRetry,
 			})
 
 			continue
@@ -470,11 +659,22 @@ func expandWithIPs(ctx context.Context, lookupFn LookupFunc, fallbacks []*Fallba
 			return nil, err
 		}
 
+		if addrs := interleaveAddrFamilies(ips); addrs != nil {
+			configs = append(configs, &FallbackConfig{
+				Host:      fb.Host,
+				Port:      fb.Port,
+				TLSConfig: fb.TLSConfig,
+				addrs:     addrs,
+				sslRetry:  fb.sslRetry,
+			})
+			continue
+		}
 		for _, ip := range ips {
 			configs = append(configs, &FallbackConfig{
 				Host:      ip,
 				Port:      fb.Port,
 				TLSConfig: fb.TLSConfig,
+				sslRetry:  fb.sslRetry,
 			})
 		}
 	}
@@ -489,6 +689,9 @@ type distributeDialer struct {
 	coordinateNodes       []coordinateNode
 	cnsBalancer           cnsBalancer
 
+	cnRetries       int
+	cnRetryInterval time.Duration
+
 	tlsCfgs []*tls.Config
 	dialer  Dialer
 
@@ -571,32 +774,48 @@ func (d *distributeDialer) dial(ctx context.Context, cfg *Config) (*conn, error)
 			err: errors.New("ip addr wasn't found")}
 	}
 	cn := &conn{}
-	for _, cNode := range coorNodes { // TODO: fetch with singleDialer.dial
-		cfg.Host = cNode.ip
-		cfg.Port = cNode.port
-		cn, err = connectCNodeConfig(ctx, cfg, cNode) // TODO: refactor error handling
-		if err != nil {
-			if pgErr, ok := err.(*Error); ok {
-				err = &connectError{config: cfg, msg: "server error", err: pgErr}
-				ErrCodeInvalidPassword := "28P01"                   // worng password
-				ErrCodeInvalidAuthorizationSpecification := "28000" // db does not exist
-				if pgErr.Code.String() == ErrCodeInvalidPassword ||
-					pgErr.Code.String() == ErrCodeInvalidAuthorizationSpecification {
-					break
+	backoff := d.cnRetryInterval
+	for attempt := 0; ; attempt++ {
+		for _, cNode := range coorNodes { // TODO: fetch with singleDialer.dial
+			cfg.Host = cNode.ip
+			cfg.Port = cNode.port
+			cn, err = connectCNodeConfig(ctx, cfg, cNode) // TODO: refactor error handling
+			if err != nil {
+				if pgErr, ok := err.(*Error); ok {
+					err = &connectError{config: cfg, msg: "server error", err: pgErr}
+					ErrCodeInvalidPassword := "28P01"                   // worng password
+					ErrCodeInvalidAuthorizationSpecification := "28000" // db does not exist
+					if pgErr.Code.String() == ErrCodeInvalidPassword ||
+						pgErr.Code.String() == ErrCodeInvalidAuthorizationSpecification {
+						break
+					}
 				}
+				cfg.Log(context.Background(), LogLevelInfo, fmt.Sprintf(
+					"fail to dial: %v, host: (%v:%v)",
+					err,
+					cNode.ip,
+					cNode.port),
+					map[string]interface{}{})
+				continue
 			}
-			cfg.Log(context.Background(), LogLevelInfo, fmt.Sprintf(
-				"fail to dial: %v, host: (%v:%v)",
-				err,
-				cNode.ip,
-				cNode.port),
+			cfg.Log(context.Background(), LogLevelDebug,
+				fmt.Sprintf("find instance: (%v:%v)", cNode.ip, cNode.port),
 				map[string]interface{}{})
-			continue
+			break
+		}
+		if err == nil || attempt >= d.cnRetries || !isCNUnavailable(err) {
+			break
+		}
+		d.Log(ctx, LogLevelWarn, "no CN is available, retrying",
+			map[string]interface{}{"attempt": attempt + 1, "backoff": backoff, "err": err})
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, &connectError{config: cfg, msg: "no CN is available", err: err}
+		}
+		if backoff *= 2; backoff > maxCNRetryInterval {
+			backoff = maxCNRetryInterval
 		}
-		cfg.Log(context.Background(), LogLevelDebug,
-			fmt.Sprintf("find instance: (%v:%v)", cNode.ip, cNode.port),
-			map[string]interface{}{})
-		break
 	}
 	if err != nil {
 		return nil, err // no need to wrap in connectError because it will already be wrapped in all cases except PgError
@@ -606,6 +825,33 @@ func (d *distributeDialer) dial(ctx context.Context, cfg *Config) (*conn, error)
 	return cn, nil
 }
 
+const (
+	defaultCNRetryInterval = time.Second
+	maxCNRetryInterval     = 30 * time.Second
+)
+
+// isCNUnavailable reports whether err, returned when connecting to a CN,
+// means that the CN cannot be connected to for now, e.g. because it is
+// restarting, rather than that the connection would be refused anyway.
+func isCNUnavailable(err error) bool {
+	var pgErr *Error
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03", // cannot_connect_now
+			"08000", // connection_exception
+			"08001", // sqlclient_unable_to_establish_sqlconnection
+			"08006": // connection_failure
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 func removeDuplicateNodes(a []coordinateNode) (ret []coordinateNode) { // todo: optimise
 	n := len(a)
 	for i := 0; i < n; i++ {
@@ -711,7 +957,10 @@ func connectCNodeConfig(ctx context.Context, cfg *Config, cNode coordinateNode)
 		logLevel:       cfg.LogLevel,
 		logger:         cfg.Logger,
 		fallbackConfig: bckCfg,
+		wireTrace:      newWireTracer(cfg.WireTrace),
+		bad:            &atomic.Value{},
 	}
+	cn.bad.Store(false)
 	cn.log(ctx, LogLevelInfo,
 		fmt.Sprintf("Dialing server: (%v:%v)", bckCfg.Host, bckCfg.Port),
 		map[string]interface{}{})
@@ -720,8 +969,11 @@ func connectCNodeConfig(ctx context.Context, cfg *Config, cNode coordinateNode)
 	var err error
 	cn.c, err = cfg.DialFunc(ctx, network, address) // exactly establish connection
 	if err != nil {
-		return nil, &connectError{config: cfg, msg: fmt.Sprintf("dial error: %v", err), err: driver.ErrBadConn}
+		return nil, &connectError{config: cfg, msg: "dial error", err: badConn(err)}
 	}
+	cn.remoteAddr = cn.c.RemoteAddr()
+	cn.c = cfg.Stats.wrapConn(cn.c)
+	cn.wrapTimeouts()
 	if bckCfg.TLSConfig != nil {
 		if err = cn.startTLS(bckCfg.TLSConfig); err != nil {
 			if err = cn.c.Close(); err != nil {
@@ -731,8 +983,9 @@ func connectCNodeConfig(ctx context.Context, cfg *Config, cNode coordinateNode)
 		}
 	}
 
-	cn.buf = bufio.NewReader(cn.c)
-	if err = cn.startup(); err != nil {
+	cn.c = &watchedConn{Conn: cn.c, cn: cn}
+	cn.buf = bufio.NewReaderSize(cn.c, cn.readBufferSize())
+	if err = cn.startup(ctx); err != nil {
 		_ = cn.Close()
 		return nil, fmt.Errorf("fail to startup: %w", err)
 	}
@@ -793,8 +1046,8 @@ func expandWithIPsDist(ctx context.Context, lookupFn LookupFunc, cNodes []coordi
 		if err != nil {
 			return nil, &connectError{
 				config: nil,
-				msg:    fmt.Sprintf("fail to look up: %v", err),
-				err:    driver.ErrBadConn,
+				msg:    "fail to look up",
+				err:    badConn(err),
 			}
 		}
 		for _, ip := range ips {
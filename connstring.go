@@ -0,0 +1,238 @@
+package pq
+
+import (
+	"encoding/base64"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redactedPassword replaces passwords in the output of Config.String.
+const redactedPassword = "xxxxx"
+
+// ConnString returns a keyword/value connection string which ParseConfig
+// turns into a config equivalent to c, including changes made to its fields
+// after parsing. Settings that only exist as Go values, such as DialFunc,
+// Tracer or a TLSConfig built by hand, cannot be represented and are left
+// out; TLS is described by the ssl settings c was parsed from.
+//
+// The result contains the password in clear text. Use String to log a
+// config.
+func (c *Config) ConnString() string {
+	return formatKeywordValue(c.connSettings(false))
+}
+
+// ConnURL is like ConnString, but returns an opengauss:// URL.
+func (c *Config) ConnURL() string {
+	settings := c.connSettings(false)
+	u := url.URL{Scheme: "opengauss", Path: "/" + settings["database"]}
+	if user, password := settings["user"], settings["password"]; password != "" {
+		u.User = url.UserPassword(user, password)
+	} else if user != "" {
+		u.User = url.User(user)
+	}
+	delete(settings, "user")
+	delete(settings, "password")
+	delete(settings, "database")
+
+	hosts, ports := strings.Split(settings["host"], ","), strings.Split(settings["port"], ",")
+	if !strings.HasPrefix(settings["host"], "/") && len(hosts) == len(ports) {
+		addrs := make([]string, len(hosts))
+		for i := range hosts {
+			addrs[i] = net.JoinHostPort(hosts[i], ports[i])
+		}
+		u.Host = strings.Join(addrs, ",")
+		delete(settings, "host")
+		delete(settings, "port")
+	}
+
+	q := url.Values{}
+	for k, v := range settings {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// String returns ConnString with passwords redacted, so that configs can be
+// logged.
+func (c *Config) String() string {
+	return formatKeywordValue(c.connSettings(true))
+}
+
+// connSettingsFromFields are the settings held in Config fields, which take
+// precedence over the parsed settings.
+var connSettingsFromFields = []string{
+	"host", "hostaddr", "port", "database", "user", "password", "connect_timeout",
+	"enable_ce", "localkms_file_path", "auto_sendtoken", "loggerLevel",
+	"search_path", "bytea_output", "scan_location", "dbcompatibility", "placeholder_format",
+	"statement_timeout", "lock_timeout", "idle_in_transaction_session_timeout",
+	"deadline_statement_timeout", "slow_query_threshold", "timestamp_rounding",
+	"allow_multiple_statements", "default_transaction_isolation", "default_transaction_read_only",
+	"client_min_messages", "notice_min_severity", "send_connection_info", "connectionExtraInfo",
+}
+
+func (c *Config) connSettings(redact bool) map[string]string {
+	settings := make(map[string]string)
+	for k, v := range c.settings {
+		if _, ok := notRuntimeParams[k]; ok {
+			settings[k] = v
+		}
+	}
+	for _, k := range connSettingsFromFields {
+		delete(settings, k)
+	}
+	if v, ok := settings["sslpassword"]; ok {
+		settings["sslpassword"] = decodePassword(v)
+	}
+	for k, v := range c.RuntimeParams {
+		if _, ok := notRuntimeParams[k]; ok || strings.HasPrefix(k, runtimeParamPrefix) {
+			k = runtimeParamPrefix + k
+		}
+		settings[k] = v
+	}
+
+	var hosts, ports, hostaddrs []string
+	hasHostaddr := false
+	addHost := func(host string, port uint16, hostaddr string) {
+		p := strconv.Itoa(int(port))
+		// fallbacks differing only in TLS configuration share an address
+		if n := len(hosts); n > 0 && hosts[n-1] == host && ports[n-1] == p && hostaddrs[n-1] == hostaddr {
+			return
+		}
+		hosts, ports, hostaddrs = append(hosts, host), append(ports, p), append(hostaddrs, hostaddr)
+		hasHostaddr = hasHostaddr || hostaddr != ""
+	}
+	addHost(c.Host, c.Port, c.HostAddr)
+	for _, f := range c.Fallbacks {
+		addHost(f.Host, f.Port, f.HostAddr)
+	}
+	settings["host"] = strings.Join(hosts, ",")
+	settings["port"] = strings.Join(ports, ",")
+	if hasHostaddr {
+		settings["hostaddr"] = strings.Join(hostaddrs, ",")
+	}
+
+	set := func(k, v string) {
+		if v != "" {
+			settings[k] = v
+		}
+	}
+	set("database", c.Database)
+	set("user", c.User)
+	if c.Password != "" {
+		settings["password"] = decodePassword(c.Password)
+	}
+	if c.ConnectTimeout > 0 {
+		settings["connect_timeout"] = strconv.Itoa(int(c.ConnectTimeout / time.Second))
+	}
+	set("enable_ce", c.EnableClientEncryption)
+	set("localkms_file_path", c.LocalKMSFilePath)
+	if c.EnableAutoSendToken {
+		settings["auto_sendtoken"] = "true"
+	}
+	if c.LogLevel != LogLevelError {
+		settings["loggerLevel"] = c.LogLevel.String()
+	}
+	set("search_path", c.SearchPath)
+	set("bytea_output", c.ByteaOutput)
+	set("default_transaction_isolation", c.DefaultTransactionIsolation)
+	set("default_transaction_read_only", c.DefaultTransactionReadOnly)
+	set("client_min_messages", c.ClientMinMessages)
+	set("notice_min_severity", c.MinNoticeSeverity)
+	if c.ScanLocation != nil {
+		settings["scan_location"] = c.ScanLocation.String()
+	}
+	set("dbcompatibility", c.DBCompatibility)
+	set("compression", c.Compression)
+	set("replication", c.Replication)
+	set("ping_query", c.PingQuery)
+	if len(c.ConnectionInfo) > 0 {
+		settings["send_connection_info"] = "true"
+	}
+	if _, ok := c.ConnectionInfo["os_user"]; ok {
+		settings["connectionExtraInfo"] = "true"
+	}
+	set("placeholder_format", string(c.PlaceholderFormat))
+	set("timestamp_rounding", string(c.TimestampRounding))
+	set("sql_comment", string(c.SQLComment))
+	set("last_insert_id_column", c.LastInsertIDColumn)
+	set("loadBalanceHosts", string(c.LoadBalanceHosts))
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"cancel_timeout", c.CancelTimeout},
+		{"statement_timeout", c.StatementTimeout},
+		{"lock_timeout", c.LockTimeout},
+		{"idle_in_transaction_session_timeout", c.IdleInTransactionSessionTimeout},
+		{"slow_query_threshold", c.SlowQueryThreshold},
+		{"heartbeatPeriod", c.HeartbeatPeriod},
+		{"idle_keepalive", c.IdleKeepalive},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+	} {
+		if d.d > 0 {
+			settings[d.name] = d.d.String()
+		}
+	}
+	if c.DeadlineStatementTimeout {
+		settings["deadline_statement_timeout"] = "true"
+	}
+	if c.ReWriteBatchedInserts {
+		settings["reWriteBatchedInserts"] = "true"
+	}
+	if c.DisableSASLprep {
+		settings["disable_saslprep"] = "true"
+	}
+	if c.RejectMultipleStatements {
+		settings["allow_multiple_statements"] = "false"
+	}
+
+	if redact {
+		for _, k := range []string{"password", "sslpassword"} {
+			if _, ok := settings[k]; ok {
+				settings[k] = redactedPassword
+			}
+		}
+	}
+	return settings
+}
+
+// decodePassword reverses the encoding applied to passwords by ParseConfig.
+func decodePassword(s string) string {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+func formatKeywordValue(settings map[string]string) string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		v := settings[k]
+		if v != "" && !strings.ContainsAny(v, " \t\n\r\v\f'\\") {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteByte('\'')
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v))
+		b.WriteByte('\'')
+	}
+	return b.String()
+}
@@ -55,6 +55,9 @@ type copyin struct {
 
 	closed bool
 
+	// span started by PrepareContext, ended once the COPY completes
+	span *traceSpan
+
 	sync.Mutex // guards err
 	err        error
 }
@@ -68,6 +71,10 @@ func (cn *conn) prepareCopyIn(q string) (_ driver.Stmt, err error) { // TODO: na
 	if !cn.isInTransaction() {
 		return nil, errCopyNotSupportedOutsideTxn
 	}
+	cn.stmtQuery = q
+	if q, err = cn.clientString(q); err != nil {
+		return nil, err
+	}
 
 	ci := &copyin{
 		cn:      cn,
@@ -124,6 +131,8 @@ awaitCopyInResponse:
 		case 'H': // CopyOutResponse
 			err = errCopyToNotSupported
 			break awaitCopyInResponse
+		case 'W': // CopyBothResponse
+			return nil, cn.abortCopyBoth()
 		case 'E': // ErrorResponse
 			err = parseError(r, cn)
 		case 'Z': // ReadyForQuery
@@ -186,11 +195,13 @@ func (ci *copyin) flush(buf []byte) error {
 	}
 	// set message length (without message identifier)
 	binary.BigEndian.PutUint32(buf[1:], uint32(len(buf)-1))
+	ci.cn.traceFrontend(buf)
 
 	if _, err := ci.cn.c.Write(buf); err != nil {
+		ci.cn.setBadErr(err)
 		return connErr{
-			msg: fmt.Sprintf("fail to write: %v", err),
-			err: driver.ErrBadConn,
+			msg: "fail to write",
+			err: badConn(err),
 		}
 	}
 
@@ -220,9 +231,7 @@ func (ci *copyin) resploop() error {
 			}
 			ci.setResult(res)
 		case 'N':
-			if n := ci.cn.noticeHandler; n != nil {
-				n(parseError(&r, ci.cn))
-			}
+			ci.cn.handleNotice(&r)
 		case 'Z':
 			ci.cn.processReadyForQuery(&r)
 			ci.done <- true
@@ -306,7 +315,7 @@ func (ci *copyin) Exec(v []driver.Value) (r driver.Result, err error) {
 	}
 
 	if ci.isBad() {
-		return nil, driver.ErrBadConn
+		return nil, ci.cn.errBadConn()
 	}
 
 	if ci.isErrorSet() {
@@ -350,9 +359,10 @@ func (ci *copyin) Close() (err error) {
 		return nil
 	}
 	ci.closed = true
+	defer func() { ci.span.end("", err) }()
 
 	if ci.isBad() {
-		return driver.ErrBadConn
+		return ci.cn.errBadConn()
 	}
 
 	if len(ci.buffer) > 0 {
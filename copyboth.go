@@ -0,0 +1,319 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+var (
+	errCopyBothClosed       = errors.New("pq: COPY BOTH has already been closed")
+	errCopyBothNotSupported = errors.New("pq: COPY BOTH is only supported through StartCopyBoth")
+)
+
+// CopyBoth is a bidirectional COPY sub-protocol session, as started by
+// START_REPLICATION on a replication connection. Both sides exchange
+// CopyData messages until each has sent CopyDone.
+//
+// One goroutine may call Receive while another calls Send; neither method
+// may be called concurrently with itself or with Close. The connection
+// cannot be used for anything else until Close returns.
+type CopyBoth struct {
+	cn *conn
+
+	// the beginning of a message whose reading was interrupted by the
+	// context passed to Receive
+	partial []byte
+
+	serverDone bool // CopyDone or ErrorResponse received
+	clientDone bool // CopyDone sent
+	closed     bool
+	err        error // ErrorResponse received while copying
+
+	// result set sent by the server after the COPY
+	resultColumns []string
+	resultRows    [][]string
+}
+
+// StartCopyBoth sends query, which must make the server enter the COPY BOTH
+// sub-protocol, on the given connection using the simple query protocol. A
+// runtime panic occurs if c is not a pq connection.
+func StartCopyBoth(ctx context.Context, c driver.Conn, query string) (*CopyBoth, error) {
+	cn := c.(*conn)
+	if cn.getBad() {
+		return nil, cn.errBadConn()
+	}
+	if cn.inCopy {
+		return nil, errCopyInProgress
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+
+	cn.stmtQuery = query
+	q, err := cn.clientString(query)
+	if err != nil {
+		return nil, err
+	}
+	b := cn.writeBuf('Q')
+	b.string(q)
+	if err := cn.send(b); err != nil {
+		return nil, fmt.Errorf("fail to send: %w", err)
+	}
+
+	for {
+		t, r, err := cn.recv1()
+		if err != nil {
+			cn.setBad()
+			return nil, fmt.Errorf("cannot start COPY BOTH: %w", err)
+		}
+		switch t {
+		case 'W':
+			cn.inCopy = true
+			return &CopyBoth{cn: cn}, nil
+		case 'E':
+			err := parseError(r, cn)
+			return nil, cn.readReadyForQueryAfter(err)
+		case 'C', 'I', 'T', 'D':
+			return nil, cn.readReadyForQueryAfter(
+				fmt.Errorf("pq: %q did not start COPY BOTH", query))
+		default:
+			cn.setBad()
+			return nil, fmt.Errorf("unknown response for COPY BOTH query: %q", t)
+		}
+	}
+}
+
+// abortCopyBoth is called when a command run through the regular query path
+// enters COPY BOTH. It ends the sub-protocol so the connection stays usable.
+func (cn *conn) abortCopyBoth() error {
+	cn.inCopy = true
+	if err := (&CopyBoth{cn: cn}).Close(); err != nil {
+		return err
+	}
+	return errCopyBothNotSupported
+}
+
+// readReadyForQueryAfter discards messages up to ReadyForQuery and returns
+// err, unless the connection broke in the meantime.
+func (cn *conn) readReadyForQueryAfter(err error) error {
+	for {
+		t, r, rerr := cn.recv1()
+		if rerr != nil {
+			cn.setBad()
+			return rerr
+		}
+		if t == 'Z' {
+			cn.processReadyForQuery(r)
+			return err
+		}
+	}
+}
+
+// Send sends data to the server in a CopyData message.
+func (cb *CopyBoth) Send(data []byte) error {
+	if cb.closed || cb.clientDone {
+		return errCopyBothClosed
+	}
+	if cb.cn.getBad() {
+		return cb.cn.errBadConn()
+	}
+	// the scratch buffer may be in use by Receive
+	b := &writeBuf{buf: make([]byte, 5, 5+len(data)), pos: 1}
+	b.buf[0] = 'd'
+	b.bytes(data)
+	return cb.cn.send(b)
+}
+
+// Receive returns the payload of the next CopyData message from the server.
+// It returns io.EOF once the server has ended the COPY, and the server's
+// error if it aborted it.
+//
+// When ctx is done before a message arrives, Receive returns ctx.Err() and
+// the CopyBoth remains usable, so a deadline can be used to wake up
+// periodically, e.g. to send status updates.
+func (cb *CopyBoth) Receive(ctx context.Context) ([]byte, error) {
+	if cb.closed {
+		return nil, errCopyBothClosed
+	}
+	for {
+		if cb.serverDone {
+			if cb.err != nil {
+				return nil, cb.err
+			}
+			return nil, io.EOF
+		}
+		t, body, err := cb.readMessage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case 'd':
+			return body, nil
+		case 'c':
+			cb.serverDone = true
+		case 'E':
+			cb.serverDone = true
+			cb.err = parseError((*readBuf)(&body), cb.cn)
+		case 'N':
+			cb.cn.handleNotice((*readBuf)(&body))
+		case 'S':
+			if err := cb.cn.processParameterStatus((*readBuf)(&body)); err != nil {
+				return nil, fmt.Errorf("cannot process parameter status: %w", err)
+			}
+		case 'A':
+			// notifications are not delivered while copying
+		default:
+			cb.cn.setBad()
+			return nil, fmt.Errorf("unexpected message during COPY BOTH: %q", t)
+		}
+	}
+}
+
+// readMessage reads one message, keeping what has been read so far if ctx
+// interrupts it.
+func (cb *CopyBoth) readMessage(ctx context.Context) (byte, []byte, error) {
+	cn := cb.cn
+	if cn.getBad() {
+		return 0, nil, cn.errBadConn()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	if done := ctx.Done(); done != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = cn.c.SetReadDeadline(deadline)
+		}
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				_ = cn.c.SetReadDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			_ = cn.c.SetReadDeadline(time.Time{})
+		}()
+	}
+	// the server may stay silent until the next keepalive
+	defer cn.idleReads()()
+
+	need := 5
+	for {
+		if len(cb.partial) >= 5 {
+			need = 1 + int(binary.BigEndian.Uint32(cb.partial[1:5]))
+			if n := need - 5; n < 0 || n > cn.maxMessageSize() {
+				t := cb.partial[0]
+				cb.partial = nil
+				cn.setBad()
+				if n < 0 {
+					return 0, nil, fmt.Errorf("invalid length %d of message %q", n+4, t)
+				}
+				return 0, nil, fmt.Errorf("%w: message %q of %d bytes", ErrMessageTooLarge, t, n)
+			}
+		}
+		if len(cb.partial) >= need {
+			break
+		}
+		if cap(cb.partial) < need {
+			grown := make([]byte, len(cb.partial), need)
+			copy(grown, cb.partial)
+			cb.partial = grown
+		}
+		n, err := cn.buf.Read(cb.partial[len(cb.partial):need])
+		cb.partial = cb.partial[:len(cb.partial)+n]
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && ctx.Err() != nil {
+				return 0, nil, ctx.Err()
+			}
+			cn.setBadErr(err)
+			return 0, nil, connErr{
+				msg: "fail to read",
+				err: badConn(err),
+			}
+		}
+	}
+
+	t, body := cb.partial[0], cb.partial[5:]
+	cb.partial = nil
+	cn.traceBackend(t, body)
+	return t, body, nil
+}
+
+// Close ends the COPY BOTH sub-protocol by sending CopyDone if that has not
+// been done yet, and waits for the server to finish the command. It returns
+// the server's error if it aborted the COPY.
+func (cb *CopyBoth) Close() error {
+	if cb.closed {
+		return nil
+	}
+	cb.closed = true
+	defer func() { cb.cn.inCopy = false }()
+
+	cn := cb.cn
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if !cb.clientDone && cb.err == nil {
+		cb.clientDone = true
+		if err := cn.sendSimpleMessage('c'); err != nil {
+			return fmt.Errorf("cannot send CopyDone: %w", err)
+		}
+	}
+
+	err := cb.err
+	for {
+		t, body, rerr := cb.readMessage(context.Background())
+		if rerr != nil {
+			return rerr
+		}
+		r := readBuf(body)
+		switch t {
+		case 'Z':
+			cn.processReadyForQuery(&r)
+			return err
+		case 'E':
+			err = parseError(&r, cn)
+		case 'N':
+			cn.handleNotice(&r)
+		case 'S':
+			if perr := cn.processParameterStatus(&r); perr != nil {
+				return fmt.Errorf("cannot process parameter status: %w", perr)
+			}
+		case 'T':
+			header, perr := parsePortalRowDescribe(&cn.parameterStatus, &r)
+			if perr != nil {
+				return fmt.Errorf("cannot parse row description: %w", perr)
+			}
+			cb.resultColumns = header.colNames
+		case 'D':
+			row := make([]string, r.int16())
+			for i := range row {
+				if l := r.int32(); l >= 0 {
+					row[i] = string(cn.parameterStatus.fromServer(r.next(l)))
+				}
+			}
+			cb.resultRows = append(cb.resultRows, row)
+		default:
+			// remaining CopyData, CopyDone and CommandComplete
+		}
+	}
+}
+
+// Result returns the result set the server sent after the COPY ended, in
+// text form, such as the next timeline after streaming a historic timeline
+// of the log. It is only available after Close.
+func (cb *CopyBoth) Result() (columns []string, rows [][]string) {
+	return cb.resultColumns, cb.resultRows
+}
@@ -0,0 +1,481 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+var (
+	errBinaryCopyOut = errors.New("pq: COPY TO returned binary data, which cannot be parsed as records")
+	errTextCopyOut   = errors.New("pq: COPY TO returned data in text format, not binary")
+)
+
+// CopyOut runs query, a COPY ... TO STDOUT statement, on the given
+// connection and writes the data sent by the server to w, one Write per
+// row. Data in text or CSV format is converted from the client_encoding of
+// the session like other text. It returns the number of rows copied.
+//
+// If w fails, the rest of the data is read and discarded before the error
+// is returned, so that the connection stays usable. A runtime panic occurs
+// if c is not a pq connection.
+func CopyOut(ctx context.Context, c driver.Conn, query string, w io.Writer) (int64, error) {
+	cn := c.(*conn)
+	var binary bool
+	return cn.copyOut(ctx, query, func(b bool) error {
+		binary = b
+		return nil
+	}, func(data []byte) error {
+		if !binary {
+			data = cn.parameterStatus.fromServer(data)
+		}
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// CopyOutBinary runs query, a COPY ... TO STDOUT (FORMAT binary) statement,
+// and calls fn with the values of every row, decoded from their binary
+// format according to types, the OIDs of the columns, which the binary
+// format does not include. The values have the types listed in Data Types,
+// with nil for NULL; []byte values are only valid until fn returns. Columns
+// of a type without a binary decoder can be read as raw binary data by
+// giving their type as oid.T_bytea.
+//
+// If fn returns an error, the rest of the data is read and discarded before
+// the error is returned. It returns the number of rows copied. A runtime
+// panic occurs if c is not a pq connection.
+func CopyOutBinary(ctx context.Context, c driver.Conn, query string, types []oid.Oid,
+	fn func(values []driver.Value) error) (int64, error) {
+	cn := c.(*conn)
+	d := binaryCopyDecoder{ps: &cn.parameterStatus, types: types, fn: fn, values: make([]driver.Value, len(types))}
+	return cn.copyOut(ctx, query, func(binary bool) error {
+		if !binary {
+			return errTextCopyOut
+		}
+		return nil
+	}, d.decode)
+}
+
+// binaryCopyDecoder parses COPY data in binary format.
+type binaryCopyDecoder struct {
+	ps     *parameterStatus
+	types  []oid.Oid
+	fn     func(values []driver.Value) error
+	values []driver.Value
+
+	headerDone bool
+	ended      bool
+	// the incomplete header or row at the end of the last CopyData message
+	pending []byte
+}
+
+// binaryCopySignature starts the header of the binary COPY format, followed
+// by 32 bits of flags and the length of the header extension.
+const binaryCopySignature = "PGCOPY\n\377\r\n\000"
+
+// decode parses the rows of a CopyData message, keeping the part of a row
+// that continues in the next one.
+func (d *binaryCopyDecoder) decode(data []byte) error {
+	if len(d.pending) > 0 {
+		d.pending = append(d.pending, data...)
+		data = d.pending
+	}
+	for len(data) > 0 {
+		n, err := d.next(data)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		data = data[n:]
+	}
+	d.pending = append(d.pending[:0], data...)
+	return nil
+}
+
+// next parses the header, a row or the trailer at the start of data, and
+// returns its length, or 0 if data is incomplete.
+func (d *binaryCopyDecoder) next(data []byte) (int, error) {
+	if d.ended {
+		return 0, errors.New("pq: binary COPY data after the trailer")
+	}
+	if !d.headerDone {
+		if len(data) < len(binaryCopySignature)+8 {
+			return 0, nil
+		}
+		if string(data[:len(binaryCopySignature)]) != binaryCopySignature {
+			return 0, errors.New("pq: invalid binary COPY signature")
+		}
+		ext := len(binaryCopySignature) + 4
+		n := ext + 4 + int(binary.BigEndian.Uint32(data[ext:]))
+		if len(data) < n {
+			return 0, nil
+		}
+		d.headerDone = true
+		return n, nil
+	}
+
+	if len(data) < 2 {
+		return 0, nil
+	}
+	fields := int(int16(binary.BigEndian.Uint16(data)))
+	if fields == -1 {
+		d.ended = true
+		return 2, nil
+	}
+	if fields != len(d.types) {
+		return 0, fmt.Errorf("pq: binary COPY row has %d fields but %d types were given", fields, len(d.types))
+	}
+	pos := 2
+	for i, typ := range d.types {
+		if len(data) < pos+4 {
+			return 0, nil
+		}
+		size := int(int32(binary.BigEndian.Uint32(data[pos:])))
+		pos += 4
+		if size < 0 {
+			d.values[i] = nil
+			continue
+		}
+		if len(data) < pos+size {
+			return 0, nil
+		}
+		v, err := binaryDecode(d.ps, data[pos:pos+size], typ)
+		if err != nil {
+			return 0, fmt.Errorf("pq: cannot decode binary COPY field %d: %w", i, err)
+		}
+		d.values[i] = v
+		pos += size
+	}
+	if err := d.fn(d.values); err != nil {
+		return 0, err
+	}
+	return pos, nil
+}
+
+// CopyFormat describes the data sent by a COPY ... TO STDOUT statement in
+// text or CSV format, and must match the options of the statement.
+type CopyFormat struct {
+	// CSV is set for FORMAT csv; the text format is the default.
+	CSV bool
+	// Delimiter is the DELIMITER option, by default a tab in text format
+	// and a comma in CSV format.
+	Delimiter byte
+	// Null is the NULL option, by default \N in text format and an
+	// unquoted empty string in CSV format.
+	Null string
+	// Quote and Escape are the QUOTE and ESCAPE options of the CSV format,
+	// by default a double quote, and Escape the same as Quote.
+	Quote  byte
+	Escape byte
+}
+
+// withDefaults returns f with the options left unset replaced by their
+// default.
+func (f CopyFormat) withDefaults() CopyFormat {
+	if f.Delimiter == 0 {
+		f.Delimiter = '\t'
+		if f.CSV {
+			f.Delimiter = ','
+		}
+	}
+	if f.Null == "" && !f.CSV {
+		f.Null = `\N`
+	}
+	if f.Quote == 0 {
+		f.Quote = '"'
+	}
+	if f.Escape == 0 {
+		f.Escape = f.Quote
+	}
+	return f
+}
+
+// CopyOutRecords runs query, a COPY ... TO STDOUT statement in the text or
+// CSV format described by format, and calls fn with the fields of every
+// row, which fn may keep. NULL fields are empty in record, and set in null.
+// Quoting, escapes and embedded newlines are handled as COPY defines them;
+// the header row of the HEADER option is passed to fn like any other row.
+//
+// If fn returns an error, the rest of the data is read and discarded before
+// the error is returned. It returns the number of rows copied. A runtime
+// panic occurs if c is not a pq connection.
+func CopyOutRecords(ctx context.Context, c driver.Conn, query string, format CopyFormat,
+	fn func(record []string, null []bool) error) (int64, error) {
+	cn := c.(*conn)
+	format = format.withDefaults()
+	return cn.copyOut(ctx, query, func(binary bool) error {
+		if binary {
+			return errBinaryCopyOut
+		}
+		return nil
+	}, func(data []byte) error {
+		record, null, err := format.parseRow(string(cn.parameterStatus.fromServer(data)))
+		if err != nil {
+			return err
+		}
+		return fn(record, null)
+	})
+}
+
+// CopyOutCSV is like CopyOutRecords, but writes the records to w, with NULL
+// fields as empty fields, and flushes it.
+func CopyOutCSV(ctx context.Context, c driver.Conn, query string, format CopyFormat, w *csv.Writer) (int64, error) {
+	n, err := CopyOutRecords(ctx, c, query, format, func(record []string, _ []bool) error {
+		return w.Write(record)
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	return n, err
+}
+
+// copyOut sends query using the simple query protocol and passes the
+// format of the COPY OUT data to start, then every CopyData message to fn.
+// Once start or fn failed, the remaining data is discarded.
+func (cn *conn) copyOut(ctx context.Context, query string, start func(binary bool) error,
+	fn func(data []byte) error) (rows int64, err error) {
+	if cn.getBad() {
+		return 0, cn.errBadConn()
+	}
+	if cn.inCopy {
+		return 0, errCopyInProgress
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+
+	cn.stmtQuery = query
+	q, err := cn.clientString(query)
+	if err != nil {
+		return 0, err
+	}
+	b := cn.writeBuf('Q')
+	b.string(q)
+	if err := cn.send(b); err != nil {
+		return 0, contextErr(ctx, fmt.Errorf("fail to send: %w", err))
+	}
+
+	var (
+		r        readBuf
+		firstErr error
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for {
+		t, err := cn.recv1Buf(&r)
+		if err != nil {
+			cn.setBadErr(err)
+			return rows, contextErr(ctx, fmt.Errorf("cannot recv from conn: %w", err))
+		}
+		switch t {
+		case 'H': // CopyOutResponse
+			cn.inCopy = true
+			if err := start(r.byte() != 0); err != nil {
+				fail(err)
+			}
+		case 'd': // CopyData
+			if firstErr == nil {
+				if err := fn(r); err != nil {
+					fail(err)
+				}
+			}
+		case 'c': // CopyDone
+		case 'C':
+			cn.inCopy = false
+			tag, err := r.string()
+			if err != nil {
+				return rows, fmt.Errorf("cannot get string from read buf: %w", err)
+			}
+			res, _, err := cn.parseComplete(tag)
+			if err != nil {
+				return rows, fmt.Errorf("cannot parse complete: %w", err)
+			}
+			rows, _ = res.(driver.RowsAffected).RowsAffected()
+		case 'E':
+			cn.inCopy = false
+			fail(parseError(&r, cn))
+		case 'Z':
+			cn.processReadyForQuery(&r)
+			return rows, contextErr(ctx, firstErr)
+		case 'G': // CopyInResponse
+			fail(fmt.Errorf("pq: %q is not a COPY TO statement", query))
+			w := cn.writeBuf('f')
+			w.string(firstErr.Error())
+			if err := cn.send(w); err != nil {
+				return rows, fmt.Errorf("cannot send CopyFail: %w", err)
+			}
+		case 'W':
+			return rows, cn.abortCopyBoth()
+		case 'T', 'D', 'I':
+			fail(fmt.Errorf("pq: %q is not a COPY TO statement", query))
+		default:
+			cn.setBad()
+			return rows, fmt.Errorf("unknown response for COPY TO: %q", t)
+		}
+	}
+}
+
+// parseRow parses a row of COPY data, with its terminating newline.
+func (f CopyFormat) parseRow(line string) (record []string, null []bool, err error) {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if f.CSV {
+		return f.parseCSVRow(line)
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	start := 0
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && line[i] == '\\' {
+			i++
+			continue
+		}
+		if i < len(line) && line[i] != f.Delimiter {
+			continue
+		}
+		field := line[start:i]
+		start = i + 1
+		if field == f.Null {
+			record, null = append(record, ""), append(null, true)
+			continue
+		}
+		v, err := unescapeCopyText(field)
+		if err != nil {
+			return nil, nil, err
+		}
+		record, null = append(record, v), append(null, false)
+	}
+	return record, null, nil
+}
+
+// unescapeCopyText replaces the backslash sequences of a field in text
+// format.
+func unescapeCopyText(s string) (string, error) {
+	i := 0
+	for i < len(s) && s[i] != '\\' {
+		i++
+	}
+	if i == len(s) {
+		return s, nil
+	}
+	b := []byte(s[:i])
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("pq: unterminated backslash sequence in COPY data %q", s)
+		}
+		switch c = s[i]; c {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i + 1
+			for j < len(s) && j < i+3 && isOctal(s[j]) {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i:j], 8, 16)
+			b = append(b, byte(n))
+			i = j - 1
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHexDigit(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				// a lone \x is an x
+				b = append(b, 'x')
+				continue
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			b = append(b, byte(n))
+			i = j - 1
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b), nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// parseCSVRow parses a row in CSV format, without its terminating newline.
+func (f CopyFormat) parseCSVRow(line string) (record []string, null []bool, err error) {
+	i := 0
+	for {
+		var (
+			b      []byte
+			quoted bool
+			start  = i
+		)
+	field:
+		for i < len(line) {
+			c := line[i]
+			switch {
+			case c == f.Delimiter:
+				break field
+			case c == f.Quote:
+				quoted = true
+				for i++; ; i++ {
+					if i == len(line) {
+						return nil, nil, fmt.Errorf("pq: unterminated quoted field in COPY data %q", line)
+					}
+					c := line[i]
+					if c == f.Escape && i+1 < len(line) && (line[i+1] == f.Quote || line[i+1] == f.Escape) {
+						i++
+						b = append(b, line[i])
+						continue
+					}
+					if c == f.Quote {
+						break
+					}
+					b = append(b, c)
+				}
+				i++
+			default:
+				b = append(b, c)
+				i++
+			}
+		}
+		if !quoted && line[start:i] == f.Null {
+			record, null = append(record, ""), append(null, true)
+		} else {
+			record, null = append(record, string(b)), append(null, false)
+		}
+		if i == len(line) {
+			return record, null, nil
+		}
+		i++ // delimiter
+	}
+}
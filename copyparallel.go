@@ -0,0 +1,209 @@
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+)
+
+// ParallelCopyOptions are the options of ParallelCopy.
+type ParallelCopyOptions struct {
+	// Workers is the number of connections copying concurrently. The
+	// default is 4.
+	Workers int
+	// Schema is the schema of the target tables. If empty, the tables are
+	// looked up in search_path.
+	Schema string
+	// Target, if set, returns the table a row is copied into, e.g. the
+	// partition it belongs to, instead of the table passed to ParallelCopy.
+	// All rows of a table are copied by the same worker, so copying is
+	// spread over the workers by table.
+	Target func(row []interface{}) string
+	// BufferRows is the number of rows queued for each worker. The default
+	// is 256.
+	BufferRows int
+}
+
+// ParallelCopyResult reports what ParallelCopy copied.
+type ParallelCopyResult struct {
+	Rows       int64   // rows copied in total
+	WorkerRows []int64 // rows copied by each worker
+}
+
+type parallelCopyRow struct {
+	table string
+	row   []interface{}
+}
+
+// ParallelCopy copies the rows returned by next into table, spreading them
+// over several connections of db, each running COPY in its own transaction.
+// This helps when a single COPY is limited by the client encoding the rows
+// rather than by the server. next is called from a single goroutine and
+// returns io.EOF after the last row; as rows are handed to other goroutines,
+// it must return a new slice for every row.
+//
+// If next or any worker fails, all workers stop and every transaction is
+// rolled back, and the first error is returned. Otherwise the transactions
+// are committed one after the other; should a commit fail, the remaining
+// transactions are rolled back but those committed before stay committed.
+func ParallelCopy(ctx context.Context, db *sql.DB, table string, columns []string,
+	next func() ([]interface{}, error), opts ParallelCopyOptions) (ParallelCopyResult, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	bufferRows := opts.BufferRows
+	if bufferRows <= 0 {
+		bufferRows = 256
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	res := ParallelCopyResult{WorkerRows: make([]int64, workers)}
+	queues := make([]chan parallelCopyRow, workers)
+	txs := make([]*sql.Tx, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan parallelCopyRow, bufferRows)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := parallelCopyWorker(ctx, db, opts.Schema, columns, queues[i], &txs[i])
+			res.WorkerRows[i] = n
+			if err != nil {
+				fail(fmt.Errorf("pq: copy worker %d: %w", i, err))
+			}
+		}(i)
+	}
+
+dispatch:
+	for n := 0; ; n++ {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(err)
+			break
+		}
+		target, w := table, n%workers
+		if opts.Target != nil {
+			target = opts.Target(row)
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(target))
+			w = int(h.Sum32() % uint32(workers))
+		}
+		select {
+		case queues[w] <- parallelCopyRow{table: target, row: row}:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		// the caller's context may have been canceled while dispatching
+		if err := ctx.Err(); err != nil {
+			fail(err)
+		}
+	}
+	for i, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		if firstErr != nil {
+			_ = tx.Rollback()
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			fail(fmt.Errorf("pq: cannot commit copy worker %d: %w", i, err))
+		}
+	}
+	if firstErr != nil {
+		return res, firstErr
+	}
+	for _, n := range res.WorkerRows {
+		res.Rows += n
+	}
+	return res, nil
+}
+
+// parallelCopyWorker copies the rows from queue in a transaction it leaves
+// open in *tx, starting a new COPY whenever the target table changes.
+func parallelCopyWorker(ctx context.Context, db *sql.DB, schema string, columns []string,
+	queue <-chan parallelCopyRow, tx **sql.Tx) (rows int64, err error) {
+	var (
+		stmt  *sql.Stmt
+		table string
+	)
+	flush := func() error {
+		if stmt == nil {
+			return nil
+		}
+		r, err := stmt.ExecContext(ctx)
+		if cerr := stmt.Close(); err == nil {
+			err = cerr
+		}
+		stmt = nil
+		if err != nil {
+			return err
+		}
+		n, err := r.RowsAffected()
+		rows += n
+		return err
+	}
+	defer func() {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+	}()
+
+	for r := range queue {
+		if ctx.Err() != nil {
+			// drain the queue so the dispatcher is not blocked
+			continue
+		}
+		if *tx == nil {
+			if *tx, err = db.BeginTx(ctx, nil); err != nil {
+				return rows, err
+			}
+		}
+		if stmt == nil || r.table != table {
+			if err = flush(); err != nil {
+				return rows, err
+			}
+			table = r.table
+			q := CopyIn(table, columns...)
+			if schema != "" {
+				q = CopyInSchema(schema, table, columns...)
+			}
+			if stmt, err = (*tx).PrepareContext(ctx, q); err != nil {
+				return rows, err
+			}
+		}
+		if _, err = stmt.ExecContext(ctx, r.row...); err != nil {
+			return rows, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return rows, err
+	}
+	return rows, flush()
+}
@@ -0,0 +1,252 @@
+package pq
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ctxWatch is a context watched by watchCancel.
+type ctxWatch struct {
+	ctx context.Context
+	// guarded by conn.watchMu
+	active   bool // until the function returned by watchCancel is called
+	canceled bool // a cancel request was sent for it
+}
+
+// expired reports whether the context is done, or about to be: the network
+// connection may time out just before the timer of the context fires.
+func (w *ctxWatch) expired() bool {
+	if w.ctx.Err() != nil {
+		return true
+	}
+	d, ok := w.ctx.Deadline()
+	return ok && !time.Now().Before(d)
+}
+
+type watchMsg struct {
+	w   *ctxWatch
+	add bool
+}
+
+// ctxWatcher is the goroutine of a connection waiting for the contexts
+// passed to watchCancel to be done. It is started by the first call to
+// watchCancel and runs until the connection is closed.
+type ctxWatcher struct {
+	cn       *conn
+	msgs     chan watchMsg
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// watcher returns the watcher goroutine of cn, starting it if needed.
+func (cn *conn) watcher() *ctxWatcher {
+	if cn.ctxWatcher == nil {
+		cn.ctxWatcher = &ctxWatcher{
+			cn:   cn,
+			msgs: make(chan watchMsg, 4),
+			quit: make(chan struct{}),
+		}
+		go cn.ctxWatcher.run()
+	}
+	return cn.ctxWatcher
+}
+
+// stopWatcher stops the watcher goroutine of cn, if any.
+func (cn *conn) stopWatcher() {
+	if cw := cn.ctxWatcher; cw != nil {
+		cw.stopOnce.Do(func() { close(cw.quit) })
+	}
+}
+
+func (cw *ctxWatcher) send(m watchMsg) {
+	select {
+	case cw.msgs <- m:
+	case <-cw.quit:
+	}
+}
+
+func (cw *ctxWatcher) run() {
+	var (
+		watching []*ctxWatch
+		cases    []reflect.SelectCase
+	)
+	for {
+		var (
+			m      watchMsg
+			fired  *ctxWatch
+			msgOK  bool
+			closed bool
+		)
+		switch len(watching) {
+		case 0:
+			select {
+			case m = <-cw.msgs:
+				msgOK = true
+			case <-cw.quit:
+				closed = true
+			}
+		case 1:
+			select {
+			case m = <-cw.msgs:
+				msgOK = true
+			case <-cw.quit:
+				closed = true
+			case <-watching[0].ctx.Done():
+				fired = watching[0]
+			}
+		default:
+			// a statement within a transaction begun with a context
+			cases = append(cases[:0],
+				reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cw.msgs)},
+				reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cw.quit)})
+			for _, w := range watching {
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(w.ctx.Done())})
+			}
+			chosen, v, _ := reflect.Select(cases)
+			switch chosen {
+			case 0:
+				m, msgOK = v.Interface().(watchMsg), true
+			case 1:
+				closed = true
+			default:
+				fired = watching[chosen-2]
+			}
+		}
+
+		switch {
+		case closed:
+			return
+		case msgOK && m.add:
+			watching = append(watching, m.w)
+			continue
+		case msgOK:
+			fired = nil
+		default:
+			cw.cn.interrupt(fired)
+			m.w = fired
+		}
+		for i, w := range watching {
+			if w == m.w {
+				watching = append(watching[:i], watching[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// interrupt makes the read or write in progress on cn, if any, return with a
+// timeout, unless w is no longer watched.
+func (cn *conn) interrupt(w *ctxWatch) {
+	cn.watchMu.Lock()
+	defer cn.watchMu.Unlock()
+	if w.active && !w.canceled {
+		_ = cn.c.SetDeadline(time.Unix(1, 0))
+	}
+}
+
+// applyDeadlineLocked sets the deadline of the network connection to the
+// earliest deadline of the contexts watched and not cancelled yet. The
+// caller must hold cn.watchMu.
+func (cn *conn) applyDeadlineLocked() {
+	var deadline time.Time
+	for _, w := range cn.watches {
+		if w.canceled {
+			continue
+		}
+		if w.ctx.Err() != nil {
+			deadline = time.Unix(1, 0)
+			break
+		}
+		if d, ok := w.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	_ = cn.c.SetDeadline(deadline)
+}
+
+// cancelExpired is called when a read or write on cn failed with err. If
+// err is a timeout caused by a watched context, it sends a cancel request,
+// clears the deadline and reports true, and the read or write is retried.
+func (cn *conn) cancelExpired(err error) bool {
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		return false
+	}
+	cn.watchMu.Lock()
+	var expired *ctxWatch
+	for _, w := range cn.watches {
+		if !w.canceled && w.expired() {
+			w.canceled = true
+			expired = w
+		}
+	}
+	if expired == nil {
+		// a deadline set by someone else, e.g. CopyBoth.Receive
+		cn.watchMu.Unlock()
+		return false
+	}
+	cn.applyDeadlineLocked()
+	cn.watchMu.Unlock()
+
+	// At this point the context of the statement is done, so it must not
+	// be used for the additional network request to cancel the query.
+	ctx, cancel := context.WithTimeout(context.Background(), cn.cancelTimeout())
+	defer cancel()
+	if err := cn.cancel(ctx); err != nil {
+		cn.log(expired.ctx, LogLevelError, fmt.Sprintf("fail to cancel: %v", err), map[string]interface{}{})
+	}
+	return true
+}
+
+// watchedConn is the network connection of a conn once it is established,
+// see watchCancel.
+type watchedConn struct {
+	net.Conn
+	cn *conn
+}
+
+func (c *watchedConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(b)
+		if n > 0 || err == nil || !c.cn.cancelExpired(err) {
+			return n, err
+		}
+	}
+}
+
+func (c *watchedConn) Write(b []byte) (int, error) {
+	written := 0
+	for {
+		n, err := c.Conn.Write(b[written:])
+		written += n
+		if err == nil {
+			return written, nil
+		}
+		if _, ok := c.Conn.(*tls.Conn); ok {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				// a tls.Conn fails every write after one timed out
+				c.cn.setBadErr(err)
+				return written, err
+			}
+		}
+		if !c.cn.cancelExpired(err) {
+			return written, err
+		}
+	}
+}
+
+// unwrapConn returns the network connection under the watchedConn c, if it
+// is one.
+func unwrapConn(c net.Conn) net.Conn {
+	if wc, ok := c.(*watchedConn); ok {
+		return wc.Conn
+	}
+	return c
+}
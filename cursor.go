@@ -0,0 +1,84 @@
+package pq
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+var errCursorOutsideTxn = errors.New("pq: cursors can only be declared inside a transaction")
+
+// Cursor is a server-side cursor declared by DeclareCursor. It reads a
+// result set in batches of rows, so that the rows held by the client are
+// bounded by the batch size whatever the size of the result set. A Cursor
+// belongs to the transaction that declared it, which closes it on commit or
+// rollback.
+type Cursor struct {
+	c      *Conn
+	name   string
+	txnGen uint64 // of the declaring transaction
+	closed bool
+}
+
+// DeclareCursor declares a cursor for query with args, which are converted
+// as by Query. It must be called inside a transaction, e.g. after
+// Exec(ctx, "BEGIN").
+//
+//	cur, err := c.DeclareCursor(ctx, "SELECT * FROM events WHERE day = $1", day)
+//	...
+//	for {
+//		rows, err := cur.FetchN(ctx, 1000)
+//		...
+//		n := 0
+//		for rows.Next() {
+//			n++
+//			...
+//		}
+//		if err := rows.Err(); err != nil || n == 0 {
+//			break
+//		}
+//	}
+//	err = cur.Close(ctx)
+func (c *Conn) DeclareCursor(ctx context.Context, query string, args ...interface{}) (*Cursor, error) {
+	if !c.cn.isInTransaction() {
+		return nil, errCursorOutsideTxn
+	}
+	name := "pq_cursor_" + c.cn.gname()
+	if _, err := c.Exec(ctx, "DECLARE "+name+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return nil, err
+	}
+	return &Cursor{c: c, name: name, txnGen: c.cn.txnGen}, nil
+}
+
+// Name returns the name of the cursor on the server, e.g. for use in
+// UPDATE ... WHERE CURRENT OF statements.
+func (cur *Cursor) Name() string {
+	return cur.name
+}
+
+// FetchN returns the next n rows of the cursor, decoded like the rows of
+// Query. The rows must be closed, or read until Next returns false, before
+// the connection is used again. Once FetchN returns no rows, the cursor is
+// exhausted.
+func (cur *Cursor) FetchN(ctx context.Context, n int) (*Rows, error) {
+	if n <= 0 {
+		return nil, errors.New("pq: the number of rows to fetch must be positive")
+	}
+	return cur.c.Query(ctx, "FETCH FORWARD "+strconv.Itoa(n)+" FROM "+cur.name)
+}
+
+// Close closes the cursor. It does nothing if the cursor is already closed,
+// including by the end of its transaction.
+func (cur *Cursor) Close(ctx context.Context) error {
+	if cur.closed {
+		return nil
+	}
+	cur.closed = true
+	if cur.c.cn.txnGen != cur.txnGen || cur.c.cn.txnStatus != txnStatusIdleInTransaction {
+		// closed with its transaction, which may have been followed by
+		// another one, or unusable until the rollback
+		return nil
+	}
+	_, err := cur.c.Exec(ctx, "CLOSE "+cur.name)
+	return err
+}
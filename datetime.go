@@ -0,0 +1,233 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date is a value of the date type, without the time of day and time zone
+// that a time.Time would carry. It implements sql.Scanner and driver.Valuer,
+// and Valid is false for NULL.
+//
+// Year follows package time, where year 0 is 1 BC.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+	Valid bool // Valid is true if the date is not NULL
+}
+
+// DateOf returns the date of t in its location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d, Valid: true}
+}
+
+// In returns the midnight starting the date in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// String returns the date in the format of the server, e.g. 2006-01-02 or
+// 0044-03-15 BC, or NULL if it is not valid.
+func (d Date) String() string {
+	if !d.Valid {
+		return "NULL"
+	}
+	if d.Year <= 0 {
+		return fmt.Sprintf("%04d-%02d-%02d BC", 1-d.Year, d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Scan implements the Scanner interface.
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case []byte:
+		return d.parse(string(v))
+	case string:
+		return d.parse(v)
+	default:
+		return fmt.Errorf("pq: cannot scan %T into a Date", value)
+	}
+}
+
+func (d *Date) parse(s string) error {
+	t, err := ParseTimestamp(nil, s)
+	if err != nil {
+		return fmt.Errorf("pq: cannot parse date %q: %w", s, err)
+	}
+	*d = DateOf(t)
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (d Date) Value() (driver.Value, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+// Time is a value of the time or timetz type, without the date that a
+// time.Time would carry. A timetz value keeps its offset, which may differ
+// from the offset of any location. It implements sql.Scanner and
+// driver.Valuer, and Valid is false for NULL.
+//
+// Hour is 24 for the time 24:00:00, which the server accepts as the end of
+// a day.
+type Time struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+
+	// Offset is the offset of a timetz value in seconds east of UTC, if
+	// HasOffset is set.
+	Offset    int
+	HasOffset bool
+
+	Valid bool // Valid is true if the time is not NULL
+}
+
+// TimeOf returns the time of day of t, with the offset of t if withOffset
+// is set.
+func TimeOf(t time.Time, withOffset bool) Time {
+	h, m, s := t.Clock()
+	tm := Time{Hour: h, Minute: m, Second: s, Nanosecond: t.Nanosecond(), Valid: true}
+	if withOffset {
+		_, tm.Offset = t.Zone()
+		tm.HasOffset = true
+	}
+	return tm
+}
+
+// On returns the time on the given date. The offset of a timetz value takes
+// precedence over loc.
+func (tm Time) On(d Date, loc *time.Location) time.Time {
+	if tm.HasOffset {
+		loc = time.FixedZone("", tm.Offset)
+	}
+	return time.Date(d.Year, d.Month, d.Day, tm.Hour, tm.Minute, tm.Second, tm.Nanosecond, loc)
+}
+
+// String returns the time in the format of the server, e.g. 15:04:05.123456
+// or 15:04:05+05:30, or NULL if it is not valid.
+func (tm Time) String() string {
+	if !tm.Valid {
+		return "NULL"
+	}
+	b := make([]byte, 0, 32)
+	b = append(b, fmt.Sprintf("%02d:%02d:%02d", tm.Hour, tm.Minute, tm.Second)...)
+	if tm.Nanosecond != 0 {
+		frac := strconv.Itoa(1000000000 + tm.Nanosecond)[1:]
+		b = append(b, '.')
+		b = append(b, strings.TrimRight(frac, "0")...)
+	}
+	if tm.HasOffset {
+		off := tm.Offset
+		sign := byte('+')
+		if off < 0 {
+			sign, off = '-', -off
+		}
+		b = append(b, sign)
+		b = append(b, fmt.Sprintf("%02d:%02d", off/3600, off/60%60)...)
+		if off%60 != 0 {
+			b = append(b, fmt.Sprintf(":%02d", off%60)...)
+		}
+	}
+	return string(b)
+}
+
+// Scan implements the Scanner interface. time.Time values are those
+// returned for time and timetz columns: the offset is kept unless the
+// location is UTC, as for a time column.
+func (tm *Time) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*tm = Time{}
+		return nil
+	case time.Time:
+		*tm = TimeOf(v, v.Location() != time.UTC)
+		if v.Year() == 0 && v.YearDay() == 2 && tm.Hour == 0 && tm.Minute == 0 && tm.Second == 0 && tm.Nanosecond == 0 {
+			// 24:00:00, returned as midnight of the next day
+			tm.Hour = 24
+		}
+		return nil
+	case []byte:
+		return tm.parse(string(v))
+	case string:
+		return tm.parse(v)
+	default:
+		return fmt.Errorf("pq: cannot scan %T into a Time", value)
+	}
+}
+
+// parse parses a time or timetz value in the text format of the server.
+func (tm *Time) parse(s string) error {
+	clock, off := s, ""
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		clock, off = s[:i], s[i:]
+	}
+	t, err := time.Parse("15:04:05", clock)
+	hour24 := false
+	if err != nil && time2400Regex.MatchString(clock) {
+		t, err = time.Parse("15:04:05", "00"+clock[2:])
+		hour24 = true
+	}
+	if err != nil {
+		return fmt.Errorf("pq: cannot parse time %q: %w", s, err)
+	}
+	parsed := TimeOf(t, false)
+	if hour24 {
+		parsed.Hour = 24
+	}
+	if off != "" {
+		parsed.Offset, err = parseTimeOffset(off)
+		if err != nil {
+			return fmt.Errorf("pq: cannot parse time %q: %w", s, err)
+		}
+		parsed.HasOffset = true
+	}
+	*tm = parsed
+	return nil
+}
+
+// parseTimeOffset parses an offset such as -07, +05:30 or +01:02:03 into
+// seconds east of UTC.
+func parseTimeOffset(s string) (int, error) {
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	parts := strings.Split(s[1:], ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	off := 0
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || len(p) != 2 {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		off += n * []int{3600, 60, 1}[i]
+	}
+	return sign * off, nil
+}
+
+// Value implements the driver Valuer interface.
+func (tm Time) Value() (driver.Value, error) {
+	if !tm.Valid {
+		return nil, nil
+	}
+	return tm.String(), nil
+}
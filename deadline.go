@@ -0,0 +1,65 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// setDeadlineTimeout implements Config.DeadlineStatementTimeout. It sets
+// statement_timeout to the time left until the context deadline and returns a
+// function restoring the previous value, which must be called once the
+// statement's results have been read.
+func (cn *conn) setDeadlineTimeout(ctx context.Context) (restore func(), err error) {
+	restore = func() {}
+	if !cn.config.DeadlineStatementTimeout || cn.txnStatus == txnStatusInFailedTransaction {
+		return restore, nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return restore, nil
+	}
+	ms := time.Until(deadline).Milliseconds()
+	if ms <= 0 {
+		// the cancel watcher reports the expired context
+		return restore, nil
+	}
+	prev, err := cn.swapStatementTimeout(strconv.FormatInt(ms, 10))
+	if err != nil {
+		return nil, fmt.Errorf("cannot set statement_timeout from context deadline: %w", err)
+	}
+	return func() {
+		if cn.getBad() || cn.txnStatus == txnStatusInFailedTransaction {
+			// a failed transaction undoes the SET when it is rolled back
+			return
+		}
+		if _, _, err := cn.simpleExec("SET statement_timeout = " + QuoteLiteral(prev)); err != nil {
+			cn.log(ctx, LogLevelWarn, "cannot restore statement_timeout", map[string]interface{}{"error": err})
+			cn.setBad()
+		}
+	}, nil
+}
+
+// swapStatementTimeout sets statement_timeout to value and returns the value
+// it had, e.g. one set by the application, in a single round trip.
+func (cn *conn) swapStatementTimeout(value string) (string, error) {
+	// the select list is evaluated in order, so the setting is read before
+	// set_config changes it
+	rows, err := cn.simpleQuery("SELECT pg_catalog.current_setting('statement_timeout'), " +
+		"pg_catalog.set_config('statement_timeout', " + QuoteLiteral(value) + ", false)")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	row := make([]driver.Value, 2)
+	if err := rows.Next(row); err == io.EOF {
+		return "", errors.New("no row returned")
+	} else if err != nil {
+		return "", err
+	}
+	return asString(row[0]), nil
+}
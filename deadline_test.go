@@ -0,0 +1,107 @@
+package pq
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// TestDeadlineStatementTimeoutPrepared checks that statements prepared with
+// Prepare set statement_timeout from the context deadline, and restore it.
+func TestDeadlineStatementTimeoutPrepared(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	db := openFakeDB(t, "deadline_statement_timeout=true", nil, func(s *fakeServer) {
+		var parsed, bound, closed bool
+		for {
+			typ, body, err := s.recv()
+			if err != nil {
+				return
+			}
+			switch typ {
+			case 'P':
+				parsed = true
+				continue
+			case 'B':
+				bound = true
+				continue
+			case 'C':
+				closed = true
+				continue
+			case 'D', 'E':
+				continue
+			case 'S':
+				switch {
+				case parsed:
+					s.send('1')
+					s.send('t', int16Bytes(0))
+					s.send('n')
+				case bound:
+					s.send('2')
+					s.complete("UPDATE 0")
+				case closed:
+					s.send('3')
+				}
+				parsed, bound, closed = false, false, false
+			case 'Q':
+				q := string(cstr(body))
+				mu.Lock()
+				queries = append(queries, q)
+				mu.Unlock()
+				if strings.Contains(q, "set_config") {
+					s.describe([]oid.Oid{oid.T_text, oid.T_text})
+					s.row([]byte("0"), []byte("1000"))
+					s.complete("SELECT 1")
+				} else {
+					s.complete("SET")
+				}
+			default:
+				return
+			}
+			s.ready('I')
+			if err := s.flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stmt, err := db.PrepareContext(ctx, "UPDATE t SET x = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var set, restored int
+	for _, q := range queries {
+		switch {
+		case strings.Contains(q, "set_config('statement_timeout'"):
+			set++
+		case q == "SET statement_timeout = '0'":
+			restored++
+		}
+	}
+	if set != 2 || restored != 2 {
+		t.Errorf("statement_timeout set %d times and restored %d times, want 2 and 2; queries: %q",
+			set, restored, queries)
+	}
+}
@@ -0,0 +1,40 @@
+package pq
+
+import "context"
+
+// DeallocateAll deallocates all prepared statements of the session, e.g.
+// after DDL changed the tables their plans were made for, or when the
+// server side of the connection may have changed behind a transaction-mode
+// pooler. The statements prepared on the connection before remain usable:
+// each is prepared again under its name the next time it is executed.
+func (c *Conn) DeallocateAll(ctx context.Context) error {
+	cn := c.cn
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if cn.inCopy {
+		return errCopyInProgress
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+	if _, _, err := cn.simpleExec("DEALLOCATE ALL"); err != nil {
+		return contextErr(ctx, err)
+	}
+	cn.stmtGen++
+	return nil
+}
+
+// reprepare prepares st again if it was deallocated by DeallocateAll since
+// it was prepared.
+func (st *stmt) reprepare() error {
+	if st.gen == st.cn.stmtGen || st.name == "" {
+		return nil
+	}
+	nst, err := st.cn.prepareTo(st.sql, st.name)
+	if err != nil {
+		return err
+	}
+	*st = *nst
+	return nil
+}
@@ -0,0 +1,58 @@
+package pq
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// The functions below parse numeric columns in text format straight from the
+// read buffer. The strconv functions need a string, and converting the
+// buffer to one allocates for every value.
+
+// maxFastIntDigits is the number of digits that cannot overflow an int64.
+const maxFastIntDigits = 18
+
+// parseTextInt parses an integer column.
+func parseTextInt(s []byte) (int64, error) {
+	digits := s
+	neg := len(s) > 0 && s[0] == '-'
+	if neg || len(s) > 0 && s[0] == '+' {
+		digits = s[1:]
+	}
+	if len(digits) == 0 || len(digits) > maxFastIntDigits {
+		return strconv.ParseInt(string(s), 10, 64)
+	}
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return strconv.ParseInt(string(s), 10, 64)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// parseTextFloat parses a floating-point column. strconv.ParseFloat accepts
+// the NaN, Infinity and -Infinity the server writes for the special values.
+func parseTextFloat(s []byte) (float64, error) {
+	// strconv.ParseFloat only keeps its argument in the error it returns,
+	// so it is safe to pass it a string sharing memory with the buffer as
+	// long as errors are produced from a copy.
+	f, err := strconv.ParseFloat(bytesAsString(s), 64)
+	if err != nil {
+		return strconv.ParseFloat(string(s), 64)
+	}
+	return f, nil
+}
+
+// bytesAsString returns a string sharing memory with b. b must not be
+// modified while the string is in use.
+func bytesAsString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
@@ -0,0 +1,96 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// StatementDescription describes the parameters and result columns of a
+// statement, as reported by the server.
+type StatementDescription struct {
+	ParamOIDs []oid.Oid // type OIDs of $1, $2, ...
+	Fields    []FieldDescription
+}
+
+// FieldDescription describes a result column.
+type FieldDescription struct {
+	Name         string
+	DataTypeOID  oid.Oid
+	DataTypeSize int // negative for variable-width types
+	TypeModifier int // type-specific, e.g. the length of varchar(n) plus 4
+	// TableOID and TableAttributeNumber identify the table column the
+	// column is taken from, by the pg_class oid of the table and the
+	// pg_attribute attnum of the column, and are 0 for other columns.
+	TableOID             oid.Oid
+	TableAttributeNumber int
+}
+
+// Describe has the server parse and describe query without executing it,
+// which validates the statement and returns the types of its parameters and
+// result columns. It uses the unnamed prepared statement, so nothing has to
+// be deallocated afterwards. A runtime panic occurs if c is not a pq
+// connection.
+func Describe(ctx context.Context, c driver.Conn, query string) (*StatementDescription, error) {
+	cn := c.(*conn)
+	if cn.getBad() {
+		return nil, cn.errBadConn()
+	}
+	if cn.inCopy {
+		return nil, errCopyInProgress
+	}
+	query, _, err := cn.translateQuery(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+
+	cn.LockReaderMutex()
+	st, err := cn.prepareTo(query, "")
+	cn.UnlockReaderMutex()
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	return st.description(), nil
+}
+
+// StmtDescription returns the description of a prepared statement obtained
+// when it was prepared, e.g. to convert arguments or build scan targets
+// ahead of execution. The statement is reached through sql.Conn.Raw:
+//
+//	err := conn.Raw(func(dc interface{}) error {
+//		st, err := dc.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+//		if err != nil {
+//			return err
+//		}
+//		defer st.Close()
+//		desc := pq.StmtDescription(st)
+//		...
+//	})
+//
+// A runtime panic occurs if s is not a pq statement.
+func StmtDescription(s driver.Stmt) *StatementDescription {
+	return s.(*stmt).description()
+}
+
+func (st *stmt) description() *StatementDescription {
+	desc := &StatementDescription{
+		ParamOIDs: st.paramTypes,
+		Fields:    make([]FieldDescription, len(st.colNames)),
+	}
+	for i, name := range st.colNames {
+		desc.Fields[i] = FieldDescription{
+			Name:                 name,
+			DataTypeOID:          st.colTyps[i].OID,
+			DataTypeSize:         st.colTyps[i].Len,
+			TypeModifier:         st.colTyps[i].Mod,
+			TableOID:             st.colTyps[i].TableOID,
+			TableAttributeNumber: st.colTyps[i].TableAttr,
+		}
+	}
+	return desc
+}
@@ -22,22 +22,164 @@ supported:
   - dbname - The name of the database to connect to
   - user - The user to sign in as
   - password - The user's password
+  - disable_saslprep - If true, the password is sent without SASLprep
+    normalization in SCRAM and sha256 authentication. See
+    Config.DisableSASLprep.
+  - min_auth_iterations, max_auth_iterations - The range of iteration
+    counts accepted from the server in sha256 and SCRAM authentication,
+    1000 to 10000000 by default. See Config.MinAuthIterations.
   - host - The host to connect to. Values that start with / are for unix
     domain sockets. (default is localhost)
   - port - The port to bind to. (default is 5432)
   - sslmode - Whether or not to use SSL (default is require, this is not
     the default for libpq)
-  - fallback_application_name - An application_name to fall back to if one isn't provided.
+  - fallback_application_name - An application_name to fall back to if one
+    isn't provided by application_name or PGAPPNAME, replacing the default
+    of go-driver. It lets a library using the driver name its connections
+    without overriding the choice of the end user.
   - connect_timeout - Maximum wait for connection, in seconds. Zero or
     not specified means wait indefinitely.
+  - cancel_timeout - Maximum wait for sending a cancel request when the
+    context of a statement is done, in seconds or as a duration such as
+    "500ms". Defaults to connect_timeout, or 10 seconds.
   - sslcert - Cert file location. The file must contain PEM encoded data.
   - sslkey - Key file location. The file must contain PEM encoded data.
   - sslrootcert - The location of the root certificate file. The file
     must contain PEM encoded data.
+  - options - Command-line options sent to the server at connection start,
+    e.g. options='-c search_path=app -c statement_timeout=5s'. Only -c
+    name=value and --name=value settings are accepted.
+  - hostaddr - The IP address to connect to instead of resolving host, which
+    is still used to verify the server certificate. With several hosts, a
+    comma-separated list with an address for each.
+  - scan_location - The time zone of scanned timestamp, timestamptz and
+    date values, e.g. Local, UTC or Asia/Shanghai. See Config.ScanLocation.
+  - prefer_simple_protocol - Set to true to send queries with arguments
+    using the simple query protocol, with the arguments interpolated as
+    escaped literals. See Config.PreferSimpleProtocol.
+  - allow_multiple_statements - Set to false to reject queries sent with
+    the simple query protocol that contain several statements, e.g.
+    "SELECT 1; DROP TABLE t". Statements within procedure bodies that are
+    not dollar-quoted count as several. See
+    Config.RejectMultipleStatements.
+  - max_row_bytes, max_result_bytes - Limits on the size of a single row
+    and of a whole result set, in bytes. See Config.MaxRowBytes.
+  - ping_query - A statement run by Ping to check the connection, e.g.
+    ping_query='SELECT 1'. By default Ping runs no statement. See
+    Config.PingQuery.
+  - replication - Set to database to open a walsender connection, which
+    accepts replication commands as well as SQL, or to true for one that
+    only streams the physical log. Queries with arguments are then sent
+    using the simple query protocol. See the replication subpackage.
+  - send_connection_info - Set to true to report the driver name, version
+    and platform to the server in connection_info, where DBAs can see it in
+    pg_stat_activity. Nothing is reported by default. See
+    Config.ConnectionInfo.
+  - connectionExtraInfo - Set to true to report connection_info as with
+    send_connection_info, including the operating system user.
+  - compression - Set to zlib to accept compressed traffic when the server or
+    a proxy in front of it offers it. See Config.Compression.
+  - max_message_size - The largest message accepted from the server, in
+    bytes. Defaults to 1 GiB. See Config.MaxMessageSize.
+  - binary_parameters - Set to true to send []byte parameters in binary
+    format, which lets queries with arguments run in a single round trip.
+    See Data Types.
+  - disable_prepared_binary_result - Set to true to receive the results of
+    prepared statements in text format only. Use it if a type is decoded
+    incorrectly from its binary format.
+  - text_as_bytes - Set to true to return char, varchar and text values as
+    []byte. See Data Types.
+  - numeric_as_string - Set to true to return numeric values as string, and
+    report string as their scan type. See Data Types.
+  - validate_json - Set to true to check that json.RawMessage parameters
+    are well-formed before sending them. See Data Types.
+  - dbcompatibility - The compatibility mode of the database (A, B, C or
+    PG) if the server does not report it. See QuoteIdentifierConn.
+  - placeholder_format - Either dollar (the default) for $1, $2, ...
+    placeholders, or question to accept ? placeholders. See
+    PlaceholderQuestion.
+  - timestamp_rounding - How time.Time parameters with nanoseconds below a
+    microsecond, which timestamps cannot hold, are sent: round (the
+    default) to the nearest microsecond, truncate, or error to fail the
+    statement.
+  - sql_comment - Where the comment of SQL comment tags is placed in
+    statements: append (the default), prepend, or off to send none. See
+    Tracing.
+  - last_insert_id_column - The key column whose value LastInsertId
+    returns, e.g. last_insert_id_column=id. See Config.LastInsertIDColumn.
+  - search_path - The schema search path of the session, e.g.
+    search_path='tenant_a, public'. See QuoteSearchPath.
+  - bytea_output - Either hex or escape, the output format of bytea values
+    requested at connection start. Both formats are decoded, so this is
+    only needed to override the setting of the server.
+  - default_transaction_isolation - The isolation level of transactions
+    begun with sql.LevelDefault in new sessions, e.g.
+    default_transaction_isolation='repeatable read' or repeatable_read. See
+    Config.DefaultTransactionIsolation.
+  - default_transaction_read_only - If true, new sessions run statements
+    and transactions read only, including transactions begun without
+    TxOptions.ReadOnly. If false, it is set to off, overriding the
+    server default. See Config.DefaultTransactionReadOnly.
+  - client_min_messages - The least severe messages the server sends to
+    new sessions, e.g. warning to keep it from sending notices. See
+    Config.ClientMinMessages.
+  - notice_min_severity - One of debug, log, info, notice or warning; less
+    severe notices are dropped before they reach the notice handler. See
+    Config.MinNoticeSeverity.
+  - statement_timeout, lock_timeout, idle_in_transaction_session_timeout -
+    Session timeouts set at connection start, either in milliseconds or as
+    a duration such as "30s". The server must support the setting.
+  - deadline_statement_timeout - If true, statements executed with a context
+    deadline also get a matching statement_timeout. See
+    Config.DeadlineStatementTimeout.
+  - reWriteBatchedInserts - If true, batches of an INSERT statement with a
+    single VALUES row, executed with a multiple of its parameters, are sent
+    as INSERT statements with many rows. Separate executions of the
+    statement, e.g. ORM insert loops, are not merged. See
+    Config.ReWriteBatchedInserts.
+  - slow_query_threshold - Report queries taking longer than this, either
+    in milliseconds or as a duration such as "1.5s". See Config.OnSlowQuery.
+  - heartbeatPeriod - Check idle connections every period, either in
+    milliseconds or as a duration such as "30s", and discard those that do
+    not answer in time. See Config.HeartbeatPeriod.
+  - idle_keepalive - Make a protocol round trip on connections idle in the
+    pool for longer than this, either in seconds or as a duration such as
+    "5m", to keep load balancers and firewalls from dropping them. The
+    round trip starts at most a quarter of idle_keepalive after it is due.
+    See Config.IdleKeepalive.
+  - read_timeout, write_timeout - The longest a single read from or write
+    to the server may block, either in seconds or as a duration such as
+    "30s", after which the connection fails and is discarded. read_timeout
+    must exceed the run time of the longest statement. See
+    Config.ReadTimeout.
+  - refreshHostsInterval - With several hosts and no load balancing, the
+    interval in seconds at which the standbys streaming from the primary are
+    looked up in pg_stat_replication and added to the hosts, so standbys
+    added later are used without changing the connection string. Zero or
+    not specified disables the lookup. Standbys must listen on the port of
+    the first host. The lookup stops when the sql.DB is closed.
+  - loadBalanceHosts - The order in which the hosts are tried for each
+    connection without autoBalance: ordered (the default), random, to
+    spread connections over the hosts, or random-prefer-primary to try the
+    first host first and the others in a random order. See
+    Config.LoadBalanceHosts.
+  - cnRetries - With autoBalance, the number of times all CNs are tried
+    again when none could be connected to because they were unavailable,
+    e.g. while a CN restarts or switches over. The default is 0.
+  - cnRetryInterval - The wait before the first retry of cnRetries, either
+    in milliseconds or as a duration such as "500ms"; it doubles with each
+    retry, up to 30 seconds. The default is one second. connect_timeout
+    bounds the whole connection attempt, retries included.
 
 Valid values for sslmode are:
 
   - disable - No SSL
+  - allow - Try without SSL first; if the server rejects the connection,
+    e.g. because pg_hba.conf requires SSL, try again with SSL (skip
+    verification)
+  - prefer - Try SSL first (skip verification); if the server does not
+    support it, go on without SSL on the same connection, and if it
+    rejects the connection, try again without SSL
   - require - Always SSL (skip verification)
   - verify-ca - Always SSL (verify that the certificate presented by the
     server was signed by a trusted CA)
@@ -53,13 +195,23 @@ A backslash will escape the next character in values:
 
 	"user=space\ man password='it\'s valid'"
 
-Note that the connection parameter client_encoding (which sets the
-text encoding for the connection) may be set but must be "UTF8",
-matching with the same rules as Postgres. It is an error to provide
-any other value.
+The connection parameter client_encoding sets the text encoding used on the
+wire. When the session's client_encoding, as reported by the server, is not
+UTF8, e.g. because the database was created with GBK or GB18030 encoding, pq
+converts query text, string parameters and text results between UTF-8 and that
+encoding. GBK, GB18030, EUC_CN, BIG5, EUC_JP, SJIS, EUC_KR, LATIN1, LATIN2,
+LATIN9, WIN1250, WIN1251, WIN1252 and KOI8R are supported; bytea values are
+never converted. Setting client_encoding=UTF8 lets the server do the
+conversion instead.
 
 In addition to the parameters listed above, any run-time parameter that can be
-set at backend start time can be set in the connection string.
+set at backend start time can be set in the connection string, e.g. DateStyle,
+IntervalStyle or extra_float_digits. They are sent in the startup packet, so
+setting them takes no extra round trip. A parameter whose name is also that of
+a connection parameter can be given with the param_ prefix, as in
+param_statement_timeout=5min, to be sent to the server as is; the prefix is
+removed before it is sent. Run-time parameters can also be set in
+Config.RuntimeParams.
 
 If any of the environment variables not supported by pq are set, pq will panic during connection
 establishment.  Environment variables have a lower precedence than explicitly
@@ -71,6 +223,19 @@ database/sql does not dictate any specific format for parameter
 markers in query strings, and pq uses the Postgres-native ordinal markers,
 as shown above.
 
+Arguments created with sql.Named are bound to :name or @name markers, which
+are rewritten to ordinal markers before the query is sent. A name may be
+used several times, and named and positional arguments cannot be mixed.
+Named arguments are not supported by prepared statements.
+
+	db.Query("SELECT * FROM users WHERE age > :age AND name <> :name",
+		sql.Named("age", 21), sql.Named("name", "admin"))
+
+A statement has at most MaxBindParameters (65535) parameters, as the
+protocol counts them in 16 bits. Statements beyond the limit fail with an
+error wrapping ErrTooManyParameters before they are sent, so that generators
+of multi-row INSERT statements can split them into several statements.
+
 pq does not support the LastInsertId() method of the Result type in database/sql.
 To return the identifier of an INSERT (or UPDATE or DELETE), use the Postgres
 RETURNING clause with a standard Query or QueryRow call.
@@ -83,22 +248,106 @@ Parameters pass through driver.DefaultParameterConverter before they are handled
 by this package. When the binary_parameters connection option is enabled,
 []byte values are sent directly to the backend as data in binary format.
 
+Slices of bool, int, int32, int64, float32, float64, string and time.Time are
+sent as array literals, as if wrapped in pq.Array:
+
+	db.Query("SELECT * FROM users WHERE id = ANY($1)", []int64{1, 2, 3})
+
+Arrays with NULL elements can be scanned with pq.Array into slices of
+pointers, such as []*int64, or of sql.Scanner values, such as
+[]sql.NullString; nil pointers and invalid values are sent as NULL.
+
+Timestamps hold microseconds, so time.Time parameters are rounded to the
+nearest microsecond before they are sent, or truncated or rejected
+depending on the timestamp_rounding connection option. Elements of arrays
+are sent with nanoseconds, which the server rounds.
+
+json.RawMessage and *json.RawMessage parameters are sent as text, without
+conversion to string, for json and jsonb columns; nil is sent as NULL. With
+validate_json=true they are checked with json.Valid first. Both can also
+be scanned into, as json and jsonb values are returned as []byte:
+
+	var doc json.RawMessage
+	err := db.QueryRow("SELECT doc FROM docs WHERE id = $1", id).Scan(&doc)
+
 This package returns the following types for values from the PostgreSQL backend:
 
-  - integer types smallint, integer, and bigint are returned as int64
-  - floating-point types real and double precision are returned as float64
-  - character types char, varchar, and text are returned as string
+  - integer types tinyint, smallint, integer, and bigint are returned as int64
+  - floating-point types real and double precision are returned as float64;
+    NaN, Infinity and -Infinity map to the IEEE values, which are also sent
+    as such
+  - character types char, varchar, nvarchar2, text, clob, and name are
+    returned as string
   - temporal types date, time, timetz, timestamp, and timestamptz are
     returned as time.Time
   - the boolean type is returned as bool
-  - the bytea type is returned as []byte
+  - the bytea type is returned as []byte, whether the server sends it in
+    hex or escape format
+  - the blob and raw types are returned as []byte
 
 All other types are returned directly from the backend as []byte values in text format.
 
+Earlier versions returned char(n) (bpchar), nvarchar2, name and clob values
+as []byte. Scanning them into []byte or sql.RawBytes still works, since
+database/sql converts the string, but code inspecting the values themselves,
+e.g. a type switch on values scanned into interface{}, now gets a string.
+With text_as_bytes=true all character types are returned as []byte again.
+
+This includes numeric and decimal, which keep their precision. With
+numeric_as_string=true they are returned as string instead, and ScanType
+reports string, for applications and libraries that pick the destination
+of a column by its scan type.
+
+In B compatibility (dolphin) databases, the unsigned types uint1, uint2 and
+uint4 and the year type are returned as int64, uint8 as a decimal string,
+which database/sql converts when scanning into a uint64, and tinyblob,
+mediumblob and longblob as []byte; the leading zeros of zerofill columns
+are dropped. The OIDs of these types are looked up when connecting.
+
+Values of the date type are returned at midnight UTC, or in the scan_location,
+and values of the time and timetz types on January 1 of year 0, in UTC for time
+and in a fixed zone with the offset of the value for timetz. Scan them into
+pq.Date and pq.Time to handle dates and times of day without these fake
+parts; pq.Time keeps the offset of timetz values.
+
+sql.ColumnType.ScanType reports these types, so any of them can be scanned
+into a value of its type, a pointer to one, or a sql.Null of it, which is
+set to invalid or nil for NULL.
+
+For ad-hoc queries, ScanMap scans a row of a sql.Rows into a map keyed by
+column name, and ScanStruct into the fields of a struct, matched by their db
+tag or their name:
+
+	var u struct {
+		ID    int64
+		Email string `db:"email_address"`
+	}
+	for rows.Next() {
+		err := pq.ScanStruct(rows, &u)
+		...
+	}
+
+Values returned as []byte refer to the connection's read buffer and are only
+valid until the next row is read, so scanning into sql.RawBytes does not copy
+them. With text_as_bytes=true this applies to character types as well, which
+allows large text values to be processed without copying.
+
 # Errors
 
 pq may return errors of type *pq.Error which can be interrogated for error details.
-See the pq.Error type for details.
+See the pq.Error type for details. When the server reports the position of
+an error in the query, such as a syntax error, Error.QueryPosition returns
+its line and column.
+
+A statement interrupted because its context was done returns an error for
+which errors.Is(err, context.DeadlineExceeded) or errors.Is(err,
+context.Canceled) holds, and which implements net.Error. The error the
+server returned for the cancelled statement can still be retrieved with
+errors.As.
+
+Once a connection is broken, the errors returned for it satisfy
+errors.Is(err, driver.ErrBadConn), so that database/sql discards it, and
+wrap the network or protocol error that broke it.
 
 # Bulk imports
 
@@ -114,6 +363,33 @@ failed.
 CopyIn uses COPY FROM internally. It is not possible to COPY outside of an
 explicit transaction in pq.
 
+When encoding the rows on the client is the bottleneck, ParallelCopy spreads a
+bulk import over several connections, optionally routing rows to per-partition
+tables.
+
+# Bulk exports
+
+CopyOut runs a COPY ... TO STDOUT statement on a connection, obtained for
+example with sql.Conn.Raw, and writes the data to an io.Writer.
+CopyOutRecords parses data in text or CSV format into the fields of each row,
+reporting NULL fields separately, and CopyOutCSV writes them to a csv.Writer:
+
+	err := conn.Raw(func(c interface{}) error {
+		w := csv.NewWriter(os.Stdout)
+		_, err := pq.CopyOutCSV(ctx, c.(driver.Conn), "COPY users TO STDOUT", pq.CopyFormat{}, w)
+		return err
+	})
+
+CopyOutBinary decodes data in binary format into typed values, given the
+types of the columns, which is the fastest way to export tables with many
+timestamp, numeric or bytea values:
+
+	n, err := pq.CopyOutBinary(ctx, c.(driver.Conn), "COPY events TO STDOUT (FORMAT binary)",
+		[]oid.Oid{oid.T_int8, oid.T_timestamptz, oid.T_numeric},
+		func(values []driver.Value) error {
+			...
+		})
+
 # Notifications
 
 PostgreSQL supports a simple publish/subscribe model over database
@@ -141,20 +417,171 @@ The channel name in both Listen and Unlisten is case sensitive, and can contain
 any characters legal in an identifier. Note that the channel name will be truncated to 63
 bytes by the PostgreSQL server.
 
-# Kerberos Support
+# Tracing
+
+Set Config.Tracer to be notified around connect, query, exec, prepare, COPY
+and transaction control operations. The config must be created by ParseConfig
+and passed to NewConnectorConfig:
+
+	cfg, distCfg, err := pq.ParseConfig("host=localhost dbname=gaussdb")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Tracer = otelpq.NewTracer()
+	connector, err := pq.NewConnectorConfig(cfg, distCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+
+The otelpq package is an OpenTelemetry implementation of Tracer. Like Kerberos
+support below, it is in a separate module.
+
+TraceStartData and SlowQuery carry the fingerprint of the statement, a digest
+of its text with the literals, parameters and comments removed, see
+NormalizeQuery. Use it rather than the text to label per-statement metrics,
+so that statements run with different values share a label.
+
+To find the statements of a trace in the server's logs, such as the slow
+query log, set Config.SQLCommentTags or use WithSQLCommentTags to add a
+comment in the sqlcommenter format to statements:
+
+	ctx = pq.WithSQLCommentTags(ctx, map[string]string{"traceparent": traceparent})
+	rows, err := db.QueryContext(ctx, "SELECT * FROM orders WHERE id = $1", id)
+
+runs the query followed by a comment holding traceparent='...'. Keys and
+values are URL-encoded, so they cannot end the comment. The tags of a
+prepared statement are those of the context it was prepared with; since
+per-query tags make every statement text distinct, prefer static tags for
+statements that are prepared once and executed often.
+
+# Statistics
+
+Set Config.Stats to a *Stats to collect connection counts, authentication and
+TLS handshake timings, completed commands by tag, rows returned, bytes read and
+written, and cancel requests sent. Stats implements expvar.Var:
+
+	stats := &pq.Stats{}
+	expvar.Publish("opengauss", stats)
+	cfg.Stats = stats
+
+Config.OnCommandComplete is called with the command tag of every statement
+completed on a connection, including each statement of a multi-statement
+query, e.g. to log what a maintenance script did:
+
+	cfg.OnCommandComplete = func(info pq.ConnInfo, tag pq.CommandTag) {
+		log.Printf("backend %d: %s", info.ProcessID, tag)
+	}
+
+# Using Connections Directly
+
+Connect returns a *Conn, which runs queries without database/sql, and
+NewConn wraps a connection of a sql.DB within sql.Conn.Raw. Rows returned by
+Conn.Query expose the column types and the command tag of the query, and
+avoid copying values:
+
+	c, err := pq.Connect(ctx, "host=localhost dbname=app")
+	...
+	rows, err := c.Query(ctx, "SELECT id, name FROM users WHERE id = ANY($1)", ids)
+	for rows.Next() {
+		err = rows.Scan(&id, &name)
+	}
+
+A Pool keeps such connections open for reuse, within the bounds of a
+PoolConfig, and checks the idle ones periodically. Acquire hands out a
+connection until Release is called, so any feature of the driver can be used
+on it:
+
+	pool, err := pq.NewPool(ctx, "host=localhost dbname=app replication=database", pq.PoolConfig{MaxConns: 8})
+	...
+	err = pool.AcquireFunc(ctx, func(c *pq.PooledConn) error {
+		cb, err := pq.StartCopyBoth(ctx, c.DriverConn(), "START_REPLICATION ...")
+		...
+	})
+
+Conn.DeclareCursor declares a server-side cursor within a transaction, from
+which Cursor.FetchN reads a given number of rows at a time, to go through
+result sets too large to be held by the client.
+
+BackendPID returns the process ID of the backend of a connection, to find
+it in pg_stat_activity. The commands of a connection can be cancelled from
+another process with its CancelKey, which GetCancelKey returns and which
+can be passed around as text.
+
+Conn.SetApplicationName changes the application_name shown in
+pg_stat_activity on a live connection, e.g. to label the job it runs.
+
+Conn.DeallocateAll deallocates the prepared statements of a session, e.g.
+after DDL changed the tables their plans depend on. Statements prepared on
+the connection before, including those of a sql.Stmt, are prepared again
+when they are next executed.
+
+Conn.SetRole and Conn.SetSessionAuthorization make a connection act as
+another user, quoting the name. The change lasts until the end of the
+current transaction, or, outside of one, until the connection is handed out
+again by a sql.DB or a Pool, which resets it:
+
+	err := sqlConn.Raw(func(dc interface{}) error {
+		return pq.NewConn(dc.(driver.Conn)).SetRole(ctx, endUser, password)
+	})
+
+# Fully-encrypted Database
+
+Built with the enable_ce build tag and linked against openGauss's libpq_ce,
+the driver encrypts parameters bound to encrypted columns and decrypts them
+when scanning, after setting enable_ce=1 in the connection string. Client
+master keys are read by that library from the key store named in CREATE
+CLIENT MASTER KEY: with localkms the keys are files in the directory given
+by localkms_file_path or the LOCALKMS_FILE_PATH environment variable, and
+gs_ktool keys are read through the gs_ktool configuration. The library
+offers no way to supply keys from elsewhere, so there is no key store to
+plug into the driver, and since it reads the directory from the
+environment, all connections of a process share a single
+localkms_file_path.
+
+# Replication
+
+The replication subpackage implements the streaming replication protocol,
+e.g. to consume changes from a logical decoding slot or to stream the
+write-ahead log. It is built on
+StartCopyBoth, which runs the COPY BOTH sub-protocol on a connection.
+Commands entering COPY BOTH through database/sql fail with an error, and the
+connection remains usable.
+
+# SASL Authentication
+
+Besides the sha256 and md5 password methods of openGauss, the driver
+answers the SASL authentication requests of servers and proxies speaking
+the PostgreSQL protocol. SCRAM-SHA-256 is supported out of the box,
+and other mechanisms, e.g. ones backed by hardware tokens, are added with
+RegisterSASLMechanism:
+
+	pq.RegisterSASLMechanism("X-TOKEN", func(ctx context.Context, req pq.SASLRequest) (pq.SASLSession, error) {
+		return newTokenSession(ctx, req.User)
+	})
+
+The sha256 method of openGauss goes through the same registry, under the
+name SHA256Mechanism, so that it can be replaced as well.
+
+Passwords are normalized with SASLprep for SCRAM and sha256 authentication,
+so that passwords with non-ASCII characters match the verifiers stored by
+the server. Passwords SASLprep prohibits, such as ones that are not valid
+UTF-8, are sent as is, like libpq does. GetAuthInfo reports the method a
+connection authenticated with, and for sha256 and SCRAM the iteration count
+the server asked for, which must lie within Config.MinAuthIterations and
+Config.MaxAuthIterations.
 
-If you need support for Kerberos authentication, add the following to your main
-package.
-
-This package is in a separate module so that users who don't need Kerberos
-don't have to download unnecessary dependencies.
+# Kerberos Support
 
-When imported, additional connection string parameters are supported:
+GSSAPI authentication is not supported yet, but its connection string
+parameters are accepted, so that connection strings shared with libpq-based
+clients can be used:
 
   - krbsrvname - GSS (Kerberos) service name when constructing the
     SPN (default is `postgres`). This will be combined with the host
     to form the full SPN: `krbsrvname/host`.
   - krbspn - GSS (Kerberos) SPN. This takes priority over
     `krbsrvname` if present.
+  - gsslib - The GSS library to use, gssapi or, on Windows, sspi.
 */
 package pq
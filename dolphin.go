@@ -0,0 +1,131 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// DBCompatibility returns the compatibility mode of the database the given
+// connection is connected to: "A", "B", "C" or "PG", or "" if it is not
+// known. See Config.DBCompatibility. A runtime panic occurs if c is not a pq
+// connection.
+func DBCompatibility(c driver.Conn) string {
+	return c.(*conn).parameterStatus.dbCompatibility
+}
+
+// QuoteIdentifierConn is like QuoteIdentifier, but quotes the identifier for
+// the given connection. In a B compatibility (dolphin) database identifiers
+// are quoted with backticks, which mean the same whatever the sql_mode. A
+// runtime panic occurs if c is not a pq connection.
+func QuoteIdentifierConn(c driver.Conn, name string) string {
+	if DBCompatibility(c) != "B" {
+		return QuoteIdentifier(name)
+	}
+	if end := strings.IndexRune(name, 0); end > -1 {
+		name = name[:end]
+	}
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// QuoteLiteralConn is like QuoteLiteral, but quotes the literal for the given
+// connection. When standard_conforming_strings is off, as is usual in B
+// compatibility databases, backslashes are escape characters in ordinary
+// string literals, so they are doubled and no E prefix is used. A
+// runtime panic occurs if c is not a pq connection.
+func QuoteLiteralConn(c driver.Conn, literal string) string {
+	cn := c.(*conn)
+	cn.serverParamsMu.Lock()
+	scs := cn.serverParams["standard_conforming_strings"]
+	cn.serverParamsMu.Unlock()
+	if scs != "off" {
+		return QuoteLiteral(literal)
+	}
+	literal = strings.Replace(literal, `\`, `\\`, -1)
+	return `'` + strings.Replace(literal, `'`, `''`, -1) + `'`
+}
+
+// dolphinTypes are the types of the dolphin extension of B compatibility
+// databases which are decoded like built-in types, by name. Unlike built-in
+// types, their OIDs differ between databases, see loadDolphinTypes.
+var dolphinTypes = map[string]reflect.Type{
+	"uint1":      reflect.TypeOf(int64(0)),
+	"uint2":      reflect.TypeOf(int64(0)),
+	"uint4":      reflect.TypeOf(int64(0)),
+	"uint8":      reflect.TypeOf(""),
+	"year":       reflect.TypeOf(int64(0)),
+	"tinyblob":   reflect.TypeOf([]byte(nil)),
+	"mediumblob": reflect.TypeOf([]byte(nil)),
+	"longblob":   reflect.TypeOf([]byte(nil)),
+}
+
+// loadDolphinTypes looks up the OIDs of dolphinTypes in a B compatibility
+// database, so that values of these types are decoded.
+func (cn *conn) loadDolphinTypes() error {
+	names := make([]string, 0, len(dolphinTypes))
+	for name := range dolphinTypes {
+		names = append(names, QuoteLiteral(name))
+	}
+	sort.Strings(names)
+	rows, err := cn.simpleQuery("SELECT oid, typname FROM pg_catalog.pg_type WHERE typname IN (" +
+		strings.Join(names, ", ") + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	types := make(map[oid.Oid]string)
+	row := make([]driver.Value, 2)
+	for {
+		if err := rows.Next(row); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		o, err := strconv.ParseUint(asString(row[0]), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid type OID: %w", err)
+		}
+		types[oid.Oid(o)] = asString(row[1])
+	}
+	cn.parameterStatus.dolphinTypes = types
+	return nil
+}
+
+// dolphinType returns the name of typ if it is one of dolphinTypes.
+func (p *parameterStatus) dolphinType(typ oid.Oid) (string, bool) {
+	name, ok := p.dolphinTypes[typ]
+	return name, ok
+}
+
+// decodeDolphin decodes a value in text format of one of dolphinTypes.
+func decodeDolphin(name string, s []byte) (interface{}, error) {
+	switch name {
+	case "uint8":
+		// uint64 is not a driver.Value, and not every value fits in int64
+		if _, err := strconv.ParseUint(string(s), 10, 64); err != nil {
+			return nil, err
+		}
+		return string(s), nil
+	case "tinyblob", "mediumblob", "longblob":
+		return decodeHexBlob(s)
+	default:
+		return parseTextInt(s)
+	}
+}
+
+// decodeHexBlob decodes a blob or raw value, which the server sends as hex
+// digits.
+func decodeHexBlob(s []byte) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(s)))
+	if _, err := hex.Decode(b, s); err != nil {
+		return nil, fmt.Errorf("pq: invalid hex in blob value: %w", err)
+	}
+	return b, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql/driver"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -34,23 +35,36 @@ func encode(parameterStatus *parameterStatus, x interface{}, pgtypOid oid.Oid) (
 	case int64:
 		return strconv.AppendInt(nil, v, 10), nil
 	case float64:
-		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+		return appendFloat(nil, v, 'f', 64), nil
 	case []byte:
 		if pgtypOid == oid.T_bytea {
 			return encodeBytea(parameterStatus.serverVersion, v), nil
 		}
 
 		return v, nil
+	case json.RawMessage:
+		if parameterStatus == nil || parameterStatus.clientEncoding == nil {
+			return v, nil
+		}
+		s, err := parameterStatus.toServer(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert to client_encoding: %w", err)
+		}
+		return []byte(s), nil
 	case string:
 		if pgtypOid == oid.T_bytea {
 			return encodeBytea(parameterStatus.serverVersion, []byte(v)), nil
 		}
 
-		return []byte(v), nil
+		s, err := parameterStatus.toServer(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert to client_encoding: %w", err)
+		}
+		return []byte(s), nil
 	case bool:
 		return strconv.AppendBool(nil, v), nil
 	case time.Time:
-		return formatTs(v), nil
+		return parameterStatus.formatTs(v)
 
 	default:
 		return nil, fmt.Errorf("encode: unknown type for %T", v)
@@ -123,6 +137,9 @@ func decode(parameterStatus *parameterStatus, s []byte, typ oid.Oid, mod int, f
 	case formatBinary:
 		return binaryDecode(parameterStatus, s, typ)
 	case formatText:
+		if typ != oid.T_bytea {
+			s = parameterStatus.fromServer(s)
+		}
 		if !disable_text_conversion {
 			return textDecode(parameterStatus, s, typ)
 		} else {
@@ -149,41 +166,237 @@ func binaryDecode(parameterStatus *parameterStatus, s []byte, typ oid.Oid) (inte
 			return nil, fmt.Errorf("cannot decode UUID binary: %w", err)
 		}
 		return b, nil
+	}
 
+	// the types below are only received in binary format by binary COPY
+	if err := checkBinaryLen(s, typ); err != nil {
+		return nil, err
+	}
+	switch typ {
+	case oid.T_int1:
+		return int64(s[0]), nil
+	case oid.T_bool:
+		return s[0] != 0, nil
+	case oid.T_float4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(s))), nil
+	case oid.T_float8:
+		return math.Float64frombits(binary.BigEndian.Uint64(s)), nil
+	case oid.T_char, oid.T_bpchar, oid.T_varchar, oid.T_nvarchar2, oid.T_text, oid.T_name, oid.T_clob:
+		return textDecode(parameterStatus, parameterStatus.fromServer(s), typ)
+	case oid.T_blob, oid.T_raw:
+		return s, nil
+	case oid.T_numeric:
+		n, err := decodeNumericBinary(s)
+		if err != nil {
+			return nil, err
+		}
+		if parameterStatus.numericAsString && !parameterStatus.textAsBytes {
+			return string(n), nil
+		}
+		return n, nil
+	case oid.T_date:
+		switch days := int32(binary.BigEndian.Uint32(s)); days {
+		case math.MaxInt32:
+			return parseTs(nil, "infinity"), nil
+		case math.MinInt32:
+			return parseTs(nil, "-infinity"), nil
+		default:
+			loc := parameterStatus.scanLocation
+			if loc == nil {
+				loc = time.UTC
+			}
+			return time.Date(2000, time.January, 1+int(days), 0, 0, 0, 0, loc), nil
+		}
+	case oid.T_timestamp, oid.T_timestamptz:
+		us := int64(binary.BigEndian.Uint64(s))
+		switch us {
+		case math.MaxInt64:
+			return parseTs(nil, "infinity"), nil
+		case math.MinInt64:
+			return parseTs(nil, "-infinity"), nil
+		}
+		t := time.Unix(pgEpochUnix+us/1000000, us%1000000*1000).UTC()
+		if typ == oid.T_timestamp {
+			if loc := parameterStatus.scanLocation; loc != nil {
+				// reinterpret the wall clock time, as for the text format
+				return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+					t.Nanosecond(), loc), nil
+			}
+			return t, nil
+		}
+		if loc := parameterStatus.scanLocation; loc != nil {
+			return t.In(loc), nil
+		}
+		if loc := parameterStatus.currentLocation; loc != nil {
+			return t.In(loc), nil
+		}
+		return t, nil
+	case oid.T_time:
+		us := int64(binary.BigEndian.Uint64(s))
+		return time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(us) * time.Microsecond), nil
+	case oid.T_timetz:
+		us := int64(binary.BigEndian.Uint64(s))
+		// the zone is in seconds west of UTC
+		zone := time.FixedZone("", -int(int32(binary.BigEndian.Uint32(s[8:]))))
+		return time.Date(0, time.January, 1, 0, 0, 0, 0, zone).Add(time.Duration(us) * time.Microsecond), nil
 	default:
 		return nil, fmt.Errorf("don't know how to decode binary parameter of type %d", uint32(typ))
 	}
 }
 
+// pgEpochUnix is the Unix time of 2000-01-01 00:00:00 UTC, from which the
+// binary formats of the date and time types count.
+const pgEpochUnix = 946684800
+
+// checkBinaryLen returns an error if s is too short for the binary format
+// of typ.
+func checkBinaryLen(s []byte, typ oid.Oid) error {
+	n := 0
+	switch typ {
+	case oid.T_int1, oid.T_bool:
+		n = 1
+	case oid.T_float4, oid.T_date:
+		n = 4
+	case oid.T_float8, oid.T_timestamp, oid.T_timestamptz, oid.T_time:
+		n = 8
+	case oid.T_timetz:
+		n = 12
+	}
+	if len(s) < n {
+		return fmt.Errorf("pq: %d bytes are too short for binary %s", len(s), oid.TypeName[typ])
+	}
+	return nil
+}
+
+// decodeNumericBinary returns the text format of a numeric value in binary
+// format: the number of base 10000 digits, the weight of the first one, the
+// sign, the display scale and the digits, all 16 bits wide.
+func decodeNumericBinary(s []byte) ([]byte, error) {
+	if len(s) < 8 {
+		return nil, fmt.Errorf("pq: %d bytes are too short for binary numeric", len(s))
+	}
+	ndigits := int(binary.BigEndian.Uint16(s))
+	weight := int(int16(binary.BigEndian.Uint16(s[2:])))
+	sign := binary.BigEndian.Uint16(s[4:])
+	dscale := int(binary.BigEndian.Uint16(s[6:]))
+	if len(s) < 8+2*ndigits {
+		return nil, fmt.Errorf("pq: %d bytes are too short for binary numeric with %d digits", len(s), ndigits)
+	}
+	switch sign {
+	case 0xC000:
+		return []byte("NaN"), nil
+	case 0xD000:
+		return []byte("Infinity"), nil
+	case 0xF000:
+		return []byte("-Infinity"), nil
+	}
+	digit := func(i int) int {
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(s[8+2*i:]))
+	}
+	appendDigits := func(b []byte, d int) []byte {
+		return append(b, byte('0'+d/1000), byte('0'+d/100%10), byte('0'+d/10%10), byte('0'+d%10))
+	}
+
+	b := make([]byte, 0, 4*(ndigits+1)+dscale+2)
+	if sign == 0x4000 {
+		b = append(b, '-')
+	}
+	if weight < 0 {
+		b = append(b, '0')
+	} else {
+		b = strconv.AppendInt(b, int64(digit(0)), 10)
+		for i := 1; i <= weight; i++ {
+			b = appendDigits(b, digit(i))
+		}
+	}
+	if dscale > 0 {
+		b = append(b, '.')
+		end := len(b) + dscale
+		for i := weight + 1; len(b) < end; i++ {
+			b = appendDigits(b, digit(i))
+		}
+		b = b[:end]
+	}
+	return b, nil
+}
+
 func textDecode(parameterStatus *parameterStatus, s []byte, typ oid.Oid) (interface{}, error) {
 	switch typ {
-	case oid.T_char, oid.T_varchar, oid.T_text:
+	case oid.T_char, oid.T_bpchar, oid.T_varchar, oid.T_nvarchar2, oid.T_text, oid.T_name, oid.T_clob:
+		if parameterStatus.textAsBytes {
+			return s, nil
+		}
 		return string(s), nil
 	case oid.T_bytea:
-		return parseBytea(s) // unescape
+		return parameterStatus.parseBytea(s) // unescape
 	case oid.T_timestamptz:
+		if loc := parameterStatus.scanLocation; loc != nil {
+			if t, ok := parseTs(nil, string(s)).(time.Time); ok {
+				return t.In(loc), nil
+			}
+		}
 		return parseTs(parameterStatus.currentLocation, string(s)), nil
 	case oid.T_timestamp, oid.T_date:
-		return parseTs(nil, string(s)), nil
+		v := parseTs(nil, string(s))
+		if t, ok := v.(time.Time); ok && parameterStatus.scanLocation != nil {
+			// reinterpret the wall clock time, which was parsed as UTC
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+				t.Nanosecond(), parameterStatus.scanLocation), nil
+		}
+		return v, nil
 	case oid.T_time:
 		return mustParse("15:04:05", typ, s)
 	case oid.T_timetz:
-		return mustParse("15:04:05-07", typ, s)
+		t, err := mustParse("15:04:05-07", typ, s)
+		if err != nil {
+			return nil, err
+		}
+		// keep the offset in a fixed zone even if it is zero or that of
+		// time.Local, so that it is not mistaken for a time without time zone
+		_, offset := t.Zone()
+		return t.In(time.FixedZone("", offset)), nil
 	case oid.T_bool:
 		return s[0] == 't', nil
-	case oid.T_int8, oid.T_int4, oid.T_int2:
-		return strconv.ParseInt(string(s), 10, 64)
+	case oid.T_int8, oid.T_int4, oid.T_int2, oid.T_int1:
+		return parseTextInt(s)
+	case oid.T_blob, oid.T_raw:
+		return decodeHexBlob(s)
+	case oid.T_numeric:
+		if parameterStatus.numericAsString && !parameterStatus.textAsBytes {
+			return string(s), nil
+		}
 	case oid.T_float4, oid.T_float8:
 		// We always use 64 bit parsing, regardless of whether the input text is for
 		// a float4 or float8, because clients expect float64s for all float datatypes
 		// and returning a 32-bit parsed float64 produces lossy results.
-		return strconv.ParseFloat(string(s), 64)
+		return parseTextFloat(s)
 	default:
+		if name, ok := parameterStatus.dolphinType(typ); ok {
+			return decodeDolphin(name, s)
+		}
 	}
 
 	return s, nil
 }
 
+// appendFloat appends f in the strconv format verb, but with the NaN,
+// Infinity and -Infinity that float4 and float8 input accepts instead of the
+// NaN, +Inf and -Inf of strconv.
+func appendFloat(b []byte, f float64, verb byte, bitSize int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, "NaN"...)
+	case math.IsInf(f, 1):
+		return append(b, "Infinity"...)
+	case math.IsInf(f, -1):
+		return append(b, "-Infinity"...)
+	}
+	return strconv.AppendFloat(b, f, verb, -1, bitSize)
+}
+
 // appendEncodedText encodes item in text format as required by COPY
 // and appends to buf
 func appendEncodedText(parameterStatus *parameterStatus, buf []byte, x interface{}) ([]byte, error) {
@@ -191,16 +404,30 @@ func appendEncodedText(parameterStatus *parameterStatus, buf []byte, x interface
 	case int64:
 		return strconv.AppendInt(buf, v, 10), nil
 	case float64:
-		return strconv.AppendFloat(buf, v, 'f', -1, 64), nil
+		return appendFloat(buf, v, 'f', 64), nil
 	case []byte:
 		encodedBytea := encodeBytea(parameterStatus.serverVersion, v)
 		return appendEscapedText(buf, string(encodedBytea)), nil
+	case json.RawMessage:
+		s, err := parameterStatus.toServer(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert to client_encoding: %w", err)
+		}
+		return appendEscapedText(buf, s), nil
 	case string:
-		return appendEscapedText(buf, v), nil
+		s, err := parameterStatus.toServer(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert to client_encoding: %w", err)
+		}
+		return appendEscapedText(buf, s), nil
 	case bool:
 		return strconv.AppendBool(buf, v), nil
 	case time.Time:
-		return append(buf, formatTs(v)...), nil
+		b, err := parameterStatus.formatTs(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, b...), nil
 	case nil:
 		return append(buf, "\\N"...), nil
 	default:
@@ -401,6 +628,53 @@ func disableInfinityTs() {
 	infinityTsEnabled = false
 }
 
+// sessionLocation returns the location described by a TimeZone parameter
+// value, or nil if it cannot be resolved. Besides names from the time zone
+// database such as "PRC" or "Asia/Shanghai", the server reports zones set as
+// an offset, e.g. with SET TIME ZONE '+08:00', in POSIX form: "<+08>-08" or
+// "UTC-8". POSIX offsets count hours west of Greenwich, so their sign is the
+// opposite of the ISO 8601 one.
+func sessionLocation(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+
+	// the zone abbreviation is either quoted in angle brackets or alphabetic;
+	// in the latter case the whole value is clearer as a name
+	var abbrev, rest string
+	if strings.HasPrefix(name, "<") {
+		end := strings.IndexByte(name, '>')
+		if end < 0 {
+			return nil
+		}
+		abbrev, rest = name[1:end], name[end+1:]
+	} else {
+		end := strings.IndexAny(name, "+-0123456789")
+		if end <= 0 {
+			return nil
+		}
+		abbrev, rest = name, name[end:]
+	}
+
+	sign := -1
+	switch {
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "-"):
+		sign = 1
+		rest = rest[1:]
+	}
+	var secs int
+	for i, part := range strings.Split(rest, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || i > 2 || n < 0 {
+			return nil
+		}
+		secs += n * []int{3600, 60, 1}[i]
+	}
+	return time.FixedZone(abbrev, sign*secs)
+}
+
 // This is a time function specific to the Postgres default DateStyle
 // setting ("ISO, MDY"), the only one we currently support. This
 // accounts for the discrepancies between the parsing available with
@@ -535,6 +809,35 @@ func ParseTimestamp(currentLocation *time.Location, str string) (time.Time, erro
 	return t, p.err
 }
 
+// TimestampRounding is how time.Time parameters with more than microsecond
+// precision, which timestamps cannot hold, are sent to the server.
+type TimestampRounding string
+
+const (
+	// TimestampRound rounds to the nearest microsecond, halfway values up.
+	TimestampRound TimestampRounding = "round"
+	// TimestampTruncate drops the nanoseconds below a microsecond.
+	TimestampTruncate TimestampRounding = "truncate"
+	// TimestampError fails the statement with an error.
+	TimestampError TimestampRounding = "error"
+)
+
+// formatTs formats t into a format postgres understands, applying the
+// TimestampRounding of the connection if p is not nil.
+func (p *parameterStatus) formatTs(t time.Time) ([]byte, error) {
+	if p != nil && t.Nanosecond()%1000 != 0 {
+		switch p.timestampRounding {
+		case TimestampTruncate:
+			t = t.Truncate(time.Microsecond)
+		case TimestampError:
+			return nil, fmt.Errorf("pq: time %v has sub-microsecond precision", t)
+		default:
+			t = t.Round(time.Microsecond)
+		}
+	}
+	return formatTs(t), nil
+}
+
 // formatTs formats t into a format postgres understands.
 func formatTs(t time.Time) []byte {
 	if infinityTsEnabled {
@@ -595,6 +898,8 @@ func parseBytea(s []byte) (result []byte, err error) {
 			return nil, err
 		}
 	} else {
+		// an empty value is not NULL
+		result = make([]byte, 0, len(s))
 		for len(s) > 0 {
 			if s[0] == '\\' {
 				// escaped '\\'
@@ -606,13 +911,12 @@ func parseBytea(s []byte) (result []byte, err error) {
 
 				// '\\' followed by an octal number
 				if len(s) < 4 {
-					return nil, fmt.Errorf("invalid bytea sequence %v", s)
+					return nil, fmt.Errorf("invalid bytea sequence %q", s)
 				}
-				r, err := strconv.ParseInt(string(s[1:4]), 8, 9)
-				if err != nil {
-					return nil, fmt.Errorf("could not parse bytea value: %s", err.Error())
+				if s[1] < '0' || s[1] > '3' || !isOctal(s[2]) || !isOctal(s[3]) {
+					return nil, fmt.Errorf("invalid bytea sequence %q", s[:4])
 				}
-				result = append(result, byte(r))
+				result = append(result, (s[1]-'0')<<6|(s[2]-'0')<<3|(s[3]-'0'))
 				s = s[4:]
 			} else {
 				// We hit an unescaped, raw byte.  Try to read in as many as
@@ -631,6 +935,35 @@ func parseBytea(s []byte) (result []byte, err error) {
 	return result, nil
 }
 
+func isOctal(c byte) bool {
+	return '0' <= c && c <= '7'
+}
+
+// parseBytea is like the package-level parseBytea, but decodes values in hex
+// format into the row buffer instead of allocating. The returned slice is
+// valid until the next row is read.
+func (p *parameterStatus) parseBytea(s []byte) ([]byte, error) {
+	n := hex.DecodedLen(len(s) - 2)
+	if !bytes.HasPrefix(s, []byte("\\x")) || n > maxPooledReadBuffer {
+		return parseBytea(s)
+	}
+	start := len(p.rowBuf)
+	p.rowBuf = append(p.rowBuf, make([]byte, n)...)
+	v := p.rowBuf[start : start+n : start+n]
+	if _, err := hex.Decode(v, s[2:]); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// resetRowBuf makes the row buffer available for the next row.
+func (p *parameterStatus) resetRowBuf() {
+	if cap(p.rowBuf) > maxPooledReadBuffer {
+		p.rowBuf = nil
+	}
+	p.rowBuf = p.rowBuf[:0]
+}
+
 func encodeBytea(serverVersion int, v []byte) (result []byte) {
 	if serverVersion >= 90000 {
 		// Use the hex format if we know that the server supports it
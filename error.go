@@ -1,7 +1,9 @@
 package pq
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,6 +11,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -359,6 +362,13 @@ type Error struct {
 	Line             string
 	Routine          string
 	err              error
+
+	// SeverityUnlocalized is Severity in English whatever lc_messages, if
+	// the server sends it.
+	SeverityUnlocalized string
+
+	// line and column of Position in the query, see setQueryPosition
+	queryLine, queryColumn int
 }
 
 func parseError(r *readBuf, cn *conn) *Error { // TODO: return error
@@ -382,12 +392,17 @@ func parseError(r *readBuf, cn *conn) *Error { // TODO: return error
 			clientlogic_read_error(client_logic, Cchar(t), cmsg, cl_refresh_params)
 			Cfree(unsafe.Pointer(cmsg))
 		}
+		if cn != nil {
+			msg = cn.parameterStatus.fromServerString(msg)
+		}
 		switch t {
 		case 'S':
 			err.Severity = msg
 			if err.IsFatal() {
 				err.err = driver.ErrBadConn
 			}
+		case 'V':
+			err.SeverityUnlocalized = msg
 		case 'C':
 			err.Code = ErrorCode(msg)
 		case 'M':
@@ -450,6 +465,9 @@ func parseError(r *readBuf, cn *conn) *Error { // TODO: return error
 	if cn.pgconn != nil {
 		delete_cl_refresh_params(cl_refresh_params)
 	}
+	if cn != nil && err.Position != "" {
+		err.setQueryPosition(cn.stmtQuery)
+	}
 
 	return err
 }
@@ -458,6 +476,37 @@ func (e *Error) Error() string {
 	return "pq: " + redactPW(e.Message)
 }
 
+// QueryPosition returns the line and column, both starting at 1, of the
+// Position of the error in the query the driver sent, e.g. of a syntax
+// error. ok is false if the error has no position, or the query it refers
+// to is not known. The query sent differs from the one passed to the driver
+// when placeholders were rewritten or arguments interpolated.
+func (e *Error) QueryPosition() (line, column int, ok bool) {
+	return e.queryLine, e.queryColumn, e.queryLine > 0
+}
+
+// setQueryPosition sets the line and column of the Position of the error in
+// query.
+func (e *Error) setQueryPosition(query string) {
+	pos, err := strconv.Atoi(e.Position)
+	if err != nil || pos < 1 {
+		return
+	}
+	// Position counts characters from 1
+	line, column := 1, 1
+	for _, r := range query {
+		if pos--; pos == 0 {
+			e.queryLine, e.queryColumn = line, column
+			return
+		}
+		if r == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+}
+
 func (e *Error) Unwrap() error {
 	return e.err
 }
@@ -473,6 +522,8 @@ func (e *Error) Get(k byte) (v string) {
 	switch k {
 	case 'S':
 		return e.Severity
+	case 'V':
+		return e.SeverityUnlocalized
 	case 'C':
 		return string(e.Code)
 	case 'M':
@@ -544,19 +595,19 @@ func (cn *conn) errRecover(err *error) { // TODO: recover with closure
 		panic(v)
 	case *Error:
 		if v.IsFatal() {
-			*err = driver.ErrBadConn
+			*err = badConn(v)
 		} else {
 			*err = v
 		}
 	case *net.OpError:
-		cn.setBad()
+		cn.setBadErr(v)
 		*err = v
 	case *safeRetryError:
-		cn.setBad()
-		*err = driver.ErrBadConn
+		cn.setBadErr(v.Err)
+		*err = badConn(v.Err)
 	case error:
 		if v == io.EOF || v.(error).Error() == "remote error: handshake failure" {
-			*err = driver.ErrBadConn
+			*err = badConn(v)
 		} else {
 			*err = v
 		}
@@ -568,9 +619,95 @@ func (cn *conn) errRecover(err *error) { // TODO: recover with closure
 
 	// Any time we return ErrBadConn, we need to remember it since *Tx doesn't
 	// mark the connection bad in database/sql.
-	if *err == driver.ErrBadConn {
-		cn.setBad()
+	if errors.Is(*err, driver.ErrBadConn) {
+		cn.setBadErr(*err)
+	}
+}
+
+// badConnError reports that a connection is broken because of err. It
+// satisfies errors.Is(err, driver.ErrBadConn), so that database/sql discards
+// the connection, and unwraps to err.
+type badConnError struct {
+	err error
+}
+
+// badConn returns an error reporting that a connection is broken because of
+// err.
+func badConn(err error) error {
+	if err == nil || errors.Is(err, driver.ErrBadConn) {
+		if err == nil {
+			return driver.ErrBadConn
+		}
+		return err
+	}
+	return &badConnError{err: err}
+}
+
+func (e *badConnError) Error() string {
+	return driver.ErrBadConn.Error() + ": " + e.err.Error()
+}
+
+func (e *badConnError) Unwrap() error {
+	return e.err
+}
+
+func (e *badConnError) Is(target error) bool {
+	return target == driver.ErrBadConn
+}
+
+// contextError is returned by a statement which failed because its context
+// was done. It wraps the error of the context, so that errors.Is(err,
+// context.DeadlineExceeded) or errors.Is(err, context.Canceled) holds, and
+// implements net.Error. errors.As finds the error the statement failed with,
+// usually an *Error with code query_canceled.
+type contextError struct {
+	ctxErr error
+	err    error
+}
+
+// contextErr returns err wrapped in a *contextError if ctx is done, as err
+// is then most likely the result of the cancel request sent for ctx.
+func contextErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
 	}
+	ctxErr := ctx.Err()
+	if ctxErr == nil {
+		// the deadline may have passed just before the timer of ctx fires,
+		// see ctxWatch.expired
+		d, ok := ctx.Deadline()
+		if !ok || time.Now().Before(d) {
+			return err
+		}
+		ctxErr = context.DeadlineExceeded
+	}
+	var ce *contextError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &contextError{ctxErr: ctxErr, err: err}
+}
+
+func (e *contextError) Error() string {
+	return fmt.Sprintf("pq: %v (%v)", e.ctxErr, e.err)
+}
+
+func (e *contextError) Unwrap() error {
+	return e.ctxErr
+}
+
+func (e *contextError) As(target interface{}) bool {
+	return errors.As(e.err, target)
+}
+
+// Timeout reports whether the deadline of the context passed.
+func (e *contextError) Timeout() bool {
+	return errors.Is(e.ctxErr, context.DeadlineExceeded)
+}
+
+// Temporary reports true: the statement may succeed with another context.
+func (e *contextError) Temporary() bool {
+	return true
 }
 
 type connectError struct {
@@ -0,0 +1,186 @@
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// fakeServer is the server side of a connection to a fake backend, which
+// speaks just enough of the protocol for the tests driving it.
+type fakeServer struct {
+	t   *testing.T
+	c   net.Conn
+	out []byte
+}
+
+// send queues a message, sent by flush.
+func (s *fakeServer) send(typ byte, body ...[]byte) {
+	n := 4
+	for _, b := range body {
+		n += len(b)
+	}
+	s.out = append(s.out, typ, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	for _, b := range body {
+		s.out = append(s.out, b...)
+	}
+}
+
+func (s *fakeServer) flush() error {
+	_, err := s.c.Write(s.out)
+	s.out = s.out[:0]
+	return err
+}
+
+// recv reads a message of the client.
+func (s *fakeServer) recv() (byte, []byte, error) {
+	var h [5]byte
+	if _, err := io.ReadFull(s.c, h[:]); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(h[1:])-4)
+	if _, err := io.ReadFull(s.c, body); err != nil {
+		return 0, nil, err
+	}
+	return h[0], body, nil
+}
+
+// query reads messages up to the next Query and returns its text. Sync
+// messages are answered with ReadyForQuery in the status given, and
+// Terminate ends the connection with io.EOF.
+func (s *fakeServer) query(status byte) (string, error) {
+	for {
+		typ, body, err := s.recv()
+		if err != nil {
+			return "", err
+		}
+		switch typ {
+		case 'Q':
+			return string(cstr(body)), nil
+		case 'S':
+			s.ready(status)
+			if err := s.flush(); err != nil {
+				return "", err
+			}
+		case 'X':
+			return "", io.EOF
+		default:
+			s.t.Errorf("unexpected message %q", typ)
+			return "", io.EOF
+		}
+	}
+}
+
+func (s *fakeServer) ready(status byte) {
+	s.send('Z', []byte{status})
+}
+
+func (s *fakeServer) complete(tag string) {
+	s.send('C', cstrBytes(tag))
+}
+
+func (s *fakeServer) error(code, msg string) {
+	s.send('E', []byte("SERROR\x00C"+code+"\x00M"+msg+"\x00\x00"))
+}
+
+// describe sends the description of text columns of the given types.
+func (s *fakeServer) describe(oids []oid.Oid) {
+	desc := int16Bytes(len(oids))
+	for i, o := range oids {
+		desc = append(desc, cstrBytes("c"+string(rune('a'+i%26)))...)
+		desc = append(desc, 0, 0, 0, 0, 0, 0)
+		desc = append(desc, int32Bytes(int(o))...)
+		desc = append(desc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0)
+	}
+	s.send('T', desc)
+}
+
+// row sends a row of text values, nil for NULL.
+func (s *fakeServer) row(values ...[]byte) {
+	row := int16Bytes(len(values))
+	for _, v := range values {
+		if v == nil {
+			row = append(row, int32Bytes(-1)...)
+			continue
+		}
+		row = append(row, int32Bytes(len(v))...)
+		row = append(row, v...)
+	}
+	s.send('D', row)
+}
+
+// startup answers the startup packet of the client.
+func (s *fakeServer) startup() error {
+	var header [4]byte
+	if _, err := io.ReadFull(s.c, header[:]); err != nil {
+		return err
+	}
+	startup := make([]byte, binary.BigEndian.Uint32(header[:])-4)
+	if _, err := io.ReadFull(s.c, startup); err != nil {
+		return err
+	}
+	s.send('R', int32Bytes(0))
+	for _, p := range [][2]string{{"server_version", "9.2.4"}, {"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"}, {"integer_datetimes", "on"}, {"standard_conforming_strings", "on"}} {
+		s.send('S', cstrBytes(p[0]), cstrBytes(p[1]))
+	}
+	s.send('K', int32Bytes(1), int32Bytes(2))
+	s.ready('I')
+	return s.flush()
+}
+
+func cstr(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+func cstrBytes(s string) []byte { return append([]byte(s), 0) }
+
+func int16Bytes(n int) []byte { return []byte{byte(n >> 8), byte(n)} }
+
+func int32Bytes(n int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+// openFakeDB opens a DB with the settings in dsn whose connections are
+// served by serve, once they are started up. dials, if not nil, counts the
+// connections opened.
+func openFakeDB(t *testing.T, dsn string, dials *int32, serve func(s *fakeServer)) *sql.DB {
+	cfg, _, err := ParseConfig("host=localhost user=test sslmode=disable " + dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dials != nil {
+			atomic.AddInt32(dials, 1)
+		}
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			s := &fakeServer{t: t, c: server}
+			if s.startup() == nil {
+				serve(s)
+			}
+		}()
+		return client, nil
+	}
+	c, err := NewConnectorConfig(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
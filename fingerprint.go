@@ -0,0 +1,121 @@
+package pq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// placeholderListRegex matches lists of normalized literals, e.g. the
+// values of an IN list, and rowListRegex the rows of a multi-row VALUES
+// list once its rows are normalized.
+var (
+	placeholderListRegex = regexp.MustCompile(`\?(?:, ?\?)+`)
+	rowListRegex         = regexp.MustCompile(`\(\?\)(?:, ?\(\?\))+`)
+)
+
+// NormalizeQuery returns q with its literals and parameters replaced by ?,
+// lists of them, such as the values of an IN list, and the rows of a
+// multi-row VALUES list collapsed into one, comments removed and whitespace
+// collapsed, so that executions of a statement with different values have
+// the same text:
+//
+//	SELECT * FROM t WHERE id IN ($1, $2) AND name = 'x' -- comment
+//	SELECT * FROM t WHERE id IN (?) AND name = ?
+//
+// Quoted identifiers and keywords are kept as they are. Backslashes are
+// assumed to escape quotes only in escape string literals such as E'\n', as
+// with standard_conforming_strings on.
+func NormalizeQuery(q string) string {
+	b := make([]byte, 0, len(q))
+	space := false
+	emit := func(s string) {
+		if space && len(b) > 0 {
+			b = append(b, ' ')
+		}
+		space = false
+		b = append(b, s...)
+	}
+	for _, seg := range splitSQL(q, false) {
+		s := seg.text
+		if !seg.code {
+			switch {
+			case isComment(s):
+				space = true
+			case s[0] == '"' || s[0] == '`':
+				emit(s)
+			default:
+				// drop the prefix of E'', B'', X'' and N'' literals
+				if n := len(b); !space && n > 0 && isLiteralPrefix(b[n-1]) && (n == 1 || !isIdentChar(b[n-2])) {
+					b = b[:n-1]
+				}
+				emit("?")
+			}
+			continue
+		}
+		for i := 0; i < len(s); {
+			c := s[i]
+			startsWord := i == 0 || !isIdentChar(s[i-1])
+			switch {
+			case isSpace(c):
+				space = true
+				i++
+			case c == '$' && startsWord && i+1 < len(s) && isDigit(s[i+1]):
+				for i++; i < len(s) && isDigit(s[i]); i++ {
+				}
+				emit("?")
+			case startsWord && (isDigit(c) || c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+				i = scanNumber(s, i)
+				emit("?")
+			default:
+				emit(s[i : i+1])
+				i++
+			}
+		}
+	}
+	b = placeholderListRegex.ReplaceAll(b, []byte("?"))
+	return string(rowListRegex.ReplaceAll(b, []byte("(?)")))
+}
+
+// QueryFingerprint returns a short digest of NormalizeQuery(q), the same
+// for all executions of a statement whatever its values. It is meant as a
+// label for metrics and logs, which the text of queries would give too many
+// values to.
+func QueryFingerprint(q string) string {
+	sum := sha256.Sum256([]byte(NormalizeQuery(q)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLiteralPrefix(c byte) bool {
+	switch c {
+	case 'E', 'e', 'B', 'b', 'X', 'x', 'N', 'n':
+		return true
+	}
+	return false
+}
+
+// scanNumber returns the end of the numeric constant starting at s[i].
+func scanNumber(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+		}
+	}
+	if i+1 < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if s[j] == '+' || s[j] == '-' {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for i = j; i < len(s) && isDigit(s[i]); i++ {
+			}
+		}
+	}
+	return i
+}
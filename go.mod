@@ -4,5 +4,6 @@ go 1.18
 
 require (
 	golang.org/x/crypto v0.10.0
+	golang.org/x/text v0.14.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
 )
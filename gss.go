@@ -0,0 +1,18 @@
+package pq
+
+// krbSPN returns the Kerberos service principal name of the server, see
+// Config.KrbSrvName.
+func (cn *conn) krbSPN() string {
+	if spn := cn.config.KrbSpn; spn != "" {
+		return spn
+	}
+	srv := cn.config.KrbSrvName
+	if srv == "" {
+		srv = "postgres"
+	}
+	var host string
+	if cn.fallbackConfig != nil {
+		host = cn.fallbackConfig.Host
+	}
+	return srv + "/" + host
+}
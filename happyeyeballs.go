@@ -0,0 +1,111 @@
+package pq
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// happyEyeballsDelay is the time to wait for a connection attempt before
+// starting the next one in parallel, as recommended by RFC 8305.
+const happyEyeballsDelay = 250 * time.Millisecond
+
+// interleaveAddrFamilies returns ips ordered for dialHappyEyeballs,
+// alternating between IPv6 and IPv4 addresses starting with the family of
+// the first address, or nil if ips are not of both families.
+func interleaveAddrFamilies(ips []string) []string {
+	var first, second []string
+	firstIsV4 := false
+	for i, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil
+		}
+		isV4 := ip.To4() != nil
+		if i == 0 {
+			firstIsV4 = isV4
+		}
+		if isV4 == firstIsV4 {
+			first = append(first, s)
+		} else {
+			second = append(second, s)
+		}
+	}
+	if len(second) == 0 {
+		return nil
+	}
+	addrs := make([]string, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+	return addrs
+}
+
+// dialHappyEyeballs connects to one of ips, starting a new attempt whenever
+// the previous one failed or has not succeeded within happyEyeballsDelay,
+// so that an unreachable address family does not hold up the connection
+// (RFC 8305). The first established connection wins.
+func dialHappyEyeballs(ctx context.Context, dial DialFunc, ips []string, port uint16) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	results := make(chan result, len(ips))
+	next, pending := 0, 0
+	timer := time.NewTimer(happyEyeballsDelay)
+	defer timer.Stop()
+	startNext := func() {
+		if next == len(ips) {
+			return
+		}
+		network, address := NetworkAddress(ips[next], port)
+		next++
+		pending++
+		go func() {
+			c, err := dial(ctx, network, address)
+			results <- result{c, err}
+		}()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(happyEyeballsDelay)
+	}
+
+	startNext()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// close the connections of the attempts still running
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.c != nil {
+							_ = r.c.Close()
+						}
+					}
+				}(pending)
+				return r.c, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			startNext()
+		case <-timer.C:
+			startNext()
+		}
+	}
+	return nil, firstErr
+}
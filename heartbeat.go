@@ -0,0 +1,131 @@
+package pq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// heartbeat implements Config.HeartbeatPeriod and Config.IdleKeepalive. A
+// connection is idle from the moment database/sql or a Pool takes it back,
+// which calls IsValid, until it is checked out again with ResetSession or
+// the next message is sent on it.
+type heartbeat struct {
+	mu   sync.Mutex
+	idle bool
+	// since is the time of the last message sent on the idle connection,
+	// and checked that of the last heartbeat check
+	since   time.Time
+	checked time.Time
+	stop    chan struct{}
+}
+
+// startHeartbeat starts checking cn while it is idle, if enabled.
+func (cn *conn) startHeartbeat() {
+	period, keepalive := cn.config.HeartbeatPeriod, cn.config.IdleKeepalive
+	if period <= 0 && keepalive <= 0 {
+		return
+	}
+	cn.hb = &heartbeat{stop: make(chan struct{})}
+	go cn.heartbeatLoop(period, keepalive)
+}
+
+// stopHeartbeat stops the heartbeat goroutine of cn and waits for a check in
+// progress to finish.
+func (cn *conn) stopHeartbeat() {
+	if cn.hb == nil {
+		return
+	}
+	cn.hb.mu.Lock()
+	defer cn.hb.mu.Unlock()
+	select {
+	case <-cn.hb.stop:
+	default:
+		close(cn.hb.stop)
+	}
+	cn.hb.idle = false
+}
+
+// setIdle records whether cn is idle in the pool. When it is not, it waits
+// for a check in progress to finish, so the check and the caller do not
+// interleave messages.
+func (cn *conn) setIdle(idle bool) {
+	if cn.hb == nil {
+		return
+	}
+	cn.hb.mu.Lock()
+	if idle && !cn.hb.idle {
+		cn.hb.since = time.Now()
+	}
+	cn.hb.idle = idle
+	cn.hb.mu.Unlock()
+}
+
+// minHeartbeatTick bounds how often heartbeatLoop wakes up, however small
+// the period and keepalive.
+const minHeartbeatTick = time.Millisecond
+
+// heartbeatLoop checks cn every period while it is idle, and when it has been
+// idle for keepalive since the last message. Either may be zero.
+func (cn *conn) heartbeatLoop(period, keepalive time.Duration) {
+	tick, timeout := period, period
+	if keepalive > 0 {
+		// a keepalive is sent at most a quarter of keepalive after it is due
+		if tick <= 0 || keepalive/4 < tick {
+			tick = keepalive / 4
+		}
+		if timeout <= 0 {
+			timeout = keepalive
+		}
+	}
+	if tick < minHeartbeatTick {
+		tick = minHeartbeatTick
+	}
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-cn.hb.stop:
+			return
+		case now = <-t.C:
+		}
+		cn.hb.mu.Lock()
+		due := period > 0 && now.Sub(cn.hb.checked) >= period ||
+			keepalive > 0 && now.Sub(cn.hb.since) >= keepalive
+		if cn.hb.idle && due && !cn.getBad() {
+			cn.hb.checked, cn.hb.since = now, now
+			if err := cn.heartbeatCheck(timeout); err != nil {
+				cn.log(context.Background(), LogLevelWarn, "heartbeat failed, the connection is marked bad",
+					map[string]interface{}{"error": err})
+				cn.setBadErr(err)
+			}
+		}
+		cn.hb.mu.Unlock()
+	}
+}
+
+// heartbeatCheck sends a Sync message and waits up to timeout for the
+// ReadyForQuery answering it.
+func (cn *conn) heartbeatCheck(timeout time.Duration) error {
+	if err := cn.c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer func() { _ = cn.c.SetDeadline(time.Time{}) }()
+
+	// not sendSimpleMessage, which would wait for the heartbeat lock
+	msg := []byte{'S', '\x00', '\x00', '\x00', '\x04'}
+	cn.traceFrontend(msg)
+	if _, err := cn.c.Write(msg); err != nil {
+		return fmt.Errorf("fail to write: %w", err)
+	}
+	return cn.awaitReadyForQuery()
+}
+
+// IsValid reports whether the connection may be returned to the pool. It is
+// called by database/sql when the connection is released.
+func (cn *conn) IsValid() bool {
+	cn.setIdle(true)
+	return !cn.getBad()
+}
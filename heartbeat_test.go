@@ -0,0 +1,64 @@
+package pq
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHeartbeatCheckout checks out a connection from database/sql while
+// heartbeat checks run on it, which must not interleave with the
+// transactions begun on it. Run it with -race.
+func TestHeartbeatCheckout(t *testing.T) {
+	var dials, syncs int32
+	db := openFakeDB(t, "heartbeatPeriod=20", &dials, func(s *fakeServer) {
+		for {
+			typ, body, err := s.recv()
+			if err != nil {
+				return
+			}
+			switch typ {
+			case 'S':
+				atomic.AddInt32(&syncs, 1)
+				s.ready('I')
+			case 'Q':
+				if q := string(cstr(body)); strings.HasPrefix(q, "BEGIN") {
+					s.complete("BEGIN")
+					s.ready('T')
+				} else {
+					s.complete("COMMIT")
+					s.ready('I')
+				}
+			default:
+				return
+			}
+			if err := s.flush(); err != nil {
+				return
+			}
+		}
+	})
+	db.SetMaxOpenConns(1)
+
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		cancel()
+		time.Sleep(time.Duration(i%5) * 10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&syncs) == 0 {
+		t.Error("no heartbeat check ran")
+	}
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("%d connections opened, want 1", n)
+	}
+}
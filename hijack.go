@@ -0,0 +1,125 @@
+package pq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// HijackedConn is an established, authenticated session taken over from the
+// driver by Conn.Hijack. It is meant for tools speaking the frontend/backend
+// protocol themselves, such as proxies or protocol testers, which still want
+// the driver to connect, negotiate TLS and authenticate.
+type HijackedConn struct {
+	// NetConn is the network connection, over TLS if it was negotiated.
+	// Bytes the driver has already read from it are returned by Read and
+	// ReadMessage first, so read through those rather than NetConn.
+	NetConn net.Conn
+
+	// Cancellation key data of the session, for CancelRequest messages.
+	ProcessID int
+	SecretKey int
+	// ParameterStatus holds the run-time parameters reported by the server.
+	ParameterStatus map[string]string
+	// TxStatus is the transaction status of the last ReadyForQuery message.
+	TxStatus TxStatus
+
+	r              *bufio.Reader
+	maxMessageSize int
+}
+
+// Hijack takes the connection over from the driver. The connection must be
+// idle, and it must have been opened by Connect or ConnectConfig: a
+// connection of a sql.DB cannot be hijacked. Afterwards the Conn, as well as
+// statements and rows created from it, must not be used; Close has no effect
+// on the returned HijackedConn.
+func (c *Conn) Hijack() (*HijackedConn, error) {
+	cn := c.cn
+	switch {
+	case !c.owned:
+		return nil, errors.New("pq: cannot hijack a connection of a sql.DB")
+	case cn.hijacked:
+		return nil, errors.New("pq: connection already hijacked")
+	case cn.getBad():
+		return nil, cn.errBadConn()
+	case cn.inCopy:
+		return nil, errCopyInProgress
+	case cn.saveMessageType != 0:
+		return nil, errors.New("pq: cannot hijack a connection with a query in progress")
+	case cn.pgconn != nil:
+		return nil, errors.New("pq: cannot hijack a connection using client encryption")
+	case cn.compressed:
+		return nil, errors.New("pq: cannot hijack a compressed connection")
+	}
+	cn.stopHeartbeat()
+	cn.stopWatcher()
+
+	h := &HijackedConn{
+		NetConn:        cn.c,
+		ProcessID:      cn.processID,
+		SecretKey:      cn.secretKey,
+		TxStatus:       TxStatus(cn.txnStatus),
+		r:              cn.buf,
+		maxMessageSize: cn.maxMessageSize(),
+	}
+	h.NetConn = unwrapConn(cn.c)
+	if cn.timeouts != nil {
+		cn.timeouts.disable()
+	}
+	h.ParameterStatus = cn.connInfo().ParameterStatus
+	cn.hijacked = true
+	cn.closed(nil)
+	cn.setBad()
+	return h, nil
+}
+
+// Read reads raw bytes sent by the server.
+func (h *HijackedConn) Read(p []byte) (int, error) {
+	return h.r.Read(p)
+}
+
+// Write writes raw bytes to the server.
+func (h *HijackedConn) Write(p []byte) (int, error) {
+	return h.NetConn.Write(p)
+}
+
+// Close closes the network connection without terminating the session.
+func (h *HijackedConn) Close() error {
+	return h.NetConn.Close()
+}
+
+// ReadMessage reads a backend message, returning its type and its body
+// without the length. Messages longer than the MaxMessageSize of the
+// connection's Config are rejected with ErrMessageTooLarge, after which the
+// connection cannot be read from any more.
+func (h *HijackedConn) ReadMessage() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(h.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint32(header[1:]))
+	if n < 4 {
+		return 0, nil, fmt.Errorf("pq: invalid message length %d", n)
+	}
+	if n-4 > h.maxMessageSize {
+		return 0, nil, fmt.Errorf("%w: message %q of %d bytes", ErrMessageTooLarge, header[0], n-4)
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(h.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// WriteMessage writes a frontend message with the given type and body,
+// which must not include the length.
+func (h *HijackedConn) WriteMessage(typ byte, body []byte) error {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(body)+4))
+	_, err := h.NetConn.Write(append(msg, body...))
+	return err
+}
@@ -0,0 +1,50 @@
+package pq
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// HostOrder is the order in which the hosts of a connection string are
+// tried when a connection is opened without autoBalance.
+type HostOrder string
+
+const (
+	// HostOrderListed tries the hosts in the order they are listed. It is
+	// the default.
+	HostOrderListed HostOrder = "ordered"
+	// HostOrderRandom tries the hosts in a random order for each
+	// connection, which spreads the connections of many clients over the
+	// hosts, e.g. when they all reconnect after a restart.
+	HostOrderRandom HostOrder = "random"
+	// HostOrderRandomPreferPrimary tries the first host, taken to be the
+	// primary, first, and the others in a random order.
+	HostOrderRandomPreferPrimary HostOrder = "random-prefer-primary"
+)
+
+// hostRand shuffles hosts. It is seeded, unlike the global source of
+// math/rand before Go 1.20, so that processes started together do not all
+// pick the same host.
+var hostRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// orderHosts reorders fallbacks in place for order.
+func orderHosts(order HostOrder, fallbacks []*FallbackConfig) {
+	switch order {
+	case HostOrderRandomPreferPrimary:
+		if len(fallbacks) > 0 {
+			fallbacks = fallbacks[1:]
+		}
+	case HostOrderRandom:
+	default:
+		return
+	}
+	hostRand.Lock()
+	defer hostRand.Unlock()
+	hostRand.Shuffle(len(fallbacks), func(i, j int) {
+		fallbacks[i], fallbacks[j] = fallbacks[j], fallbacks[i]
+	})
+}
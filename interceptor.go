@@ -0,0 +1,75 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// QueryFunc executes a query on a connection.
+type QueryFunc func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error)
+
+// ExecFunc executes a statement that returns no rows on a connection.
+type ExecFunc func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error)
+
+// PrepareFunc prepares a statement on a connection.
+type PrepareFunc func(ctx context.Context, query string) (driver.Stmt, error)
+
+// Interceptor wraps the QueryContext, ExecContext and PrepareContext calls made
+// on connections. Each function receives the next handler in the chain; it may
+// modify the SQL or arguments before calling next, inspect the result, or
+// return without calling next at all. A nil function passes the call through
+// unchanged.
+//
+// Statements executed through a prepared driver.Stmt are only intercepted at
+// prepare time.
+type Interceptor struct {
+	Query   func(ctx context.Context, query string, args []driver.NamedValue, next QueryFunc) (driver.Rows, error)
+	Exec    func(ctx context.Context, query string, args []driver.NamedValue, next ExecFunc) (driver.Result, error)
+	Prepare func(ctx context.Context, query string, next PrepareFunc) (driver.Stmt, error)
+}
+
+// Use appends interceptors to the connector's chain. The first interceptor
+// registered is the outermost one. Use must not be called concurrently with
+// Connect, and only affects connections opened afterwards.
+func (c *Connector) Use(interceptors ...Interceptor) {
+	c.config.Interceptors = append(c.config.Interceptors, interceptors...)
+}
+
+func (c *Config) interceptQuery(next QueryFunc) QueryFunc {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		if f := c.Interceptors[i].Query; f != nil {
+			next = func(next QueryFunc) QueryFunc {
+				return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+					return f(ctx, query, args, next)
+				}
+			}(next)
+		}
+	}
+	return next
+}
+
+func (c *Config) interceptExec(next ExecFunc) ExecFunc {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		if f := c.Interceptors[i].Exec; f != nil {
+			next = func(next ExecFunc) ExecFunc {
+				return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+					return f(ctx, query, args, next)
+				}
+			}(next)
+		}
+	}
+	return next
+}
+
+func (c *Config) interceptPrepare(next PrepareFunc) PrepareFunc {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		if f := c.Interceptors[i].Prepare; f != nil {
+			next = func(next PrepareFunc) PrepareFunc {
+				return func(ctx context.Context, query string) (driver.Stmt, error) {
+					return f(ctx, query, next)
+				}
+			}(next)
+		}
+	}
+	return next
+}
@@ -0,0 +1,111 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// insertReturning returns query with a RETURNING clause for
+// Config.LastInsertIDColumn appended, if it is a single INSERT statement
+// without one run once with nargs arguments. Batches, which pass a multiple
+// of the parameters of the statement, are left to Exec, as statements
+// returning rows cannot run as a batch.
+func (cn *conn) insertReturning(query string, nargs int) (string, bool) {
+	col := cn.config.LastInsertIDColumn
+	if col == "" || nargs > maxPlaceholder(query, cn.backslashEscapes()) {
+		return "", false
+	}
+	segs := splitSQL(query, cn.backslashEscapes())
+	end := statementEnd(query, segs)
+	insert := false
+	pos := 0
+	for _, seg := range segs {
+		if pos >= end {
+			break
+		}
+		s := seg.text
+		if pos+len(s) > end {
+			s = s[:end-pos]
+		}
+		pos += len(seg.text)
+		if !seg.code {
+			if !insert && !isComment(s) {
+				return "", false
+			}
+			continue
+		}
+		if !insert {
+			t := strings.TrimLeft(s, " \t\r\n\f\v")
+			if t == "" {
+				continue
+			}
+			if len(t) < 6 || !strings.EqualFold(t[:6], "INSERT") || len(t) > 6 && isIdentChar(t[6]) {
+				return "", false
+			}
+			insert = true
+		}
+		if strings.IndexByte(s, ';') >= 0 || hasKeyword(s, "RETURNING") {
+			return "", false
+		}
+	}
+	if !insert {
+		return "", false
+	}
+	return query[:end] + " RETURNING " + QuoteIdentifierConn(cn, col) + query[end:], true
+}
+
+// execReturning runs query, an INSERT statement returning the column of
+// Config.LastInsertIDColumn, and returns its result with the value of the
+// column in the first row as the LastInsertId.
+func (cn *conn) execReturning(query string, args []driver.Value) (driver.Result, error) {
+	r, err := cn.query(query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	dest := make([]driver.Value, len(r.Columns()))
+	var id *int64
+	for {
+		if err := r.Next(dest); err != nil {
+			if err == io.EOF {
+				break
+			}
+			r.Close()
+			return nil, err
+		}
+		if id != nil {
+			continue
+		}
+		n, err := insertID(dest[0])
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("pq: cannot use column %s as LastInsertId: %w", cn.config.LastInsertIDColumn, err)
+		}
+		id = &n
+	}
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	tag := CommandTag(cn.lastCommandTag)
+	return &Result{tags: []CommandTag{tag}, rows: driver.RowsAffected(tag.RowsAffected()), insertID: id}, nil
+}
+
+func insertID(v driver.Value) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		if int64(v) < 0 {
+			return 0, fmt.Errorf("%d overflows int64", v)
+		}
+		return int64(v), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", v)
+	}
+}
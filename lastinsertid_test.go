@@ -0,0 +1,61 @@
+package pq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// TestLastInsertIDBatch checks that with last_insert_id_column set, an
+// INSERT run with a batch of arguments still runs as a batch.
+func TestLastInsertIDBatch(t *testing.T) {
+	var query string
+	db := openFakeDB(t, "last_insert_id_column=id", nil, func(s *fakeServer) {
+		var batch bool
+		for {
+			typ, body, err := s.recv()
+			if err != nil {
+				return
+			}
+			switch typ {
+			case 'P':
+				body = body[len(cstr(body))+1:]
+				query = string(cstr(body))
+			case 'D':
+			case 'U':
+				batch = true
+			case 'S':
+				if !batch {
+					s.send('1')
+					s.send('t', int16Bytes(2), int32Bytes(int(oid.T_int4)), int32Bytes(int(oid.T_text)))
+					if strings.Contains(query, "RETURNING") {
+						s.describe([]oid.Oid{oid.T_int4})
+					} else {
+						s.send('n')
+					}
+				} else {
+					s.send('2')
+					s.complete("INSERT 0 2")
+				}
+				s.ready('I')
+				if err := s.flush(); err != nil {
+					return
+				}
+			default:
+				return
+			}
+		}
+	})
+
+	res, err := db.Exec("INSERT INTO t (id, name) VALUES ($1, $2)", 1, "a", 2, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "RETURNING") {
+		t.Errorf("the batch was sent as %q", query)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("RowsAffected: got %d, %v, want 2", n, err)
+	}
+}
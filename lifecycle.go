@@ -0,0 +1,63 @@
+package pq
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// ConnInfo describes a connection passed to the lifecycle callbacks of
+// Config.
+type ConnInfo struct {
+	Host      string
+	Port      uint16
+	ProcessID int // backend process ID
+	// RemoteAddr is the address the session is connected to. It may differ
+	// from Host when Host is a name resolving to several addresses.
+	RemoteAddr net.Addr
+	// ParameterStatus holds the run-time parameters reported by the server,
+	// e.g. server_version, TimeZone or client_encoding.
+	ParameterStatus map[string]string
+}
+
+func (cn *conn) connInfo() ConnInfo {
+	info := ConnInfo{
+		ProcessID:       cn.processID,
+		RemoteAddr:      cn.remoteAddr,
+		ParameterStatus: make(map[string]string),
+	}
+	if cn.fallbackConfig != nil {
+		info.Host, info.Port = cn.fallbackConfig.Host, cn.fallbackConfig.Port
+	}
+	cn.serverParamsMu.Lock()
+	for k, v := range cn.serverParams {
+		info.ParameterStatus[k] = v
+	}
+	cn.serverParamsMu.Unlock()
+	return info
+}
+
+// connected marks the connection as established and calls OnConnect.
+func (cn *conn) connected(ctx context.Context) {
+	atomic.StoreInt32(&cn.established, 1)
+	cn.startHeartbeat()
+	if f := cn.config.OnConnect; f != nil {
+		f(ctx, cn.connInfo())
+	}
+}
+
+// closed calls OnClose once for an established connection.
+func (cn *conn) closed(err error) {
+	if !atomic.CompareAndSwapInt32(&cn.established, 1, 0) {
+		return
+	}
+	f := cn.config.OnClose
+	if f == nil {
+		return
+	}
+	reason := err
+	if reason == nil && cn.getBad() {
+		reason = cn.errBadConn()
+	}
+	f(cn.connInfo(), reason)
+}
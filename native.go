@@ -0,0 +1,473 @@
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Conn is a connection used without database/sql. It gives direct access
+// to the driver's query, exec and prepare calls, and can be passed to the
+// package functions taking a driver.Conn, such as StartCopyBoth or
+// SetNotificationHandler, through DriverConn. Like the connections of a
+// sql.DB, a Conn must not be used concurrently.
+type Conn struct {
+	cn *conn
+	// whether the connection was opened by Connect rather than by a sql.DB
+	owned bool
+}
+
+// Connect opens a connection for the given connection string.
+func Connect(ctx context.Context, dsn string) (*Conn, error) {
+	connector, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connectNative(ctx, connector)
+}
+
+// ConnectConfig opens a connection for the given configuration, which must
+// have been created by ParseConfig.
+func ConnectConfig(ctx context.Context, cfg *Config, distCfg *DistConfig) (*Conn, error) {
+	connector, err := NewConnectorConfig(cfg, distCfg)
+	if err != nil {
+		return nil, err
+	}
+	return connectNative(ctx, connector)
+}
+
+func connectNative(ctx context.Context, connector *Connector) (*Conn, error) {
+	c, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{cn: c.(*conn), owned: true}, nil
+}
+
+// NewConn returns a Conn for a connection of a sql.DB, e.g. within
+// sql.Conn.Raw. The connection still belongs to the sql.DB, so the Conn must
+// not be closed nor used once Raw returns. A runtime panic occurs if c is not
+// a pq connection.
+//
+//	err := sqlConn.Raw(func(dc interface{}) error {
+//		rows, err := pq.NewConn(dc.(driver.Conn)).Query(ctx, "SELECT ...")
+//		...
+//	})
+func NewConn(c driver.Conn) *Conn {
+	return &Conn{cn: c.(*conn)}
+}
+
+// DriverConn returns the underlying driver connection.
+func (c *Conn) DriverConn() driver.Conn {
+	return c.cn
+}
+
+// BackendPID returns the process ID of the server backend of the
+// connection, see the package function BackendPID.
+func (c *Conn) BackendPID() int {
+	return c.cn.processID
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.cn.Close()
+}
+
+// Ping checks that the connection is still alive.
+func (c *Conn) Ping(ctx context.Context) error {
+	return c.cn.Ping(ctx)
+}
+
+// Exec executes query with args, which are converted as by database/sql. The
+// result is a *Result, which reports the command tags of the statements.
+func (c *Conn) Exec(ctx context.Context, query string, args ...interface{}) (driver.Result, error) {
+	nv, err := c.cn.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.cn.ExecContext(ctx, query, nv)
+}
+
+// Query runs query with args, which are converted as by database/sql.
+func (c *Conn) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	nv, err := c.cn.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := c.cn.QueryContext(ctx, query, nv)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(rs), nil
+}
+
+// QueryRow runs query with args and returns its first row, see Row.Scan.
+func (c *Conn) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
+	rows, err := c.Query(ctx, query, args...)
+	return &Row{rows: rows, err: err}
+}
+
+// Prepare creates a prepared statement for query.
+func (c *Conn) Prepare(ctx context.Context, query string) (*Stmt, error) {
+	st, err := c.cn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{cn: c.cn, st: st}, nil
+}
+
+// namedValues converts args as database/sql does, including the slices
+// accepted by CheckNamedValue.
+func (cn *conn) namedValues(args []interface{}) ([]driver.NamedValue, error) {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: a}
+		if na, ok := a.(sql.NamedArg); ok {
+			nv.Name, nv.Value = na.Name, na.Value
+		}
+		switch err := cn.CheckNamedValue(&nv); err {
+		case nil:
+		case driver.ErrSkip:
+			v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("pq: cannot convert argument %d: %w", i+1, err)
+			}
+			nv.Value = v
+		default:
+			return nil, fmt.Errorf("pq: cannot convert argument %d: %w", i+1, err)
+		}
+		nvs[i] = nv
+	}
+	return nvs, nil
+}
+
+// Stmt is a prepared statement of a Conn.
+type Stmt struct {
+	cn *conn
+	st driver.Stmt
+}
+
+// Description returns the parameter and result column types of the
+// statement, or nil for a COPY statement.
+func (s *Stmt) Description() *StatementDescription {
+	if _, ok := s.st.(*stmt); !ok {
+		return nil
+	}
+	return StmtDescription(s.st)
+}
+
+// Exec executes the statement with args.
+func (s *Stmt) Exec(ctx context.Context, args ...interface{}) (driver.Result, error) {
+	nv, err := s.cn.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.st.(driver.StmtExecContext).ExecContext(ctx, nv)
+}
+
+// Query runs the statement with args.
+func (s *Stmt) Query(ctx context.Context, args ...interface{}) (*Rows, error) {
+	nv, err := s.cn.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := s.st.(driver.StmtQueryContext).QueryContext(ctx, nv)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(rs), nil
+}
+
+// Close deallocates the statement.
+func (s *Stmt) Close() error {
+	return s.st.Close()
+}
+
+// Rows is the result of a query run on a Conn. Unlike sql.Rows, the []byte
+// values it returns refer to the connection's read buffer and are only
+// valid until the next call to Next.
+type Rows struct {
+	rs     driver.Rows
+	values []driver.Value
+	err    error
+	closed bool
+}
+
+func newRows(rs driver.Rows) *Rows {
+	return &Rows{rs: rs, values: make([]driver.Value, len(rs.Columns()))}
+}
+
+// Columns returns the names of the result columns.
+func (r *Rows) Columns() []string {
+	return r.rs.Columns()
+}
+
+// FieldDescriptions describes the result columns.
+func (r *Rows) FieldDescriptions() []FieldDescription {
+	rs, ok := r.rs.(*rows)
+	if !ok {
+		return nil
+	}
+	fields := make([]FieldDescription, len(rs.colTyps))
+	for i, t := range rs.colTyps {
+		fields[i] = FieldDescription{
+			Name:                 rs.colNames[i],
+			DataTypeOID:          t.OID,
+			DataTypeSize:         t.Len,
+			TypeModifier:         t.Mod,
+			TableOID:             t.TableOID,
+			TableAttributeNumber: t.TableAttr,
+		}
+	}
+	return fields
+}
+
+// Next prepares the next row for Values or Scan. It returns false after the
+// last row or if an error occurred, see Err; the rows are closed then.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	if err := r.rs.Next(r.values); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		_ = r.Close()
+		return false
+	}
+	return true
+}
+
+// Values returns the values of the current row, with the types listed in
+// Data Types.
+func (r *Rows) Values() []interface{} {
+	vs := make([]interface{}, len(r.values))
+	for i, v := range r.values {
+		vs[i] = v
+	}
+	return vs
+}
+
+// Scan copies the values of the current row into dest, which may hold
+// pointers to the types database/sql scans into for the column, or
+// sql.Scanner implementations.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("pq: expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+	for i, d := range dest {
+		if err := assignValue(d, r.values[i]); err != nil {
+			return fmt.Errorf("pq: cannot scan column %d (%q): %w", i, r.rs.Columns()[i], err)
+		}
+	}
+	return nil
+}
+
+// CommandTag returns the command tag of the query once all rows were read.
+func (r *Rows) CommandTag() CommandTag {
+	if rs, ok := r.rs.(*rows); ok {
+		return CommandTag(rs.tag)
+	}
+	return ""
+}
+
+// Err returns the error that ended Next, if any.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close discards the remaining rows. It is called by Next after the last
+// row.
+func (r *Rows) Close() error {
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	if err := r.rs.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Row is the result of QueryRow.
+type Row struct {
+	rows *Rows
+	err  error
+}
+
+// Scan copies the values of the first row into dest, as Rows.Scan does, and
+// discards the other rows. It returns sql.ErrNoRows if there is no row.
+func (r *Row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
+
+var errNilPtr = errors.New("destination pointer is nil")
+
+// assignValue stores src, a value returned by the driver, in dest. It
+// supports the conversions of database/sql that do not depend on the
+// column type.
+func assignValue(dest interface{}, src driver.Value) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(src)
+	case *interface{}:
+		if b, ok := src.([]byte); ok {
+			src = append([]byte(nil), b...)
+		}
+		*d = src
+		return nil
+	case *[]byte:
+		switch s := src.(type) {
+		case nil:
+			*d = nil
+		case []byte:
+			*d = append((*d)[:0:0], s...)
+		case string:
+			*d = []byte(s)
+		default:
+			*d = []byte(asString(s))
+		}
+		return nil
+	case *json.RawMessage:
+		switch s := src.(type) {
+		case nil:
+			*d = nil
+		case []byte:
+			*d = append((*d)[:0:0], s...)
+		case string:
+			*d = json.RawMessage(s)
+		default:
+			return fmt.Errorf("unsupported conversion of %T into json.RawMessage", src)
+		}
+		return nil
+	case *sql.RawBytes:
+		switch s := src.(type) {
+		case []byte:
+			*d = s
+		case nil:
+			*d = nil
+		default:
+			*d = append((*d)[:0], asString(s)...)
+		}
+		return nil
+	case *string:
+		if src == nil {
+			return errors.New("converting NULL to string is unsupported")
+		}
+		*d = asString(src)
+		return nil
+	case *time.Time:
+		t, ok := src.(time.Time)
+		if !ok {
+			return fmt.Errorf("unsupported conversion of %T into *time.Time", src)
+		}
+		*d = t
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr {
+		return errors.New("destination not a pointer")
+	}
+	if dv.IsNil() {
+		return errNilPtr
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Kind())
+	}
+	if dv.Kind() == reflect.Ptr {
+		elem := reflect.New(dv.Type().Elem())
+		if err := assignValue(elem.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(elem)
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		if b, ok := src.([]byte); ok {
+			sv = reflect.ValueOf(append([]byte(nil), b...))
+		}
+		dv.Set(sv)
+		return nil
+	}
+	s := asString(src)
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %q to %s: %w", s, dv.Kind(), err)
+		}
+		dv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %q to %s: %w", s, dv.Kind(), err)
+		}
+		dv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %q to %s: %w", s, dv.Kind(), err)
+		}
+		dv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("converting %q to bool: %w", s, err)
+		}
+		dv.SetBool(b)
+	case reflect.String:
+		dv.SetString(s)
+	default:
+		return fmt.Errorf("unsupported conversion of %T into %s", src, dv.Type())
+	}
+	return nil
+}
+
+// asString formats a value returned by the driver as database/sql does.
+func asString(src interface{}) string {
+	switch v := src.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%v", src)
+}
@@ -6,6 +6,7 @@ package pq
 import (
 	"context"
 	"database/sql/driver"
+	"strings"
 )
 
 // NoticeHandler returns the notice handler on the given connection, if any. A
@@ -70,3 +71,50 @@ func ConnectorWithNoticeHandler(c driver.Connector, handler func(*Error)) *Notic
 	}
 	return &NoticeHandlerConnector{Connector: c, noticeHandler: handler}
 }
+
+// noticeSeverities ranks the severities of notices, from the least severe.
+// DEBUG stands for DEBUG1 to DEBUG5.
+var noticeSeverities = map[string]int{
+	Edebug:   1,
+	Elog:     2,
+	Einfo:    3,
+	Enotice:  4,
+	Ewarning: 5,
+}
+
+// parseNoticeSeverity parses the name of a notice severity, in any case,
+// returning it in upper case without the level of DEBUG.
+func parseNoticeSeverity(s string) (string, bool) {
+	s = strings.ToUpper(s)
+	if len(s) == len(Edebug)+1 && strings.HasPrefix(s, Edebug) && s[len(Edebug)] >= '1' && s[len(Edebug)] <= '5' {
+		s = Edebug
+	}
+	_, ok := noticeSeverities[s]
+	return s, ok
+}
+
+// handleNotice passes the notice in r to the notice handler of the
+// connection, unless its severity is below Config.MinNoticeSeverity.
+func (cn *conn) handleNotice(r *readBuf) {
+	n := cn.noticeHandler
+	if n == nil && cn.config.Stats == nil {
+		return
+	}
+	notice := parseError(r, cn)
+	if min, ok := parseNoticeSeverity(cn.config.MinNoticeSeverity); ok {
+		severity := notice.SeverityUnlocalized
+		if severity == "" {
+			// older servers only send the severity in lc_messages
+			severity = notice.Severity
+		}
+		// severities the driver does not know, e.g. localized ones, pass
+		if rank, ok := noticeSeverities[severity]; ok && rank < noticeSeverities[min] {
+			cn.config.Stats.noticeReceived(true)
+			return
+		}
+	}
+	cn.config.Stats.noticeReceived(false)
+	if n != nil {
+		n(notice)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,7 +24,7 @@ type Notification struct {
 	Extra string
 }
 
-func recvNotification(r *readBuf) (*Notification, error) {
+func recvNotification(ps *parameterStatus, r *readBuf) (*Notification, error) {
 	bePid := r.int32()
 	channel, err := r.string()
 	if err != nil {
@@ -34,7 +35,7 @@ func recvNotification(r *readBuf) (*Notification, error) {
 		return nil, fmt.Errorf("cannot get string from read buf: %w", err)
 	}
 
-	return &Notification{bePid, channel, extra}, nil
+	return &Notification{bePid, ps.fromServerString(channel), ps.fromServerString(extra)}, nil
 }
 
 // SetNotificationHandler sets the given notification handler on the given
@@ -112,6 +113,8 @@ func newDialListenerConn(_ Dialer, name string, c chan<- *Notification) (*Listen
 		connState:        connStateIdle,
 		replyChan:        make(chan message, 2),
 	}
+	// listenerConnLoop waits for notifications for as long as it takes
+	l.cn.idleReads()
 
 	go l.listenerConnMain()
 
@@ -178,7 +181,7 @@ func (l *ListenerConn) listenerConnLoop() (err error) {
 		case 'A':
 			// recvNotification copies all the data so we don't need to worry
 			// about the scratch buffer being overwritten.
-			not, err := recvNotification(r)
+			not, err := recvNotification(&l.cn.parameterStatus, r)
 			if err != nil {
 				return fmt.Errorf("cannot recv notification: %w", err)
 			}
@@ -203,6 +206,13 @@ func (l *ListenerConn) listenerConnLoop() (err error) {
 				return fmt.Errorf("unexpected CommandComplete")
 			}
 			// ExecSimpleQuery doesn't need to know about this message
+			if t == 'C' {
+				tag, err := r.string()
+				if err != nil {
+					return fmt.Errorf("cannot get string from read buf: %w", err)
+				}
+				l.cn.onCommandComplete(tag)
+			}
 
 		case 'Z':
 			if !l.setState(connStateIdle) {
@@ -212,11 +222,12 @@ func (l *ListenerConn) listenerConnLoop() (err error) {
 			l.replyChan <- message{t, nil}
 
 		case 'S':
-			// ignore
-		case 'N':
-			if n := l.cn.noticeHandler; n != nil {
-				n(parseError(r, l.cn))
+			// keep TimeZone and client_encoding up to date
+			if err := l.cn.processParameterStatus(r); err != nil {
+				return fmt.Errorf("cannot process parameter status: %w", err)
 			}
+		case 'N':
+			l.cn.handleNotice(r)
 		default:
 			return fmt.Errorf("unexpected message %q from server in listenerConnLoop", t)
 		}
@@ -284,6 +295,15 @@ func (l *ListenerConn) Ping() error {
 	return nil
 }
 
+// CancelRequest asks the server to cancel the command currently executing on
+// the connection, such as a query sent with ExecSimpleQuery.
+func (l *ListenerConn) CancelRequest(ctx context.Context) error {
+	l.connectionLock.Lock()
+	cn := l.cn
+	l.connectionLock.Unlock()
+	return cn.cancel(ctx)
+}
+
 // Attempt to send a query on the connection.  Returns an error if sending the
 // query failed, and the caller should initiate closure of this connection.
 // The caller must be holding senderLock (see acquireSenderLock and
@@ -297,6 +317,10 @@ func (l *ListenerConn) sendSimpleQuery(q string) (err error) {
 
 	// Can't use l.cn.writeBuf here because it uses the scratch buffer which
 	// might get overwritten by listenerConnLoop.
+	q, err = l.cn.clientString(q)
+	if err != nil {
+		return err
+	}
 	b := &writeBuf{
 		buf: []byte("Q\x00\x00\x00\x00"),
 		pos: 1,
@@ -467,7 +491,15 @@ type Listener struct {
 // re-establish the database connection after connection loss.  After each
 // consecutive failure this interval is doubled, until maxReconnectInterval is
 // reached.  Successfully completing the connection establishment procedure
-// resets the interval back to minReconnectInterval.
+// resets the interval back to minReconnectInterval.  Each wait is randomized
+// to between half and all of the current interval, so that listeners losing
+// their connection at the same time, e.g. on a server restart, do not all
+// reconnect at once.
+//
+// After reconnecting, the Listener re-issues LISTEN for all channels it was
+// listening on, and sends a nil notification over the Notify channel and emits
+// ListenerEventReconnected, since notifications sent while it was disconnected
+// are lost.
 //
 // The last parameter eventCallback can be set to a function which will be
 // called by the Listener when the state of the underlying database connection
@@ -651,6 +683,22 @@ func (l *Listener) Ping() error {
 	return l.cn.Ping()
 }
 
+// CancelRequest asks the server to cancel the command currently executing on
+// the listener's connection.
+func (l *Listener) CancelRequest(ctx context.Context) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.isClosed {
+		return errListenerClosed
+	}
+	if l.cn == nil {
+		return errors.New("no connection")
+	}
+
+	return l.cn.CancelRequest(ctx)
+}
+
 // Clean up after losing the server connection.  Returns l.cn.Err(), which
 // should have the reason the connection was lost.
 func (l *Listener) disconnectCleanup() error {
@@ -795,7 +843,7 @@ func (l *Listener) listenerConnLoop() {
 			}
 			l.emitEvent(ListenerEventConnectionAttemptFailed, err)
 
-			time.Sleep(reconnectInterval)
+			time.Sleep(jitter(reconnectInterval))
 			reconnectInterval *= 2
 			if reconnectInterval > l.maxReconnectInterval {
 				reconnectInterval = l.maxReconnectInterval
@@ -810,7 +858,7 @@ func (l *Listener) listenerConnLoop() {
 		}
 
 		reconnectInterval = l.minReconnectInterval
-		nextReconnect = time.Now().Add(reconnectInterval)
+		nextReconnect = time.Now().Add(jitter(reconnectInterval))
 
 		for {
 			notification, ok := <-l.connNotificationChan
@@ -831,6 +879,14 @@ func (l *Listener) listenerConnLoop() {
 	}
 }
 
+// jitter returns a random duration between d/2 and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d - time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
 func (l *Listener) listenerMain() {
 	l.listenerConnLoop()
 	close(l.Notify)
@@ -0,0 +1,20 @@
+module github.com/trymesoft/openGauss-connector-go-pq/otelpq
+
+go 1.20
+
+require (
+	github.com/trymesoft/openGauss-connector-go-pq v0.0.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/crypto v0.10.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+)
+
+replace github.com/trymesoft/openGauss-connector-go-pq => ../
@@ -0,0 +1,115 @@
+// Package otelpq provides an OpenTelemetry implementation of pq.Tracer.
+//
+// Set the tracer on a config before creating the connector:
+//
+//	cfg, distCfg, err := pq.ParseConfig(dsn)
+//	if err != nil {
+//		return err
+//	}
+//	cfg.Tracer = otelpq.NewTracer()
+//	connector, err := pq.NewConnectorConfig(cfg, distCfg)
+//	if err != nil {
+//		return err
+//	}
+//	db := sql.OpenDB(connector)
+//
+// It lives in its own module so that applications which do not use
+// OpenTelemetry do not pull in its dependencies.
+package otelpq
+
+import (
+	"context"
+	"strconv"
+
+	pq "github.com/trymesoft/openGauss-connector-go-pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/trymesoft/openGauss-connector-go-pq/otelpq"
+
+// Tracer implements pq.Tracer by recording an OpenTelemetry span for every
+// traced operation.
+type Tracer struct {
+	tracer     trace.Tracer
+	attrs      []attribute.KeyValue
+	includeSQL bool
+}
+
+// Option configures a Tracer.
+type Option func(*Tracer)
+
+// WithTracerProvider sets the provider used to create the tracer. The global
+// provider is used by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+// WithAttributes adds attrs to every span.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(t *Tracer) {
+		t.attrs = append(t.attrs, attrs...)
+	}
+}
+
+// WithIncludeSQL controls whether the statement text is recorded in the
+// db.statement attribute. It is recorded by default.
+func WithIncludeSQL(include bool) Option {
+	return func(t *Tracer) {
+		t.includeSQL = include
+	}
+}
+
+// NewTracer returns a Tracer configured by opts.
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{includeSQL: true}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.tracer == nil {
+		t.tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return t
+}
+
+// TraceStart implements pq.Tracer.
+func (t *Tracer) TraceStart(ctx context.Context, data pq.TraceStartData) context.Context {
+	attrs := make([]attribute.KeyValue, 0, len(t.attrs)+7)
+	attrs = append(attrs,
+		attribute.String("db.system", "opengauss"),
+		attribute.String("db.operation", data.Op.String()),
+		attribute.String("db.name", data.Database),
+		attribute.String("db.user", data.User),
+		attribute.String("net.peer.name", data.Host),
+		attribute.String("net.peer.port", strconv.Itoa(int(data.Port))),
+		attribute.Int("db.args_count", data.ArgsCount),
+	)
+	if t.includeSQL && data.SQL != "" {
+		attrs = append(attrs, attribute.String("db.statement", data.SQL))
+	}
+	attrs = append(attrs, t.attrs...)
+	ctx, _ = t.tracer.Start(ctx, "opengauss."+data.Op.String(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+// TraceEnd implements pq.Tracer.
+func (t *Tracer) TraceEnd(ctx context.Context, data pq.TraceEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.CommandTag != "" {
+		span.SetAttributes(attribute.String("db.command_tag", data.CommandTag))
+	}
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
+
+var _ pq.Tracer = (*Tracer)(nil)
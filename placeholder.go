@@ -0,0 +1,211 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMixedArgs     = errors.New("pq: cannot mix named and positional arguments")
+	errNamedArgsStmt = errors.New("pq: named arguments are not supported by prepared statements")
+)
+
+var errMultipleStatements = errors.New("pq: the query contains multiple statements, which allow_multiple_statements=false rejects")
+
+// MaxBindParameters is the maximum number of parameters of a statement
+// executed with the extended query protocol, which counts them in 16 bits.
+// Generators of multi-row statements, e.g. INSERT ... VALUES with many rows,
+// should split them so that every statement stays within the limit.
+const MaxBindParameters = 65535
+
+// ErrTooManyParameters is returned, wrapped, when a statement has more than
+// MaxBindParameters parameters. It is detected before the statement is sent.
+var ErrTooManyParameters = errors.New("pq: too many bind parameters")
+
+func errTooManyParameters(n int) error {
+	return fmt.Errorf("%w: got %d but the protocol supports at most %d", ErrTooManyParameters, n, MaxBindParameters)
+}
+
+// PlaceholderFormat is the syntax of the parameter placeholders in queries.
+type PlaceholderFormat string
+
+const (
+	// PlaceholderDollar is the server's own syntax, $1, $2 and so on.
+	PlaceholderDollar PlaceholderFormat = "dollar"
+	// PlaceholderQuestion accepts ? placeholders, as used by MySQL drivers
+	// and some ORMs. They are rewritten to $1, $2 and so on before a query
+	// is sent; ? within string literals, quoted identifiers and comments is
+	// left alone. Write ?? for an operator such as the jsonb ? operator.
+	PlaceholderQuestion PlaceholderFormat = "question"
+)
+
+// backslashEscapes reports whether backslashes are escape characters in
+// ordinary string literals on this connection.
+func (cn *conn) backslashEscapes() bool {
+	cn.serverParamsMu.Lock()
+	defer cn.serverParamsMu.Unlock()
+	return cn.serverParams["standard_conforming_strings"] == "off"
+}
+
+// checkSingleStatement returns an error if query, to be sent with the simple
+// query protocol, contains several statements and Config.RejectMultipleStatements
+// is set.
+func (cn *conn) checkSingleStatement(query string) error {
+	if cn.config.RejectMultipleStatements && multipleStatements(query, cn.backslashEscapes()) {
+		return errMultipleStatements
+	}
+	return nil
+}
+
+// checkPlaceholders returns an error if query refers to a parameter beyond
+// MaxBindParameters, which the server would count wrong in its description of
+// the statement.
+func (cn *conn) checkPlaceholders(query string) error {
+	if strings.IndexByte(query, '$') < 0 {
+		return nil
+	}
+	if n := maxPlaceholder(query, cn.backslashEscapes()); n > MaxBindParameters {
+		return errTooManyParameters(n)
+	}
+	return nil
+}
+
+// translateQuery applies Config.RewriteQuery, then rewrites a query and its
+// arguments from the syntax accepted by the driver into the server's.
+func (cn *conn) translateQuery(ctx context.Context, query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+	if f := cn.config.RewriteQuery; f != nil {
+		var err error
+		if query, args, err = f(ctx, query, args); err != nil {
+			return "", nil, err
+		}
+	}
+	if cn.config.PlaceholderFormat == PlaceholderQuestion {
+		query = rewriteQuestionPlaceholders(query, cn.backslashEscapes())
+	}
+	if hasNamedArgs(args) {
+		var err error
+		if query, args, err = rewriteNamedPlaceholders(query, args, cn.backslashEscapes()); err != nil {
+			return "", nil, err
+		}
+	}
+	if cn.config.PreferSimpleProtocol && len(args) > 0 {
+		q, err := cn.interpolate(query, args)
+		if err != nil {
+			return "", nil, err
+		}
+		return cn.addSQLComment(ctx, q), nil, nil
+	}
+	return cn.addSQLComment(ctx, query), args, nil
+}
+
+func hasNamedArgs(args []driver.NamedValue) bool {
+	for _, a := range args {
+		if a.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteNamedPlaceholders replaces :name and @name placeholders with $1, $2
+// and so on, numbered in order of first appearance, and orders args to
+// match. A name may appear several times. :: casts are left alone.
+func rewriteNamedPlaceholders(q string, args []driver.NamedValue, backslashEscapes bool) (string, []driver.NamedValue, error) {
+	byName := make(map[string]driver.NamedValue, len(args))
+	for _, a := range args {
+		if a.Name == "" {
+			return "", nil, errMixedArgs
+		}
+		byName[a.Name] = a
+	}
+
+	var b strings.Builder
+	b.Grow(len(q))
+	positions := make(map[string]int, len(args))
+	out := make([]driver.NamedValue, 0, len(args))
+	for _, seg := range splitSQL(q, backslashEscapes) {
+		if !seg.code {
+			b.WriteString(seg.text)
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == ':' && i+1 < len(s) && s[i+1] == ':' {
+				b.WriteString("::")
+				i++
+				continue
+			}
+			if (c != ':' && c != '@') || i+1 >= len(s) || !isIdentStart(s[i+1]) ||
+				(i > 0 && isIdentChar(s[i-1])) {
+				b.WriteByte(c)
+				continue
+			}
+			j := i + 1
+			for j < len(s) && isIdentChar(s[j]) && s[j] != '$' {
+				j++
+			}
+			name := s[i+1 : j]
+			pos, ok := positions[name]
+			if !ok {
+				a, found := byName[name]
+				if !found {
+					return "", nil, fmt.Errorf("pq: no argument named %q", name)
+				}
+				out = append(out, driver.NamedValue{Ordinal: len(out) + 1, Value: a.Value})
+				pos = len(out)
+				positions[name] = pos
+			}
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(pos))
+			i = j - 1
+		}
+	}
+	for name := range byName {
+		if _, ok := positions[name]; !ok {
+			return "", nil, fmt.Errorf("pq: named argument %q is not used in the query", name)
+		}
+	}
+	return b.String(), out, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// rewriteQuestionPlaceholders replaces ? placeholders with $1, $2 and so on,
+// and ?? with ?.
+func rewriteQuestionPlaceholders(q string, backslashEscapes bool) string {
+	if !strings.Contains(q, "?") {
+		return q
+	}
+	var b strings.Builder
+	b.Grow(len(q) + 8)
+	n := 0
+	for _, seg := range splitSQL(q, backslashEscapes) {
+		if !seg.code {
+			b.WriteString(seg.text)
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			if s[i] != '?' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '?' {
+				b.WriteByte('?')
+				i++
+				continue
+			}
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,391 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by Pool.Acquire once the pool is closed.
+var ErrPoolClosed = errors.New("pq: pool is closed")
+
+// PoolConfig are the options of a Pool.
+type PoolConfig struct {
+	// MaxConns is the maximum number of connections, idle or in use. The
+	// default is 4.
+	MaxConns int
+	// MinConns is the number of connections the pool keeps open, even if
+	// they are idle.
+	MinConns int
+	// MaxConnLifetime is the time after which a connection is closed rather
+	// than reused. Zero means no limit.
+	MaxConnLifetime time.Duration
+	// MaxConnIdleTime is the time after which an idle connection above
+	// MinConns is closed. Zero means no limit.
+	MaxConnIdleTime time.Duration
+	// HealthCheckPeriod is the interval at which idle connections are
+	// checked: connections past MaxConnLifetime or MaxConnIdleTime are
+	// closed, MinConns is restored, and connections that were idle for at
+	// least a period are pinged. The default is one minute.
+	HealthCheckPeriod time.Duration
+}
+
+// PoolStat reports the connections of a Pool.
+type PoolStat struct {
+	Total int // open connections
+	Idle  int // connections waiting in the pool
+	InUse int // acquired connections
+}
+
+// Pool is a pool of connections used without database/sql, for services
+// that need the features of the driver that database/sql hides, e.g. COPY
+// BOTH, notifications or hijacking, on pooled connections. Unlike a sql.DB,
+// a Pool hands out a connection for as long as it is needed, see Acquire,
+// and does not retry statements on another connection.
+//
+// A Pool may be used concurrently by several goroutines.
+type Pool struct {
+	connector *Connector
+	cfg       PoolConfig
+
+	// sem holds a token for every connection in use or being opened, which
+	// keeps the number of connections, those idle included, to MaxConns
+	sem chan struct{}
+
+	mu     sync.Mutex
+	idle   []*PooledConn // most recently released last
+	total  int
+	closed bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// PooledConn is a connection acquired from a Pool. It must be given back
+// with Release once it is not needed anymore, and must not be used after.
+type PooledConn struct {
+	*Conn
+	pool     *Pool
+	created  time.Time
+	released time.Time
+	checked  time.Time // start of the last health check that pinged it
+}
+
+// NewPool creates a pool of connections for the given connection string
+// and opens MinConns connections.
+func NewPool(ctx context.Context, dsn string, cfg PoolConfig) (*Pool, error) {
+	connector, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newPool(ctx, connector, cfg)
+}
+
+// NewPoolConfig is like NewPool, but takes a configuration created by
+// ParseConfig.
+func NewPoolConfig(ctx context.Context, config *Config, distCfg *DistConfig, cfg PoolConfig) (*Pool, error) {
+	connector, err := NewConnectorConfig(config, distCfg)
+	if err != nil {
+		return nil, err
+	}
+	return newPool(ctx, connector, cfg)
+}
+
+func newPool(ctx context.Context, connector *Connector, cfg PoolConfig) (*Pool, error) {
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 4
+	}
+	if cfg.MinConns > cfg.MaxConns {
+		cfg.MinConns = cfg.MaxConns
+	}
+	if cfg.HealthCheckPeriod <= 0 {
+		cfg.HealthCheckPeriod = time.Minute
+	}
+	p := &Pool{
+		connector: connector,
+		cfg:       cfg,
+		sem:       make(chan struct{}, cfg.MaxConns),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go p.healthCheckLoop()
+	if err := p.fill(ctx); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Acquire returns an idle connection of the pool, or opens a new one if
+// there is none and MaxConns is not reached. Otherwise it waits for a
+// connection to be released, until ctx is done.
+func (p *Pool) Acquire(ctx context.Context) (*PooledConn, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		pc, err := p.popIdle()
+		if err != nil {
+			<-p.sem
+			return nil, err
+		}
+		if pc == nil {
+			break
+		}
+		if p.expired(pc, time.Now()) || pc.cn.getBad() {
+			p.discard(pc)
+			continue
+		}
+		// ResetSession also waits for a heartbeat check in progress
+		if err := pc.cn.ResetSession(ctx); err != nil {
+			p.discard(pc)
+			continue
+		}
+		return pc, nil
+	}
+
+	pc, err := p.open(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return pc, nil
+}
+
+// AcquireFunc acquires a connection, calls f with it and releases it.
+func (p *Pool) AcquireFunc(ctx context.Context, f func(*PooledConn) error) error {
+	pc, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer pc.Release()
+	return f(pc)
+}
+
+// Exec executes query with args on a connection of the pool, see
+// Conn.Exec.
+func (p *Pool) Exec(ctx context.Context, query string, args ...interface{}) (driver.Result, error) {
+	pc, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Release()
+	return pc.Exec(ctx, query, args...)
+}
+
+// Ping acquires a connection and checks that it is alive.
+func (p *Pool) Ping(ctx context.Context) error {
+	return p.AcquireFunc(ctx, func(pc *PooledConn) error {
+		return pc.Ping(ctx)
+	})
+}
+
+// Stat reports the connections of the pool.
+func (p *Pool) Stat() PoolStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStat{Total: p.total, Idle: len(p.idle), InUse: p.total - len(p.idle)}
+}
+
+// Close closes the idle connections and makes Acquire fail. Connections in
+// use are closed when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.done
+	for _, pc := range idle {
+		p.discard(pc)
+	}
+}
+
+// Release gives the connection back to its pool. It is closed instead if it
+// is broken, in a transaction or a COPY, past MaxConnLifetime, or if the
+// pool is closed. Calling Release more than once has no effect.
+func (pc *PooledConn) Release() {
+	pc.release(time.Now())
+}
+
+// release gives the connection back to its pool, as idle since the given
+// time.
+func (pc *PooledConn) release(since time.Time) {
+	p := pc.pool
+	if p == nil {
+		return
+	}
+	pc.pool = nil
+	cn := pc.cn
+	reusable := !cn.hijacked && cn.IsValid() && !cn.inCopy && cn.txnStatus == txnStatusIdle
+	if reusable && !p.expired(pc, time.Now()) {
+		p.mu.Lock()
+		if !p.closed {
+			p.idle = append(p.idle, &PooledConn{Conn: pc.Conn, pool: p, created: pc.created, released: since, checked: pc.checked})
+			p.mu.Unlock()
+			<-p.sem
+			return
+		}
+		p.mu.Unlock()
+	}
+	p.discard(pc)
+	<-p.sem
+}
+
+// popIdle takes the most recently released idle connection, if any.
+func (p *Pool) popIdle() (*PooledConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	n := len(p.idle)
+	if n == 0 {
+		return nil, nil
+	}
+	pc := p.idle[n-1]
+	p.idle[n-1] = nil
+	p.idle = p.idle[:n-1]
+	return pc, nil
+}
+
+// open opens a connection, for which the caller holds a token of sem.
+func (p *Pool) open(ctx context.Context) (*PooledConn, error) {
+	c, err := connectNative(ctx, p.connector)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		_ = c.Close()
+		return nil, ErrPoolClosed
+	}
+	p.total++
+	p.mu.Unlock()
+	return &PooledConn{Conn: c, pool: p, created: time.Now()}, nil
+}
+
+// discard closes a connection which is not in the idle list anymore; the
+// caller gives back its token.
+func (p *Pool) discard(pc *PooledConn) {
+	_ = pc.cn.Close()
+	p.mu.Lock()
+	p.total--
+	p.mu.Unlock()
+}
+
+func (p *Pool) expired(pc *PooledConn, now time.Time) bool {
+	return p.cfg.MaxConnLifetime > 0 && now.Sub(pc.created) > p.cfg.MaxConnLifetime
+}
+
+// fill opens connections until MinConns are open.
+func (p *Pool) fill(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if p.closed || p.total >= p.cfg.MinConns {
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			// all connections are in use
+			return nil
+		}
+		pc, err := p.open(ctx)
+		if err != nil {
+			<-p.sem
+			return err
+		}
+		pc.Release()
+	}
+}
+
+func (p *Pool) healthCheckLoop() {
+	defer close(p.done)
+	t := time.NewTicker(p.cfg.HealthCheckPeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.healthCheck()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// healthCheck closes the idle connections which expired, pings those idle
+// for a period, and restores MinConns.
+func (p *Pool) healthCheck() {
+	now := time.Now()
+	for {
+		// take the connection out of the pool as if it was acquired, so
+		// that it is not counted twice if Acquire opens a new one
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			return
+		}
+		pc, idleTooLong := p.popToCheck(now)
+		if pc == nil {
+			<-p.sem
+			break
+		}
+		if p.expired(pc, now) || pc.cn.getBad() || idleTooLong {
+			p.discard(pc)
+			<-p.sem
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.HealthCheckPeriod)
+		err := pc.cn.Ping(ctx)
+		cancel()
+		if err != nil {
+			p.discard(pc)
+			<-p.sem
+			continue
+		}
+		pc.checked = now
+		pc.release(pc.released)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.HealthCheckPeriod)
+	defer cancel()
+	if err := p.fill(ctx); err != nil {
+		p.connector.config.Log(ctx, LogLevelWarn, "cannot open pool connection", map[string]interface{}{"err": err})
+	}
+}
+
+// popToCheck takes an idle connection released at least a period ago, and
+// not checked yet by the health check started at now, out of the pool, and
+// reports whether it was idle for longer than MaxConnIdleTime and is not
+// needed for MinConns.
+func (p *Pool) popToCheck(now time.Time) (pc *PooledConn, idleTooLong bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, false
+	}
+	for i, c := range p.idle {
+		if (now.Sub(c.released) < p.cfg.HealthCheckPeriod || c.checked.Equal(now)) && !p.expired(c, now) {
+			continue
+		}
+		p.idle = append(p.idle[:i], p.idle[i+1:]...)
+		idleTooLong = p.cfg.MaxConnIdleTime > 0 && now.Sub(c.released) > p.cfg.MaxConnIdleTime &&
+			p.total > p.cfg.MinConns
+		return c, idleTooLong
+	}
+	return nil, false
+}
@@ -0,0 +1,128 @@
+package replication
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Type bytes of the messages exchanged inside the replication stream.
+const (
+	XLogDataByteID                = 'w'
+	PrimaryKeepaliveMessageByteID = 'k'
+	StandbyStatusUpdateByteID     = 'r'
+)
+
+// Message is a message received from the server in the replication stream,
+// either *XLogData or *PrimaryKeepaliveMessage.
+type Message interface {
+	messageType() byte
+}
+
+// XLogData carries a chunk of the write-ahead log in physical mode, or of
+// the output of the logical decoding plugin in logical mode.
+type XLogData struct {
+	WALStart     LSN // position of WALData in the log
+	ServerWALEnd LSN // current end of the log on the server
+	ServerTime   time.Time
+	WALData      []byte
+}
+
+func (*XLogData) messageType() byte { return XLogDataByteID }
+
+// PrimaryKeepaliveMessage is sent periodically by the server. If
+// ReplyRequested is set the client should send a StandbyStatusUpdate
+// promptly to avoid being disconnected.
+type PrimaryKeepaliveMessage struct {
+	ServerWALEnd   LSN
+	ServerTime     time.Time
+	ReplyRequested bool
+}
+
+func (*PrimaryKeepaliveMessage) messageType() byte { return PrimaryKeepaliveMessageByteID }
+
+// StandbyStatusUpdate reports the client's progress to the server, which may
+// then release the log up to WALFlushPosition.
+type StandbyStatusUpdate struct {
+	WALWritePosition LSN       // last position received and written
+	WALFlushPosition LSN       // last position durably stored; defaults to WALWritePosition
+	WALApplyPosition LSN       // last position applied; defaults to WALWritePosition
+	ClientTime       time.Time // defaults to time.Now()
+	ReplyRequested   bool
+}
+
+// postgresEpoch is the origin of the timestamps in replication messages.
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func timeFromMicros(us int64) time.Time {
+	return postgresEpoch.Add(time.Duration(us) * time.Microsecond)
+}
+
+func timeToMicros(t time.Time) int64 {
+	return t.Sub(postgresEpoch).Microseconds()
+}
+
+var errShortMessage = errors.New("replication message too short")
+
+// ParseMessage parses the payload of a CopyData message received from the
+// server.
+func ParseMessage(data []byte) (Message, error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+	switch data[0] {
+	case XLogDataByteID:
+		return parseXLogData(data[1:])
+	case PrimaryKeepaliveMessageByteID:
+		return parsePrimaryKeepaliveMessage(data[1:])
+	default:
+		return nil, fmt.Errorf("unknown replication message type %q", data[0])
+	}
+}
+
+func parseXLogData(b []byte) (*XLogData, error) {
+	if len(b) < 24 {
+		return nil, errShortMessage
+	}
+	return &XLogData{
+		WALStart:     LSN(binary.BigEndian.Uint64(b)),
+		ServerWALEnd: LSN(binary.BigEndian.Uint64(b[8:])),
+		ServerTime:   timeFromMicros(int64(binary.BigEndian.Uint64(b[16:]))),
+		WALData:      b[24:],
+	}, nil
+}
+
+func parsePrimaryKeepaliveMessage(b []byte) (*PrimaryKeepaliveMessage, error) {
+	// openGauss may send trailing fields, which are ignored
+	if len(b) < 17 {
+		return nil, errShortMessage
+	}
+	return &PrimaryKeepaliveMessage{
+		ServerWALEnd:   LSN(binary.BigEndian.Uint64(b)),
+		ServerTime:     timeFromMicros(int64(binary.BigEndian.Uint64(b[8:]))),
+		ReplyRequested: b[16] != 0,
+	}, nil
+}
+
+func (s StandbyStatusUpdate) encode() []byte {
+	if s.WALFlushPosition == 0 {
+		s.WALFlushPosition = s.WALWritePosition
+	}
+	if s.WALApplyPosition == 0 {
+		s.WALApplyPosition = s.WALWritePosition
+	}
+	if s.ClientTime.IsZero() {
+		s.ClientTime = time.Now()
+	}
+	b := make([]byte, 34)
+	b[0] = StandbyStatusUpdateByteID
+	binary.BigEndian.PutUint64(b[1:], uint64(s.WALWritePosition))
+	binary.BigEndian.PutUint64(b[9:], uint64(s.WALFlushPosition))
+	binary.BigEndian.PutUint64(b[17:], uint64(s.WALApplyPosition))
+	binary.BigEndian.PutUint64(b[25:], uint64(timeToMicros(s.ClientTime)))
+	if s.ReplyRequested {
+		b[33] = 1
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MppdbDecodingMessage is a message of openGauss's mppdb_decoding logical
+// decoding plugin, as carried in XLogData.WALData: *MppdbDecodingBegin,
+// *MppdbDecodingCommit or *MppdbDecodingChange.
+type MppdbDecodingMessage interface {
+	mppdbDecodingMessage()
+}
+
+// MppdbDecodingBegin starts a transaction. Xid is set when the slot was
+// started with "include-xids" enabled.
+type MppdbDecodingBegin struct {
+	Xid uint64
+}
+
+// MppdbDecodingCommit ends a transaction. Xid is set when the slot was
+// started with "include-xids", and CommitTime with "include-timestamp".
+type MppdbDecodingCommit struct {
+	Xid        uint64
+	CommitTime time.Time
+}
+
+// MppdbDecodingChange is a row change. Values are in SQL literal syntax,
+// e.g. `'text'` or `1`, and "null" for NULL. The old key columns are set for
+// updates and deletes.
+type MppdbDecodingChange struct {
+	TableName   string   `json:"table_name"` // schema-qualified
+	OpType      string   `json:"op_type"`    // INSERT, UPDATE or DELETE
+	ColumnsName []string `json:"columns_name"`
+	ColumnsType []string `json:"columns_type"`
+	ColumnsVal  []string `json:"columns_val"`
+	OldKeysName []string `json:"old_keys_name"`
+	OldKeysType []string `json:"old_keys_type"`
+	OldKeysVal  []string `json:"old_keys_val"`
+}
+
+func (*MppdbDecodingBegin) mppdbDecodingMessage()  {}
+func (*MppdbDecodingCommit) mppdbDecodingMessage() {}
+func (*MppdbDecodingChange) mppdbDecodingMessage() {}
+
+// ParseMppdbDecoding parses a message of the mppdb_decoding plugin in its
+// default JSON format.
+func ParseMppdbDecoding(data []byte) (MppdbDecodingMessage, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("{")):
+		var m MppdbDecodingChange
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("invalid mppdb_decoding change: %w", err)
+		}
+		return &m, nil
+	case bytes.HasPrefix(data, []byte("BEGIN")):
+		m := &MppdbDecodingBegin{}
+		if f := strings.Fields(string(data)); len(f) > 1 {
+			m.Xid, _ = strconv.ParseUint(f[1], 10, 64)
+		}
+		return m, nil
+	case bytes.HasPrefix(data, []byte("COMMIT")):
+		// COMMIT [xid] [(at timestamp)] [CSN csn]
+		s := string(data)
+		m := &MppdbDecodingCommit{}
+		if f := strings.Fields(s); len(f) > 1 {
+			m.Xid, _ = strconv.ParseUint(f[1], 10, 64)
+		}
+		if i := strings.Index(s, "(at "); i >= 0 {
+			if j := strings.IndexByte(s[i:], ')'); j >= 0 {
+				m.CommitTime, _ = parseCommitTime(s[i+len("(at ") : i+j])
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown mppdb_decoding message %q", truncate(data, 32))
+	}
+}
+
+func parseCommitTime(s string) (time.Time, error) {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999-07",
+		"2006-01-02 15:04:05.999999-07:00",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid commit time %q", s)
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
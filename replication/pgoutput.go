@@ -0,0 +1,263 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// LogicalMessage is a message of the pgoutput logical decoding plugin, as
+// carried in XLogData.WALData: *BeginMessage, *CommitMessage,
+// *OriginMessage, *RelationMessage, *TypeMessage, *InsertMessage,
+// *UpdateMessage, *DeleteMessage or *TruncateMessage.
+type LogicalMessage interface {
+	logicalMessageType() byte
+}
+
+// BeginMessage starts a transaction.
+type BeginMessage struct {
+	FinalLSN   LSN // LSN of the commit record
+	CommitTime time.Time
+	Xid        uint32
+}
+
+// CommitMessage ends a transaction.
+type CommitMessage struct {
+	Flags             uint8
+	CommitLSN         LSN
+	TransactionEndLSN LSN
+	CommitTime        time.Time
+}
+
+// OriginMessage reports the replication origin of the following changes.
+type OriginMessage struct {
+	CommitLSN LSN
+	Name      string
+}
+
+// RelationMessage describes a table. It is sent before the first change to
+// the table in a session, and again whenever its definition changes; the
+// following changes refer to it by RelationID.
+type RelationMessage struct {
+	RelationID      uint32
+	Namespace       string
+	RelationName    string
+	ReplicaIdentity uint8
+	Columns         []RelationMessageColumn
+}
+
+// RelationMessageColumn describes a column of a RelationMessage.
+type RelationMessageColumn struct {
+	Flags        uint8 // 1 if the column is part of the key
+	Name         string
+	DataType     uint32 // type OID
+	TypeModifier int32
+}
+
+// TypeMessage describes a user-defined type used by a relation.
+type TypeMessage struct {
+	DataType  uint32
+	Namespace string
+	Name      string
+}
+
+// InsertMessage is an inserted row.
+type InsertMessage struct {
+	RelationID uint32
+	Tuple      *TupleData
+}
+
+// UpdateMessage is an updated row. OldTuple holds the key columns ('K') or
+// the whole old row ('O') depending on the table's replica identity, and is
+// nil if the key did not change.
+type UpdateMessage struct {
+	RelationID   uint32
+	OldTupleType uint8 // 'K', 'O' or 0
+	OldTuple     *TupleData
+	NewTuple     *TupleData
+}
+
+// DeleteMessage is a deleted row. OldTuple holds the key columns ('K') or
+// the whole row ('O').
+type DeleteMessage struct {
+	RelationID   uint32
+	OldTupleType uint8
+	OldTuple     *TupleData
+}
+
+// TruncateMessage reports tables being truncated.
+type TruncateMessage struct {
+	Option      uint8 // 1 for CASCADE, 2 for RESTART IDENTITY
+	RelationIDs []uint32
+}
+
+// TupleData is the content of a row.
+type TupleData struct {
+	Columns []TupleDataColumn
+}
+
+// Kinds of TupleDataColumn.
+const (
+	TupleDataNull      = 'n'
+	TupleDataUnchanged = 'u' // unchanged TOASTed value, not sent
+	TupleDataText      = 't'
+	TupleDataBinary    = 'b'
+)
+
+// TupleDataColumn is a column value of a TupleData.
+type TupleDataColumn struct {
+	DataType uint8 // one of the TupleData kinds
+	Data     []byte
+}
+
+func (*BeginMessage) logicalMessageType() byte    { return 'B' }
+func (*CommitMessage) logicalMessageType() byte   { return 'C' }
+func (*OriginMessage) logicalMessageType() byte   { return 'O' }
+func (*RelationMessage) logicalMessageType() byte { return 'R' }
+func (*TypeMessage) logicalMessageType() byte     { return 'Y' }
+func (*InsertMessage) logicalMessageType() byte   { return 'I' }
+func (*UpdateMessage) logicalMessageType() byte   { return 'U' }
+func (*DeleteMessage) logicalMessageType() byte   { return 'D' }
+func (*TruncateMessage) logicalMessageType() byte { return 'T' }
+
+// ParseLogicalMessage parses a pgoutput message. The returned message may
+// refer to data.
+func ParseLogicalMessage(data []byte) (m LogicalMessage, err error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+	d := &decoder{b: data[1:]}
+	defer func() {
+		if d.short {
+			m, err = nil, fmt.Errorf("%w: pgoutput message %q", errShortMessage, data[0])
+		}
+	}()
+
+	switch data[0] {
+	case 'B':
+		return &BeginMessage{
+			FinalLSN:   LSN(d.uint64()),
+			CommitTime: timeFromMicros(int64(d.uint64())),
+			Xid:        d.uint32(),
+		}, nil
+	case 'C':
+		return &CommitMessage{
+			Flags:             d.uint8(),
+			CommitLSN:         LSN(d.uint64()),
+			TransactionEndLSN: LSN(d.uint64()),
+			CommitTime:        timeFromMicros(int64(d.uint64())),
+		}, nil
+	case 'O':
+		return &OriginMessage{CommitLSN: LSN(d.uint64()), Name: d.string()}, nil
+	case 'R':
+		m := &RelationMessage{
+			RelationID:      d.uint32(),
+			Namespace:       d.string(),
+			RelationName:    d.string(),
+			ReplicaIdentity: d.uint8(),
+		}
+		n := int(d.uint16())
+		for i := 0; i < n && !d.short; i++ {
+			m.Columns = append(m.Columns, RelationMessageColumn{
+				Flags:        d.uint8(),
+				Name:         d.string(),
+				DataType:     d.uint32(),
+				TypeModifier: int32(d.uint32()),
+			})
+		}
+		return m, nil
+	case 'Y':
+		return &TypeMessage{DataType: d.uint32(), Namespace: d.string(), Name: d.string()}, nil
+	case 'I':
+		m := &InsertMessage{RelationID: d.uint32()}
+		if t := d.uint8(); t != 'N' && !d.short {
+			return nil, fmt.Errorf("unexpected tuple type %q in insert message", t)
+		}
+		m.Tuple = d.tuple()
+		return m, nil
+	case 'U':
+		m := &UpdateMessage{RelationID: d.uint32()}
+		t := d.uint8()
+		if t == 'K' || t == 'O' {
+			m.OldTupleType = t
+			m.OldTuple = d.tuple()
+			t = d.uint8()
+		}
+		if t != 'N' && !d.short {
+			return nil, fmt.Errorf("unexpected tuple type %q in update message", t)
+		}
+		m.NewTuple = d.tuple()
+		return m, nil
+	case 'D':
+		m := &DeleteMessage{RelationID: d.uint32(), OldTupleType: d.uint8()}
+		if m.OldTupleType != 'K' && m.OldTupleType != 'O' && !d.short {
+			return nil, fmt.Errorf("unexpected tuple type %q in delete message", m.OldTupleType)
+		}
+		m.OldTuple = d.tuple()
+		return m, nil
+	case 'T':
+		n := int(d.uint32())
+		m := &TruncateMessage{Option: d.uint8()}
+		for i := 0; i < n && !d.short; i++ {
+			m.RelationIDs = append(m.RelationIDs, d.uint32())
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown pgoutput message type %q", data[0])
+	}
+}
+
+// decoder reads big-endian values, recording rather than failing on short
+// input so messages can be parsed in straight-line code.
+type decoder struct {
+	b     []byte
+	short bool
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.short || n < 0 || len(d.b) < n {
+		d.short = true
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// fixed is like next, but returns zeros on short input.
+func (d *decoder) fixed(n int) []byte {
+	if v := d.next(n); v != nil {
+		return v
+	}
+	return make([]byte, n)
+}
+
+func (d *decoder) uint8() uint8   { return d.fixed(1)[0] }
+func (d *decoder) uint16() uint16 { return binary.BigEndian.Uint16(d.fixed(2)) }
+func (d *decoder) uint32() uint32 { return binary.BigEndian.Uint32(d.fixed(4)) }
+func (d *decoder) uint64() uint64 { return binary.BigEndian.Uint64(d.fixed(8)) }
+
+func (d *decoder) string() string {
+	i := bytes.IndexByte(d.b, 0)
+	if d.short || i < 0 {
+		d.short = true
+		return ""
+	}
+	s := string(d.b[:i])
+	d.b = d.b[i+1:]
+	return s
+}
+
+func (d *decoder) tuple() *TupleData {
+	n := int(d.uint16())
+	t := &TupleData{}
+	for i := 0; i < n && !d.short; i++ {
+		c := TupleDataColumn{DataType: d.uint8()}
+		if c.DataType == TupleDataText || c.DataType == TupleDataBinary {
+			c.Data = d.next(int(int32(d.uint32())))
+		}
+		t.Columns = append(t.Columns, c)
+	}
+	return t
+}
@@ -0,0 +1,313 @@
+// Package replication implements the client side of the streaming
+// replication protocol on top of a pq connection, e.g. to consume the output
+// of a logical decoding slot or to stream the write-ahead log for backups.
+//
+//	conn, err := replication.Connect(ctx, "host=... user=... dbname=app")
+//	sys, err := conn.IdentifySystem(ctx)
+//	_, err = conn.CreateReplicationSlot(ctx, "cdc", "mppdb_decoding", replication.CreateReplicationSlotOptions{})
+//	err = conn.StartReplication(ctx, "cdc", sys.XLogPos, replication.StartReplicationOptions{})
+//	for {
+//		msg, err := conn.ReceiveMessage(ctx)
+//		...
+//		err = conn.SendStandbyStatusUpdate(replication.StandbyStatusUpdate{WALWritePosition: pos})
+//	}
+package replication
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	pq "github.com/trymesoft/openGauss-connector-go-pq"
+)
+
+// Conn is a replication connection. It runs replication commands until
+// StartReplication is called, after which it streams messages until
+// StopReplication.
+type Conn struct {
+	conn driver.Conn
+	copy *pq.CopyBoth
+}
+
+var (
+	errStreaming    = errors.New("replication: replication is in progress")
+	errNotStreaming = errors.New("replication: replication has not been started")
+)
+
+// Connect opens a replication connection. dsn is a connection string as
+// accepted by pq.ParseConfig; the replication parameter is set to
+// "database" if it is not present, which allows logical replication and SQL
+// on the same connection. Tools streaming only the physical log may set
+// replication=true instead.
+func Connect(ctx context.Context, dsn string) (*Conn, error) {
+	cfg, distCfg, err := pq.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return ConnectConfig(ctx, cfg, distCfg)
+}
+
+// ConnectConfig is like Connect, but takes a config created by
+// pq.ParseConfig. cfg is not modified.
+func ConnectConfig(ctx context.Context, cfg *pq.Config, distCfg *pq.DistConfig) (*Conn, error) {
+	if cfg.Replication == "" {
+		cfg = cfg.Copy()
+		cfg.Replication = "database"
+		cfg.PreferSimpleProtocol = true
+	}
+	connector, err := pq.NewConnectorConfig(cfg, distCfg)
+	if err != nil {
+		return nil, err
+	}
+	c, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: c}, nil
+}
+
+// query runs a replication command and returns its result as text.
+func (c *Conn) query(ctx context.Context, command string) (columns []string, values [][]string, err error) {
+	if c.copy != nil {
+		return nil, nil, errStreaming
+	}
+	rows, err := c.conn.(driver.QueryerContext).QueryContext(ctx, command, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	columns = rows.Columns()
+	dest := make([]driver.Value, len(columns))
+	for {
+		if err := rows.Next(dest); err == io.EOF {
+			return columns, values, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(dest))
+		for i, v := range dest {
+			row[i] = textValue(v)
+		}
+		values = append(values, row)
+	}
+}
+
+func textValue(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// queryRow runs a command returning exactly one row, keyed by column name.
+func (c *Conn) queryRow(ctx context.Context, command string) (map[string]string, error) {
+	columns, values, err := c.query(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("replication: %s returned %d rows, expected 1", strings.Fields(command)[0], len(values))
+	}
+	row := make(map[string]string, len(columns))
+	for i, name := range columns {
+		row[name] = values[0][i]
+	}
+	return row, nil
+}
+
+// IdentifySystemResult is the result of IDENTIFY_SYSTEM.
+type IdentifySystemResult struct {
+	SystemID string
+	Timeline int32
+	XLogPos  LSN // current flush position of the log
+	DBName   string
+}
+
+// IdentifySystem asks the server to identify itself.
+func (c *Conn) IdentifySystem(ctx context.Context) (IdentifySystemResult, error) {
+	var res IdentifySystemResult
+	row, err := c.queryRow(ctx, "IDENTIFY_SYSTEM")
+	if err != nil {
+		return res, err
+	}
+	res.SystemID = row["systemid"]
+	res.DBName = row["dbname"]
+	tli, err := strconv.ParseInt(row["timeline"], 10, 32)
+	if err != nil {
+		return res, fmt.Errorf("replication: invalid timeline: %w", err)
+	}
+	res.Timeline = int32(tli)
+	if res.XLogPos, err = ParseLSN(row["xlogpos"]); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// ReplicationMode selects what StartReplication streams.
+type ReplicationMode int
+
+const (
+	// LogicalReplication streams the output of a logical decoding plugin.
+	LogicalReplication ReplicationMode = iota
+	// PhysicalReplication streams the raw write-ahead log.
+	PhysicalReplication
+)
+
+// StartReplicationOptions are the options of StartReplication.
+type StartReplicationOptions struct {
+	Mode ReplicationMode
+	// Timeline is the timeline to stream in physical mode; 0 means the
+	// server's current timeline.
+	Timeline int32
+	// PluginArgs are passed to the output plugin in logical mode, each in
+	// the form `"name" 'value'`, e.g. `"include-xids" 'false'`.
+	PluginArgs []string
+}
+
+// StartReplication starts streaming at startLSN. Messages are then read with
+// ReceiveMessage.
+//
+// In logical mode slotName is required. In physical mode it is optional;
+// without a slot the server does not retain the log for the client. When a
+// physical stream of a historic timeline reaches the end of that timeline,
+// ReceiveMessage returns io.EOF and StopReplication reports where the next
+// timeline starts.
+func (c *Conn) StartReplication(ctx context.Context, slotName string, startLSN LSN, opts StartReplicationOptions) error {
+	if c.copy != nil {
+		return errStreaming
+	}
+	var b strings.Builder
+	b.WriteString("START_REPLICATION")
+	if slotName != "" {
+		b.WriteString(" SLOT ")
+		b.WriteString(slotName)
+	}
+	switch opts.Mode {
+	case LogicalReplication:
+		if slotName == "" {
+			return errors.New("replication: logical replication requires a slot")
+		}
+		fmt.Fprintf(&b, " LOGICAL %s", startLSN)
+		if len(opts.PluginArgs) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(opts.PluginArgs, ", "))
+		}
+	case PhysicalReplication:
+		fmt.Fprintf(&b, " PHYSICAL %s", startLSN)
+		if opts.Timeline > 0 {
+			fmt.Fprintf(&b, " TIMELINE %d", opts.Timeline)
+		}
+	default:
+		return fmt.Errorf("replication: invalid mode %d", opts.Mode)
+	}
+	cb, err := pq.StartCopyBoth(ctx, c.conn, b.String())
+	if err != nil {
+		return err
+	}
+	c.copy = cb
+	return nil
+}
+
+// ReceiveMessage returns the next message of the replication stream. It
+// returns io.EOF when the server has ended the stream.
+//
+// When ctx is done first, ReceiveMessage returns ctx.Err() and the stream
+// remains usable, so a deadline can be used to send status updates at
+// regular intervals.
+func (c *Conn) ReceiveMessage(ctx context.Context) (Message, error) {
+	if c.copy == nil {
+		return nil, errNotStreaming
+	}
+	data, err := c.copy.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMessage(data)
+}
+
+// SendStandbyStatusUpdate reports the client's progress to the server. It
+// may be called while another goroutine is blocked in ReceiveMessage.
+func (c *Conn) SendStandbyStatusUpdate(s StandbyStatusUpdate) error {
+	if c.copy == nil {
+		return errNotStreaming
+	}
+	return c.copy.Send(s.encode())
+}
+
+// StopReplicationResult is the result of StopReplication.
+type StopReplicationResult struct {
+	// NextTimeline and NextTimelineStartPos are set when the server ended a
+	// physical stream because it reached the end of a historic timeline.
+	// Streaming continues with NextTimeline at NextTimelineStartPos.
+	NextTimeline         int32
+	NextTimelineStartPos LSN
+}
+
+// StopReplication ends the stream and returns the connection to command
+// mode.
+func (c *Conn) StopReplication() (StopReplicationResult, error) {
+	var res StopReplicationResult
+	if c.copy == nil {
+		return res, errNotStreaming
+	}
+	cb := c.copy
+	c.copy = nil
+	if err := cb.Close(); err != nil {
+		return res, err
+	}
+	columns, rows := cb.Result()
+	if len(rows) != 1 || len(columns) < 2 {
+		return res, nil
+	}
+	tli, err := strconv.ParseInt(rows[0][0], 10, 32)
+	if err != nil {
+		return res, fmt.Errorf("replication: invalid next timeline: %w", err)
+	}
+	res.NextTimeline = int32(tli)
+	if res.NextTimelineStartPos, err = ParseLSN(rows[0][1]); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// TimelineHistoryResult is the result of TIMELINE_HISTORY.
+type TimelineHistoryResult struct {
+	FileName string
+	Content  []byte
+}
+
+// TimelineHistory returns the history file of a timeline, which records
+// where each earlier timeline branched off.
+func (c *Conn) TimelineHistory(ctx context.Context, timeline int32) (TimelineHistoryResult, error) {
+	var res TimelineHistoryResult
+	row, err := c.queryRow(ctx, "TIMELINE_HISTORY "+strconv.FormatInt(int64(timeline), 10))
+	if err != nil {
+		return res, err
+	}
+	res.FileName = row["filename"]
+	res.Content = []byte(row["content"])
+	return res, nil
+}
+
+// Close closes the connection, stopping replication first if necessary.
+func (c *Conn) Close() error {
+	if c.copy != nil {
+		_, _ = c.StopReplication()
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,188 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pq "github.com/trymesoft/openGauss-connector-go-pq"
+)
+
+// SnapshotAction is what CreateReplicationSlot does with the snapshot of a
+// new logical slot.
+type SnapshotAction string
+
+const (
+	// ExportSnapshot exports the snapshot for use by other sessions with
+	// SET TRANSACTION SNAPSHOT, e.g. to copy the initial table contents
+	// consistently with the changes streamed from the slot. The snapshot
+	// stays valid until the next command on the replication connection.
+	ExportSnapshot SnapshotAction = "EXPORT_SNAPSHOT"
+	// NoExportSnapshot creates the slot without exporting the snapshot.
+	NoExportSnapshot SnapshotAction = "NOEXPORT_SNAPSHOT"
+	// UseSnapshot uses the snapshot in the current transaction of the
+	// replication connection.
+	UseSnapshot SnapshotAction = "USE_SNAPSHOT"
+)
+
+// CreateReplicationSlotOptions are the options of CreateReplicationSlot.
+type CreateReplicationSlotOptions struct {
+	Mode ReplicationMode
+	// Temporary slots are dropped when the connection closes.
+	Temporary bool
+	// SnapshotAction applies to logical slots. By default the server's
+	// default, exporting the snapshot, is used.
+	SnapshotAction SnapshotAction
+	// ReserveWAL makes a physical slot retain the log immediately, rather
+	// than once a client first connects to it.
+	ReserveWAL bool
+}
+
+// CreateReplicationSlotResult is the result of CREATE_REPLICATION_SLOT.
+type CreateReplicationSlotResult struct {
+	SlotName string
+	// ConsistentPoint is the first position the slot can stream from.
+	ConsistentPoint LSN
+	// SnapshotName is the name of the exported snapshot, if any.
+	SnapshotName string
+	OutputPlugin string
+}
+
+// CreateReplicationSlot creates a replication slot. outputPlugin is the
+// logical decoding plugin of a logical slot, e.g. mppdb_decoding or pgoutput,
+// and is ignored for physical slots.
+func (c *Conn) CreateReplicationSlot(ctx context.Context, slotName, outputPlugin string, opts CreateReplicationSlotOptions) (CreateReplicationSlotResult, error) {
+	var res CreateReplicationSlotResult
+	var b strings.Builder
+	b.WriteString("CREATE_REPLICATION_SLOT ")
+	b.WriteString(slotName)
+	if opts.Temporary {
+		b.WriteString(" TEMPORARY")
+	}
+	switch opts.Mode {
+	case LogicalReplication:
+		if outputPlugin == "" {
+			return res, errors.New("replication: logical slots require an output plugin")
+		}
+		b.WriteString(" LOGICAL ")
+		b.WriteString(outputPlugin)
+		if opts.SnapshotAction != "" {
+			b.WriteString(" ")
+			b.WriteString(string(opts.SnapshotAction))
+		}
+	case PhysicalReplication:
+		b.WriteString(" PHYSICAL")
+		if opts.ReserveWAL {
+			b.WriteString(" RESERVE_WAL")
+		}
+	default:
+		return res, fmt.Errorf("replication: invalid mode %d", opts.Mode)
+	}
+
+	row, err := c.queryRow(ctx, b.String())
+	if err != nil {
+		return res, err
+	}
+	res.SlotName = row["slot_name"]
+	res.SnapshotName = row["snapshot_name"]
+	res.OutputPlugin = row["output_plugin"]
+	if s := row["consistent_point"]; s != "" {
+		if res.ConsistentPoint, err = ParseLSN(s); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// DropReplicationSlotOptions are the options of DropReplicationSlot.
+type DropReplicationSlotOptions struct {
+	// Wait for the slot to become inactive instead of failing if it is in
+	// use by another connection.
+	Wait bool
+}
+
+// DropReplicationSlot drops a replication slot, releasing the log it
+// retains.
+func (c *Conn) DropReplicationSlot(ctx context.Context, slotName string, opts DropReplicationSlotOptions) error {
+	command := "DROP_REPLICATION_SLOT " + slotName
+	if opts.Wait {
+		command += " WAIT"
+	}
+	_, _, err := c.query(ctx, command)
+	return err
+}
+
+// ReplicationSlot describes a replication slot, as listed in the
+// pg_replication_slots view.
+type ReplicationSlot struct {
+	SlotName string
+	Plugin   string // empty for physical slots
+	SlotType string // "logical" or "physical"
+	Database string // empty for physical slots
+	Active   bool
+	// RestartLSN is the oldest position of the log retained for the slot.
+	RestartLSN LSN
+	// ConfirmedFlushLSN is the position up to which the consumer of a
+	// logical slot has confirmed receiving changes.
+	ConfirmedFlushLSN LSN
+}
+
+// ReplicationSlots lists the replication slots of the server. It runs SQL
+// and so requires a connection with replication=database.
+func (c *Conn) ReplicationSlots(ctx context.Context) ([]ReplicationSlot, error) {
+	return c.replicationSlots(ctx, "SELECT * FROM pg_replication_slots ORDER BY slot_name")
+}
+
+// ReadReplicationSlot returns the replication slot with the given name. ok is
+// false if there is no such slot. Like ReplicationSlots, it requires a
+// connection with replication=database.
+func (c *Conn) ReadReplicationSlot(ctx context.Context, slotName string) (slot ReplicationSlot, ok bool, err error) {
+	slots, err := c.replicationSlots(ctx,
+		"SELECT * FROM pg_replication_slots WHERE slot_name = "+pq.QuoteLiteral(slotName))
+	if err != nil || len(slots) == 0 {
+		return slot, false, err
+	}
+	return slots[0], true, nil
+}
+
+func (c *Conn) replicationSlots(ctx context.Context, query string) ([]ReplicationSlot, error) {
+	columns, values, err := c.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]ReplicationSlot, 0, len(values))
+	for _, v := range values {
+		var s ReplicationSlot
+		for i, name := range columns {
+			switch name {
+			case "slot_name":
+				s.SlotName = v[i]
+			case "plugin":
+				s.Plugin = v[i]
+			case "slot_type":
+				s.SlotType = v[i]
+			case "database":
+				s.Database = v[i]
+			case "active":
+				s.Active = v[i] == "true" || v[i] == "t"
+			case "restart_lsn":
+				s.RestartLSN, err = parseOptionalLSN(v[i])
+			case "confirmed_flush_lsn", "confirmed_flush": // the latter in openGauss
+				s.ConfirmedFlushLSN, err = parseOptionalLSN(v[i])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("replication: invalid %s of slot %s: %w", name, s.SlotName, err)
+			}
+		}
+		slots = append(slots, s)
+	}
+	return slots, nil
+}
+
+func parseOptionalLSN(s string) (LSN, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return ParseLSN(s)
+}
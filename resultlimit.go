@@ -0,0 +1,62 @@
+package pq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRowTooLarge is returned by Rows.Next when a row exceeds
+	// Config.MaxRowBytes.
+	ErrRowTooLarge = errors.New("pq: row exceeds max_row_bytes")
+	// ErrResultTooLarge is returned by Rows.Next when a result set exceeds
+	// Config.MaxResultBytes.
+	ErrResultTooLarge = errors.New("pq: result set exceeds max_result_bytes")
+	// ErrMessageTooLarge is returned when the server sends a message longer
+	// than Config.MaxMessageSize. The connection cannot be used afterwards.
+	ErrMessageTooLarge = errors.New("pq: message exceeds max_message_size")
+)
+
+const defaultMaxMessageSize = 1 << 30
+
+// maxMessageSize implements Config.MaxMessageSize.
+func (cn *conn) maxMessageSize() int {
+	if cn.config != nil && cn.config.MaxMessageSize > 0 {
+		return cn.config.MaxMessageSize
+	}
+	return defaultMaxMessageSize
+}
+
+// how long checkLimits waits for the cancel request to be delivered
+const limitCancelTimeout = 10 * time.Second
+
+// checkLimits checks the DataRow in rs.rb against the size limits of the
+// connection. When one is exceeded, it cancels the query so the rest of the
+// result does not have to be read, and rs discards rows until the server is
+// ready for the next query.
+func (rs *rows) checkLimits() error {
+	cn := rs.cn
+	var err error
+	if len(rs.rb) == 0 {
+		// skipped by recvMessage
+		err = ErrRowTooLarge
+	} else if max := cn.config.MaxResultBytes; max > 0 {
+		rs.resultBytes += int64(len(rs.rb))
+		if rs.resultBytes > max {
+			err = ErrResultTooLarge
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	rs.limitErr = err
+
+	ctx, cancel := context.WithTimeout(context.Background(), limitCancelTimeout)
+	defer cancel()
+	if cerr := cn.cancel(ctx); cerr != nil {
+		cn.log(ctx, LogLevelWarn, "cannot cancel query after exceeding a size limit",
+			map[string]interface{}{"error": cerr})
+	}
+	return err
+}
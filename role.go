@@ -0,0 +1,79 @@
+package pq
+
+import "context"
+
+// SetRole sets the current role of the session, as SET ROLE does, so that
+// statements run with the privileges of role, e.g. those of the end user a
+// service acts for. password, if not empty, is sent in the PASSWORD clause
+// openGauss requires to switch to a role the session user is not a member
+// of.
+//
+// Within a transaction the role is set with SET LOCAL and reverts when the
+// transaction ends. Otherwise it holds until ResetRole is called, or until
+// the session is reset when the connection is taken out of a sql.DB or a
+// Pool again, so that a pooled connection never acts as another user.
+func (c *Conn) SetRole(ctx context.Context, role, password string) error {
+	return c.cn.setRole(ctx, "ROLE", role, password)
+}
+
+// SetSessionAuthorization sets the session user and the current user of the
+// session, as SET SESSION AUTHORIZATION does, which requires the session user
+// to be a system administrator. password and the duration of the change are
+// as for SetRole.
+func (c *Conn) SetSessionAuthorization(ctx context.Context, user, password string) error {
+	return c.cn.setRole(ctx, "SESSION AUTHORIZATION", user, password)
+}
+
+// ResetRole resets the role and the session authorization changed by SetRole
+// and SetSessionAuthorization.
+func (c *Conn) ResetRole(ctx context.Context) error {
+	if c.cn.getBad() {
+		return c.cn.errBadConn()
+	}
+	return c.cn.resetRole(ctx)
+}
+
+// setRole runs SET [LOCAL] what name [PASSWORD password]. It does not go
+// through the tracer or the query logs, and the wire trace redacts the
+// statement, which would show the password.
+func (cn *conn) setRole(ctx context.Context, what, name, password string) error {
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if cn.inCopy {
+		return errCopyInProgress
+	}
+	local := cn.txnStatus != txnStatusIdle
+	q := "SET "
+	if local {
+		q += "LOCAL "
+	}
+	q += what + " " + QuoteIdentifierConn(cn, name)
+	if password != "" {
+		q += " PASSWORD " + QuoteLiteralConn(cn, password)
+	}
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+	cn.redactQuery = password != ""
+	_, _, err := cn.simpleExec(q)
+	cn.redactQuery = false
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+	if !local {
+		cn.roleSet = true
+	}
+	return nil
+}
+
+func (cn *conn) resetRole(ctx context.Context) error {
+	if finish := cn.watchCancel(ctx); finish != nil {
+		defer finish()
+	}
+	if _, _, err := cn.simpleExec("RESET ROLE; RESET SESSION AUTHORIZATION"); err != nil {
+		return contextErr(ctx, err)
+	}
+	cn.roleSet = false
+	return nil
+}
@@ -0,0 +1,52 @@
+package pq
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+// TestSetRoleWireTrace checks that the password given to SetRole is not
+// written to the wire trace.
+func TestSetRoleWireTrace(t *testing.T) {
+	db := openFakeDB(t, "", nil, func(s *fakeServer) {
+		for {
+			if _, err := s.query('I'); err != nil {
+				return
+			}
+			s.complete("SET")
+			s.ready('I')
+			if err := s.flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	ctx := context.Background()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var trace bytes.Buffer
+	err = c.Raw(func(dc interface{}) error {
+		SetWireTrace(dc.(driver.Conn), &trace)
+		defer SetWireTrace(dc.(driver.Conn), nil)
+		cn := NewConn(dc.(driver.Conn))
+		if err := cn.SetRole(ctx, "alice", "s3cret-pw"); err != nil {
+			return err
+		}
+		return cn.ResetRole(ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(trace.String(), "s3cret-pw") {
+		t.Errorf("the password is in the wire trace:\n%s", trace.String())
+	}
+	if !strings.Contains(trace.String(), "RESET ROLE") {
+		t.Errorf("the statement after SetRole is not in the wire trace:\n%s", trace.String())
+	}
+}
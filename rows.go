@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/trymesoft/openGauss-connector-go-pq/oid"
@@ -24,28 +25,28 @@ type fieldDesc struct {
 	// The type modifier (see pg_attribute.atttypmod).
 	// The meaning of the modifier is type-specific.
 	Mod int
+	// The OID of the table and the attribute number of the column the
+	// field is taken from, or zero.
+	TableOID  oid.Oid
+	TableAttr int
 }
 
+// Type returns the type of the values decode returns for the field, so that
+// a value of that type, or a sql.Null of it, can be scanned into.
 func (fd fieldDesc) Type() reflect.Type {
 	switch fd.OID {
-	case oid.T_int8:
+	case oid.T_int8, oid.T_int4, oid.T_int2, oid.T_int1:
 		return reflect.TypeOf(int64(0))
-	case oid.T_int4:
-		return reflect.TypeOf(int32(0))
-	case oid.T_int2:
-		return reflect.TypeOf(int16(0))
-	case oid.T_varchar, oid.T_text:
+	case oid.T_char, oid.T_bpchar, oid.T_varchar, oid.T_nvarchar2, oid.T_text, oid.T_name, oid.T_clob:
 		return reflect.TypeOf("")
 	case oid.T_bool:
 		return reflect.TypeOf(false)
 	case oid.T_date, oid.T_time, oid.T_timetz, oid.T_timestamp, oid.T_timestamptz:
 		return reflect.TypeOf(time.Time{})
 	case oid.T_bytea, oid.T_byteawithoutorderwithequalcol, oid.T_byteawithoutordercol,
-		oid.T__byteawithoutorderwithequalcol, oid.T__byteawithoutordercol:
+		oid.T__byteawithoutorderwithequalcol, oid.T__byteawithoutordercol, oid.T_blob, oid.T_raw:
 		return reflect.TypeOf([]byte(nil))
-	case oid.T_float4:
-		return reflect.TypeOf(float32(0))
-	case oid.T_float8:
+	case oid.T_float4, oid.T_float8:
 		return reflect.TypeOf(float64(0))
 	default:
 		return reflect.TypeOf(new(interface{})).Elem()
@@ -98,6 +99,7 @@ type rowsHeader struct {
 type rows struct {
 	cn                      *conn
 	finish                  func()
+	span                    *traceSpan
 	rowsHeader              //TODO: pointer
 	done                    bool
 	rb                      readBuf
@@ -105,13 +107,19 @@ type rows struct {
 	tag                     string
 	disable_text_conversion bool
 
+	// size of the rows read so far, and the error returned when a limit
+	// was exceeded, see checkLimits
+	resultBytes int64
+	limitErr    error
+
 	next *rowsHeader
 }
 
-func (rs *rows) Close() error {
+func (rs *rows) Close() (err error) {
 	if finish := rs.finish; finish != nil {
 		defer finish()
 	}
+	defer func() { rs.span.end(rs.tag, err) }()
 	// no need to look at cn.bad as Next() will
 	for {
 		err := rs.Next(nil)
@@ -156,7 +164,7 @@ func (rs *rows) Next(dest []driver.Value) (err error) {
 
 	cn := rs.cn
 	if cn.getBad() {
-		return driver.ErrBadConn
+		return cn.errBadConn()
 	}
 
 	for {
@@ -188,6 +196,21 @@ func (rs *rows) Next(dest []driver.Value) (err error) {
 			}
 			return io.EOF
 		case 'D':
+			if rs.limitErr != nil {
+				// discard the rest of the result
+				continue
+			}
+			if dest == nil {
+				// Close discards the row whatever its size, and has to read
+				// on until the server is ready for the next query
+				cn.config.Stats.rowReturned()
+				return nil
+			}
+			if err := rs.checkLimits(); err != nil {
+				return err
+			}
+			cn.config.Stats.rowReturned()
+			cn.parameterStatus.resetRowBuf()
 			n := rs.rb.int16()
 			if n < len(dest) {
 				dest = dest[:n]
@@ -204,7 +227,7 @@ func (rs *rows) Next(dest []driver.Value) (err error) {
 			}
 			return err
 		case 'T':
-			next, err := parsePortalRowDescribe(&rs.rb)
+			next, err := parsePortalRowDescribe(&rs.cn.parameterStatus, &rs.rb)
 			if err != nil {
 				return fmt.Errorf("cannot parse protal row describe: %w", err)
 			}
@@ -232,11 +255,25 @@ func (rs *rows) NextResultSet() error {
 
 // ColumnTypeScanType returns the value type that can be used to scan types into.
 func (rs *rows) ColumnTypeScanType(index int) reflect.Type {
-	return rs.colTyps[index].Type()
+	t := rs.colTyps[index].Type()
+	if name, ok := rs.cn.parameterStatus.dolphinType(rs.colTyps[index].OID); ok {
+		t = dolphinTypes[name]
+	} else if rs.colTyps[index].OID == oid.T_numeric && rs.cn.parameterStatus.numericAsString {
+		t = reflect.TypeOf("")
+	}
+	if rs.disable_text_conversion || (t.Kind() == reflect.String && rs.cn.parameterStatus.textAsBytes) {
+		if rs.colFmts[index] == formatText {
+			return reflect.TypeOf([]byte(nil))
+		}
+	}
+	return t
 }
 
 // ColumnTypeDatabaseTypeName return the database system type name.
 func (rs *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if name, ok := rs.cn.parameterStatus.dolphinType(rs.colTyps[index].OID); ok {
+		return strings.ToUpper(name)
+	}
 	return rs.colTyps[index].Name()
 }
 
@@ -247,6 +284,34 @@ func (rs *rows) ColumnTypeLength(index int) (length int64, ok bool) {
 	return rs.colTyps[index].Length()
 }
 
+// RowsColumnTable is implemented by the rows of the driver, e.g. as
+// returned by the QueryContext method of a connection reached through
+// sql.Conn.Raw, to tell the table column each result column is taken from,
+// so that tooling can map result columns back to the catalog.
+type RowsColumnTable interface {
+	driver.Rows
+	// ColumnTableOID returns the OID of the table the column at index is
+	// taken from, its pg_class oid, or 0 if the column is not a column of
+	// a table, e.g. an expression.
+	ColumnTableOID(index int) oid.Oid
+	// ColumnTableAttributeNumber returns the attribute number, i.e.
+	// pg_attribute.attnum, of the table column the column at index is
+	// taken from, or 0 if it is not a column of a table.
+	ColumnTableAttributeNumber(index int) int
+}
+
+var _ RowsColumnTable = (*rows)(nil)
+
+// ColumnTableOID implements RowsColumnTable.
+func (rs *rows) ColumnTableOID(index int) oid.Oid {
+	return rs.colTyps[index].TableOID
+}
+
+// ColumnTableAttributeNumber implements RowsColumnTable.
+func (rs *rows) ColumnTableAttributeNumber(index int) int {
+	return rs.colTyps[index].TableAttr
+}
+
 // ColumnTypePrecisionScale should return the precision and scale for decimal
 // types. If not applicable, ok should be false.
 func (rs *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
@@ -0,0 +1,124 @@
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type routeKey struct{}
+
+// WithStandby returns a context that makes SplitDB run statements on a
+// standby.
+func WithStandby(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, true)
+}
+
+// WithPrimary returns a context that makes SplitDB run statements on the
+// primary, even read-only transactions.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, false)
+}
+
+// standbyHint is the comment which, at the start of a query, routes it to a
+// standby.
+const standbyHint = "/* standby */"
+
+// SplitDB splits statements between the primary and the standbys of a
+// cluster, so that reads can be offloaded without a proxy. It holds a pool of
+// connections to the primary and one to the standbys, picked from the hosts
+// of the connection string with target_session_attrs=read-write and
+// preferSlave respectively; reads fall back to the primary when no standby is
+// available.
+//
+// Statements run on the primary unless
+//   - the context was returned by WithStandby, or
+//   - the query starts with the comment /* standby */, or
+//   - they are in a transaction begun with sql.TxOptions.ReadOnly set,
+//
+// and the context was not returned by WithPrimary. Reads from a standby may
+// not yet see recent writes to the primary.
+type SplitDB struct {
+	Primary *sql.DB
+	Standby *sql.DB
+}
+
+// OpenSplitDB opens a SplitDB for the hosts of dsn. Any
+// target_session_attrs in dsn is overridden.
+func OpenSplitDB(dsn string) (*SplitDB, error) {
+	var dbs [2]*sql.DB
+	for i, attrs := range []uint8{targetSessionAttrsReadWrite, targetSessionAttrsPreferSlave} {
+		connector, err := newSplitConnector(dsn, attrs)
+		if err != nil {
+			if dbs[0] != nil {
+				_ = dbs[0].Close()
+			}
+			return nil, err
+		}
+		dbs[i] = sql.OpenDB(connector)
+	}
+	return &SplitDB{Primary: dbs[0], Standby: dbs[1]}, nil
+}
+
+// newSplitConnector returns a connector for dsn with the given target session
+// attributes.
+func newSplitConnector(dsn string, attrs uint8) (*Connector, error) {
+	cfg, distCfg, err := ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.targetSessionAttrs = attrs
+	return NewConnectorConfig(cfg, distCfg)
+}
+
+// db returns the pool query should run on.
+func (s *SplitDB) db(ctx context.Context, query string, readOnly bool) *sql.DB {
+	if standby, ok := ctx.Value(routeKey{}).(bool); ok {
+		readOnly = standby
+	} else if strings.HasPrefix(strings.TrimSpace(query), standbyHint) {
+		readOnly = true
+	}
+	if readOnly {
+		return s.Standby
+	}
+	return s.Primary
+}
+
+// ExecContext executes a query on the pool selected for it.
+func (s *SplitDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db(ctx, query, false).ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a query on the pool selected for it.
+func (s *SplitDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db(ctx, query, false).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query returning at most one row on the pool
+// selected for it.
+func (s *SplitDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db(ctx, query, false).QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx starts a transaction on the standbys if opts.ReadOnly is set, and
+// on the primary otherwise, unless ctx says differently.
+func (s *SplitDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return s.db(ctx, "", opts != nil && opts.ReadOnly).BeginTx(ctx, opts)
+}
+
+// PingContext checks the connections to the primary and to the standbys.
+func (s *SplitDB) PingContext(ctx context.Context) error {
+	if err := s.Primary.PingContext(ctx); err != nil {
+		return err
+	}
+	return s.Standby.PingContext(ctx)
+}
+
+// Close closes both pools.
+func (s *SplitDB) Close() error {
+	err := s.Primary.Close()
+	if serr := s.Standby.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
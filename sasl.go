@@ -0,0 +1,287 @@
+package pq
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/scram"
+)
+
+// SASLSession is the client side of one SASL authentication exchange.
+type SASLSession interface {
+	// Next returns the response to a challenge of the server. It is first
+	// called with a nil challenge for the initial response, which may be
+	// nil to send none.
+	Next(challenge []byte) ([]byte, error)
+	// Final checks the additional data of the final message of the server,
+	// e.g. the server signature of SCRAM, once the exchange succeeded.
+	Final(data []byte) error
+}
+
+// SASLRequest describes the connection a SASL exchange authenticates.
+type SASLRequest struct {
+	AuthTokenRequest
+	// Password returns the password of the connection, prepared with
+	// SASLprep unless Config.DisableSASLprep is set, or the token of
+	// Config.AuthTokenProvider if there is one. Mechanisms which do not use
+	// a password need not call it.
+	Password func() (string, error)
+	// MinIterations and MaxIterations bound the iteration count accepted
+	// from the server by mechanisms hashing the password, such as SCRAM,
+	// from Config.MinAuthIterations and Config.MaxAuthIterations.
+	MinIterations, MaxIterations int
+}
+
+// SASLMechanism starts an exchange of a SASL mechanism.
+type SASLMechanism func(ctx context.Context, req SASLRequest) (SASLSession, error)
+
+// SHA256Mechanism is the name the sha256 authentication of openGauss is
+// registered under. Servers do not advertise it by name: the mechanism
+// registered under SHA256Mechanism answers every sha256 authentication
+// request. Next is called once, with the salt, token and iteration count of
+// the request as the challenge, and Final with no data once the server
+// accepted the response.
+const SHA256Mechanism = "OPENGAUSS-SHA256"
+
+var (
+	saslMu         sync.RWMutex
+	saslMechanisms = map[string]SASLMechanism{
+		"SCRAM-SHA-256": newSCRAMSHA256,
+		SHA256Mechanism: newSHA256,
+	}
+)
+
+// RegisterSASLMechanism registers the client side of a SASL mechanism under
+// the name servers advertise it with, replacing any mechanism registered
+// under that name; a nil mechanism removes it. SCRAM-SHA-256 and
+// SHA256Mechanism are registered by default.
+//
+// When a server offers SASL authentication, the first of the mechanisms it
+// lists that is registered is used.
+func RegisterSASLMechanism(name string, m SASLMechanism) {
+	saslMu.Lock()
+	defer saslMu.Unlock()
+	if m == nil {
+		delete(saslMechanisms, name)
+		return
+	}
+	saslMechanisms[name] = m
+}
+
+// saslMechanismFor returns the first of the mechanisms named by the server
+// which is registered.
+func saslMechanismFor(names []string) (string, SASLMechanism, error) {
+	saslMu.RLock()
+	defer saslMu.RUnlock()
+	for _, name := range names {
+		if m, ok := saslMechanisms[name]; ok {
+			return name, m, nil
+		}
+	}
+	return "", nil, fmt.Errorf("pq: none of the SASL mechanisms offered by the server (%s) is registered",
+		strings.Join(names, ", "))
+}
+
+// parseSASLMechanisms parses the body of an AuthenticationSASL message, a
+// list of mechanism names, each terminated by a zero byte, terminated by a
+// zero byte. It returns false if data is not such a list, as is the case of
+// the body of the sha256 authentication request of openGauss, which uses
+// the same code and starts with a zero byte.
+func parseSASLMechanisms(data []byte) ([]string, bool) {
+	var names []string
+	for len(data) > 0 && data[0] != 0 {
+		i := 0
+		for i < len(data) && data[i] != 0 {
+			if data[i] < ' ' || data[i] > '~' {
+				return nil, false
+			}
+			i++
+		}
+		if i == len(data) {
+			return nil, false
+		}
+		names = append(names, string(data[:i]))
+		data = data[i+1:]
+	}
+	return names, len(names) > 0 && len(data) == 1
+}
+
+// authSASL runs a SASL exchange with one of the mechanisms listed in names.
+func (cn *conn) authSASL(ctx context.Context, names []string, password func() (string, error)) error {
+	name, m, err := saslMechanismFor(names)
+	if err != nil {
+		return err
+	}
+	req := SASLRequest{AuthTokenRequest: cn.authTokenRequest(), Password: password}
+	req.MinIterations, req.MaxIterations = cn.config.authIterationBounds()
+	session, err := m(ctx, req)
+	if err != nil {
+		return fmt.Errorf("cannot start SASL mechanism %s: %w", name, err)
+	}
+	resp, err := session.Next(nil)
+	if err != nil {
+		return fmt.Errorf("SASL mechanism %s: %w", name, err)
+	}
+	w := cn.writeBuf('p')
+	w.string(name)
+	if resp == nil {
+		w.int32(-1)
+	} else {
+		w.int32(len(resp))
+		w.bytes(resp)
+	}
+	if err := cn.send(w); err != nil {
+		return fmt.Errorf("fail to send: %w", err)
+	}
+
+	for {
+		t, r, err := cn.recv()
+		if err != nil {
+			return fmt.Errorf("cannot recv from conn: %w", err)
+		}
+		if t != 'R' {
+			return fmt.Errorf("unexpected SASL response: %q", t)
+		}
+		switch code := r.int32(); code {
+		case 11: // AuthenticationSASLContinue
+			resp, err := session.Next(*r)
+			if err != nil {
+				return fmt.Errorf("SASL mechanism %s: %w", name, err)
+			}
+			w := cn.writeBuf('p')
+			w.bytes(resp)
+			if err := cn.send(w); err != nil {
+				return fmt.Errorf("fail to send: %w", err)
+			}
+		case 12: // AuthenticationSASLFinal
+			if err := session.Final(*r); err != nil {
+				return fmt.Errorf("SASL mechanism %s: %w", name, err)
+			}
+		case 0:
+			cn.authInfo = AuthInfo{Method: AuthMethodSASLPrefix + name}
+			if s, ok := session.(scramSession); ok {
+				cn.authInfo.Iterations = s.c.IterationCount()
+			}
+			return nil
+		default:
+			return fmt.Errorf("unexpected authentication response during SASL exchange: %d", code)
+		}
+	}
+}
+
+// authSHA256 answers the sha256 authentication request of openGauss, whose
+// body is challenge, with the mechanism registered as SHA256Mechanism.
+func (cn *conn) authSHA256(ctx context.Context, challenge []byte, password func() (string, error)) error {
+	_, m, err := saslMechanismFor([]string{SHA256Mechanism})
+	if err != nil {
+		return err
+	}
+	req := SASLRequest{AuthTokenRequest: cn.authTokenRequest(), Password: password}
+	req.MinIterations, req.MaxIterations = cn.config.authIterationBounds()
+	session, err := m(ctx, req)
+	if err != nil {
+		return fmt.Errorf("cannot start %s: %w", SHA256Mechanism, err)
+	}
+	resp, err := session.Next(challenge)
+	if err != nil {
+		return fmt.Errorf("%s: %w", SHA256Mechanism, err)
+	}
+	w := cn.writeBuf('p')
+	w.bytes(resp)
+	w.byte(0)
+	if err := cn.send(w); err != nil {
+		return fmt.Errorf("fail to send: %w", err)
+	}
+
+	t, r, err := cn.recv()
+	if err != nil {
+		return fmt.Errorf("cannot recv from conn: %w", err)
+	}
+	if t != 'R' {
+		return fmt.Errorf("unexpected password response: %q", t)
+	}
+	if r.int32() != 0 {
+		return fmt.Errorf("unexpected authentication response: %q", t)
+	}
+	if err := session.Final(nil); err != nil {
+		return fmt.Errorf("%s: %w", SHA256Mechanism, err)
+	}
+	cn.authInfo = AuthInfo{Method: AuthMethodSHA256}
+	if s, ok := session.(*sha256Session); ok {
+		cn.authInfo.Iterations = s.iterations
+	}
+	return nil
+}
+
+// sha256Session is the sha256 authentication of openGauss, based on RFC
+// 5802.
+type sha256Session struct {
+	password   string
+	min, max   int
+	iterations int
+}
+
+func newSHA256(_ context.Context, req SASLRequest) (SASLSession, error) {
+	password, err := req.Password()
+	if err != nil {
+		return nil, err
+	}
+	return &sha256Session{password: password, min: req.MinIterations, max: req.MaxIterations}, nil
+}
+
+func (s *sha256Session) Next(challenge []byte) ([]byte, error) {
+	if len(challenge) < 64+8+4 {
+		return nil, errors.New("server sent a truncated sha256 request")
+	}
+	r := readBuf(challenge)
+	salt := string(r.next(64))
+	token := string(r.next(8))
+	iterations := r.int32()
+	if err := checkSHA256Request(salt, token, iterations, s.min, s.max); err != nil {
+		return nil, err
+	}
+	result := RFC5802Algorithm(s.password, salt, token, "", iterations)
+	if len(result) == 0 {
+		return nil, errors.New("invalid username/password,login denied")
+	}
+	s.iterations = iterations
+	return result, nil
+}
+
+func (s *sha256Session) Final([]byte) error {
+	return nil
+}
+
+// scramSession adapts a scram.Client to SASLSession.
+type scramSession struct {
+	c *scram.Client
+}
+
+func newSCRAMSHA256(_ context.Context, req SASLRequest) (SASLSession, error) {
+	password, err := req.Password()
+	if err != nil {
+		return nil, err
+	}
+	c := scram.NewClient(sha256.New, req.User, password)
+	c.SetIterationBounds(req.MinIterations, req.MaxIterations)
+	return scramSession{c: c}, nil
+}
+
+func (s scramSession) Next(challenge []byte) ([]byte, error) {
+	s.c.Step(challenge)
+	if err := s.c.Err(); err != nil {
+		return nil, err
+	}
+	return s.c.Out(), nil
+}
+
+func (s scramSession) Final(data []byte) error {
+	if !s.c.Step(data) {
+		return errors.New("SCRAM exchange did not complete")
+	}
+	return s.c.Err()
+}
@@ -0,0 +1,135 @@
+package pq
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/unicode/norm"
+)
+
+// runeRange is an inclusive range of code points.
+type runeRange struct{ lo, hi rune }
+
+func inRanges(r rune, ranges []runeRange) bool {
+	for _, rr := range ranges {
+		if r < rr.lo {
+			return false
+		}
+		if r <= rr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// The tables of RFC 3454 used by the SASLprep profile of RFC 4013, sorted.
+var (
+	// C.1.2, mapped to a space
+	saslprepSpace = []runeRange{
+		{0x00A0, 0x00A0}, {0x1680, 0x1680}, {0x2000, 0x200B}, {0x202F, 0x202F},
+		{0x205F, 0x205F}, {0x3000, 0x3000},
+	}
+	// B.1, mapped to nothing
+	saslprepNothing = []runeRange{
+		{0x00AD, 0x00AD}, {0x034F, 0x034F}, {0x1806, 0x1806}, {0x180B, 0x180D},
+		{0x200B, 0x200D}, {0x2060, 0x2060}, {0xFE00, 0xFE0F}, {0xFEFF, 0xFEFF},
+	}
+	// C.1.2, C.2.1, C.2.2, C.3, C.4, C.5, C.6, C.7, C.8 and C.9
+	saslprepProhibited = []runeRange{
+		{0x0000, 0x001F}, {0x007F, 0x009F}, {0x00A0, 0x00A0},
+		{0x0340, 0x0341}, {0x06DD, 0x06DD}, {0x070F, 0x070F},
+		{0x1680, 0x1680}, {0x180E, 0x180E}, {0x2000, 0x200F},
+		{0x2028, 0x202F}, {0x205F, 0x2063}, {0x206A, 0x206F},
+		{0x2FF0, 0x2FFB}, {0x3000, 0x3000}, {0xD800, 0xF8FF},
+		{0xFDD0, 0xFDEF}, {0xFEFF, 0xFEFF}, {0xFFF9, 0xFFFF},
+		{0x1D173, 0x1D17A}, {0x1FFFE, 0x1FFFF}, {0x2FFFE, 0x2FFFF},
+		{0x3FFFE, 0x3FFFF}, {0x4FFFE, 0x4FFFF}, {0x5FFFE, 0x5FFFF},
+		{0x6FFFE, 0x6FFFF}, {0x7FFFE, 0x7FFFF}, {0x8FFFE, 0x8FFFF},
+		{0x9FFFE, 0x9FFFF}, {0xAFFFE, 0xAFFFF}, {0xBFFFE, 0xBFFFF},
+		{0xCFFFE, 0xCFFFF}, {0xDFFFE, 0xDFFFF}, {0xE0001, 0xE0001},
+		{0xE0020, 0xE007F}, {0xEFFFE, 0x10FFFF},
+	}
+)
+
+// saslPrep prepares password with the SASLprep profile of RFC 4013, as
+// stored strings. Like libpq and the server, which prepares the password
+// the same way when storing its verifier, it reports false for passwords
+// that are not valid UTF-8 or that SASLprep prohibits, which are then used
+// as is.
+func saslPrep(password string) (string, bool) {
+	if !utf8.ValidString(password) {
+		return "", false
+	}
+	ascii := true
+	for i := 0; i < len(password); i++ {
+		if c := password[i]; c >= utf8.RuneSelf || c < 0x20 || c == 0x7F {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		// printable ASCII is left unchanged
+		return password, true
+	}
+
+	var sb strings.Builder
+	for _, r := range password {
+		switch {
+		case inRanges(r, saslprepSpace):
+			sb.WriteByte(' ')
+		case inRanges(r, saslprepNothing):
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	prepped := norm.NFKC.String(sb.String())
+
+	var randAL, l bool
+	for _, r := range prepped {
+		if inRanges(r, saslprepProhibited) || !assigned(r) {
+			return "", false
+		}
+		switch p, _ := bidi.LookupRune(r); p.Class() {
+		case bidi.R, bidi.AL:
+			randAL = true
+		case bidi.L:
+			l = true
+		}
+	}
+	if randAL {
+		// a string with right-to-left characters must not have left-to-right
+		// ones, and must start and end with right-to-left ones
+		first, _ := utf8.DecodeRuneInString(prepped)
+		last, _ := utf8.DecodeLastRuneInString(prepped)
+		if l || !isRandAL(first) || !isRandAL(last) {
+			return "", false
+		}
+	}
+	return prepped, true
+}
+
+func isRandAL(r rune) bool {
+	p, _ := bidi.LookupRune(r)
+	return p.Class() == bidi.R || p.Class() == bidi.AL
+}
+
+// assigned reports whether r is assigned a character by the version of
+// Unicode of package unicode.
+func assigned(r rune) bool {
+	return unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S,
+		unicode.Z, unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs)
+}
+
+// preparePassword returns password prepared for SCRAM and sha256
+// authentication, with SASLprep unless Config.DisableSASLprep is set.
+func (cn *conn) preparePassword(password string) string {
+	if cn.config.DisableSASLprep {
+		return password
+	}
+	if prepped, ok := saslPrep(password); ok {
+		return prepped
+	}
+	return password
+}
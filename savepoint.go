@@ -0,0 +1,107 @@
+package pq
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errNoSavepointOutsideTxn = errors.New("pq: savepoints are only allowed inside a transaction")
+
+// Savepointer is implemented by the driver connection and emulates nested
+// transactions with savepoints. Reach it through database/sql's Conn.Raw
+// while a transaction started on that Conn is open:
+//
+//	conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(pq.Savepointer).Savepoint("before_import")
+//	})
+//
+// Names are quoted with QuoteIdentifier, so any string may be used. The
+// savepoints are forgotten when the transaction ends.
+type Savepointer interface {
+	// Savepoint establishes a new savepoint in the current transaction.
+	Savepoint(name string) error
+	// RollbackTo rolls back to the most recent savepoint with the given
+	// name. The savepoint itself stays established, savepoints created after
+	// it are destroyed.
+	RollbackTo(name string) error
+	// Release destroys the most recent savepoint with the given name and all
+	// savepoints created after it, keeping their effects.
+	Release(name string) error
+	// SavepointDepth returns the number of established savepoints.
+	SavepointDepth() int
+}
+
+var _ Savepointer = (*conn)(nil)
+
+func (cn *conn) Savepoint(name string) error {
+	cn.LockReaderMutex()
+	defer cn.UnlockReaderMutex()
+	if err := cn.savepointExec("SAVEPOINT " + QuoteIdentifier(name)); err != nil {
+		return err
+	}
+	cn.savepoints = append(cn.savepoints, name)
+	return nil
+}
+
+func (cn *conn) RollbackTo(name string) error {
+	cn.LockReaderMutex()
+	defer cn.UnlockReaderMutex()
+	i, err := cn.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+	if err = cn.savepointExec("ROLLBACK TO SAVEPOINT " + QuoteIdentifier(name)); err != nil {
+		return err
+	}
+	cn.savepoints = cn.savepoints[:i+1]
+	return nil
+}
+
+func (cn *conn) Release(name string) error {
+	cn.LockReaderMutex()
+	defer cn.UnlockReaderMutex()
+	i, err := cn.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+	if err = cn.savepointExec("RELEASE SAVEPOINT " + QuoteIdentifier(name)); err != nil {
+		return err
+	}
+	cn.savepoints = cn.savepoints[:i]
+	return nil
+}
+
+func (cn *conn) SavepointDepth() int {
+	if !cn.isInTransaction() {
+		return 0
+	}
+	return len(cn.savepoints)
+}
+
+func (cn *conn) findSavepoint(name string) (int, error) {
+	if !cn.isInTransaction() {
+		return 0, errNoSavepointOutsideTxn
+	}
+	for i := len(cn.savepoints) - 1; i >= 0; i-- {
+		if cn.savepoints[i] == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("pq: savepoint %s does not exist", QuoteIdentifier(name))
+}
+
+func (cn *conn) savepointExec(q string) error {
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if !cn.isInTransaction() {
+		// the transaction may have been ended by a statement rather than
+		// through Commit or Rollback
+		cn.savepoints = nil
+		return errNoSavepointOutsideTxn
+	}
+	if _, _, err := cn.simpleExec(q); err != nil {
+		return fmt.Errorf("fail to simple exec: %w", err)
+	}
+	return nil
+}
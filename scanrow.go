@@ -0,0 +1,181 @@
+package pq
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// binaryTypeNames are the database type names of the columns whose []byte
+// values ScanMap keeps as []byte.
+var binaryTypeNames = map[string]bool{
+	"BYTEA":      true,
+	"BLOB":       true,
+	"RAW":        true,
+	"TINYBLOB":   true,
+	"MEDIUMBLOB": true,
+	"LONGBLOB":   true,
+}
+
+// ScanMap scans the current row of rows into a map from column names to
+// values. Values have the types listed in Data Types, and are nil for NULL;
+// the []byte values of types returned in text format, such as numeric or
+// json, are converted to string, so that the map can be printed or encoded
+// as JSON as is. Columns must have distinct names.
+//
+//	for rows.Next() {
+//		m, err := pq.ScanMap(rows)
+//		...
+//	}
+func ScanMap(rows *sql.Rows) (map[string]interface{}, error) {
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(cols))
+	for i, col := range cols {
+		name := col.Name()
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("pq: duplicate column %q; use an alias", name)
+		}
+		if b, ok := values[i].([]byte); ok && !binaryTypeNames[col.DatabaseTypeName()] {
+			values[i] = string(b)
+		}
+		m[name] = values[i]
+	}
+	return m, nil
+}
+
+// ScanStruct scans the current row of rows into the fields of the struct
+// dest points to. A column is scanned into the field whose db tag is its
+// name, or, for fields without a db tag, whose name equals it ignoring case,
+// e.g. column user_id into field UserID `db:"user_id"` and column email into
+// field Email. Fields tagged db:"-" and unexported fields are skipped;
+// the fields of embedded structs are matched as if they were fields of
+// dest. Every column must have a field, which is scanned into as by
+// rows.Scan, so NULL columns need pointer or sql.Null fields.
+//
+//	var u struct {
+//		ID    int64
+//		Name  string
+//		Email *string `db:"email_address"`
+//	}
+//	for rows.Next() {
+//		if err := pq.ScanStruct(rows, &u); err != nil {
+//			...
+//		}
+//	}
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("pq: ScanStruct destination must be a non-nil pointer to a struct, not %T", dest)
+	}
+	v = v.Elem()
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	fields := structFieldsOf(v.Type())
+	ptrs := make([]interface{}, len(cols))
+	for i, col := range cols {
+		index, ok := fields.byName[col.Name()]
+		if !ok {
+			index, ok = fields.byFold[strings.ToLower(col.Name())]
+		}
+		if !ok {
+			return fmt.Errorf("pq: no field of %s for column %q", v.Type(), col.Name())
+		}
+		ptrs[i] = fieldByIndexAlloc(v, index).Addr().Interface()
+	}
+	return rows.Scan(ptrs...)
+}
+
+// structFields maps column names to the index paths of struct fields, by
+// db tag and by lower case field name.
+type structFields struct {
+	byName map[string][]int
+	byFold map[string][]int
+}
+
+var structFieldsCache sync.Map // reflect.Type -> *structFields
+
+func structFieldsOf(t reflect.Type) *structFields {
+	if f, ok := structFieldsCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f := &structFields{byName: make(map[string][]int), byFold: make(map[string][]int)}
+	f.add(t, nil)
+	structFieldsCache.Store(t, f)
+	return f
+}
+
+func (f *structFields) add(t reflect.Type, parent []int) {
+	// fields of embedded structs are added after the fields of t, which
+	// take precedence as in Go
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(parent[:len(parent):len(parent)], i)
+		tag, tagged := sf.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && !tagged && ft.Kind() == reflect.Struct {
+			// a nil pointer to an unexported struct cannot be allocated
+			if sf.Type.Kind() != reflect.Ptr || sf.PkgPath == "" {
+				sf.Index = index
+				embedded = append(embedded, sf)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if tagged && tag != "" {
+			if _, ok := f.byName[tag]; !ok {
+				f.byName[tag] = index
+			}
+			continue
+		}
+		name := strings.ToLower(sf.Name)
+		if _, ok := f.byFold[name]; !ok {
+			f.byFold[name] = index
+		}
+	}
+	for _, sf := range embedded {
+		t := sf.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f.add(t, sf.Index)
+	}
+}
+
+// fieldByIndexAlloc is reflect.Value.FieldByIndex, allocating the nil
+// pointers to embedded structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
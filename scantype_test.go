@@ -0,0 +1,59 @@
+package pq
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/trymesoft/openGauss-connector-go-pq/oid"
+)
+
+// TestScanTypeNull checks that a NULL of every type can be scanned into a
+// pointer to the type ColumnType.ScanType reports for it.
+func TestScanTypeNull(t *testing.T) {
+	var oids []oid.Oid
+	for o := range oid.TypeName {
+		oids = append(oids, o)
+	}
+	sort.Slice(oids, func(i, j int) bool { return oids[i] < oids[j] })
+
+	db := openFakeDB(t, "", nil, func(s *fakeServer) {
+		for {
+			if _, err := s.query('I'); err != nil {
+				return
+			}
+			s.describe(oids)
+			s.row(make([][]byte, len(oids))...)
+			s.complete("SELECT 1")
+			s.ready('I')
+			if err := s.flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	dest := make([]interface{}, len(types))
+	for i, ct := range types {
+		dest[i] = reflect.New(reflect.PtrTo(ct.ScanType())).Interface()
+	}
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range dest {
+		if p := reflect.ValueOf(d).Elem(); !p.IsNil() {
+			t.Errorf("%s: NULL scanned into %s as %v", oid.TypeName[oids[i]], p.Type(), p.Elem())
+		}
+	}
+}
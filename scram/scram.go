@@ -52,6 +52,9 @@ type Client struct {
 	serverNonce []byte
 	saltedPass  []byte
 	authMsg     bytes.Buffer
+
+	minIter, maxIter int
+	iterCount        int
 }
 
 // NewClient returns a new SCRAM-* client with the provided hash algorithm.
@@ -64,6 +67,7 @@ func NewClient(newHash func() hash.Hash, user, pass string) *Client {
 		newHash: newHash,
 		user:    user,
 		pass:    pass,
+		minIter: 1000,
 	}
 	c.out.Grow(256)
 	c.authMsg.Grow(256)
@@ -83,6 +87,21 @@ func (c *Client) Err() error {
 	return c.err
 }
 
+// SetIterationBounds sets the range of iteration counts accepted from the
+// server, whose first message is rejected if its count falls outside. A
+// count too low weakens the salted password against brute force, and one
+// too high keeps the client hashing for a long time. max 0 means no upper
+// bound. The default bounds are 1000 and none.
+func (c *Client) SetIterationBounds(min, max int) {
+	c.minIter, c.maxIter = min, max
+}
+
+// IterationCount returns the iteration count sent by the server, or 0 if
+// it has not been received yet.
+func (c *Client) IterationCount() int {
+	return c.iterCount
+}
+
 // SetNonce sets the client nonce to the provided value.
 // If not set, the nonce is generated automatically out of crypto/rand on the first step.
 func (c *Client) SetNonce(nonce []byte) {
@@ -149,7 +168,7 @@ func (c *Client) step2(in []byte) error {
 	if !bytes.HasPrefix(fields[1], []byte("s=")) || len(fields[1]) < 6 {
 		return fmt.Errorf("server sent an invalid SCRAM-SHA-256 salt: %q", fields[1])
 	}
-	if !bytes.HasPrefix(fields[2], []byte("i=")) || len(fields[2]) < 6 {
+	if !bytes.HasPrefix(fields[2], []byte("i=")) || len(fields[2]) < 3 {
 		return fmt.Errorf("server sent an invalid SCRAM-SHA-256 iteration count: %q", fields[2])
 	}
 
@@ -168,6 +187,13 @@ func (c *Client) step2(in []byte) error {
 	if err != nil {
 		return fmt.Errorf("server sent an invalid SCRAM-SHA-256 iteration count: %q", fields[2])
 	}
+	if iterCount < c.minIter {
+		return fmt.Errorf("server sent a SCRAM-SHA-256 iteration count of %d, below the minimum of %d", iterCount, c.minIter)
+	}
+	if c.maxIter > 0 && iterCount > c.maxIter {
+		return fmt.Errorf("server sent a SCRAM-SHA-256 iteration count of %d, above the maximum of %d", iterCount, c.maxIter)
+	}
+	c.iterCount = iterCount
 	c.saltPassword(salt, iterCount)
 
 	c.authMsg.WriteString(",c=biws,r=")
@@ -0,0 +1,62 @@
+package pq
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// interpolate replaces the $1, $2, ... placeholders of query with the
+// arguments as quoted literals, for Config.PreferSimpleProtocol. Like
+// parameters of the extended protocol, the literals are untyped and take
+// their type from the context; []byte arguments are sent in bytea format.
+func (cn *conn) interpolate(query string, args []driver.NamedValue) (string, error) {
+	literals := make([]string, len(args))
+	for i, a := range args {
+		if a.Value == nil {
+			literals[i] = "NULL"
+			continue
+		}
+		var (
+			b   []byte
+			err error
+		)
+		if v, ok := a.Value.([]byte); ok {
+			b = encodeBytea(cn.parameterStatus.serverVersion, v)
+		} else if b, err = encode(&cn.parameterStatus, a.Value, 0); err != nil {
+			return "", fmt.Errorf("cannot encode argument %d: %w", i+1, err)
+		}
+		literals[i] = QuoteLiteralConn(cn, string(b))
+	}
+
+	var sb strings.Builder
+	for _, seg := range splitSQL(query, cn.backslashEscapes()) {
+		if !seg.code {
+			sb.WriteString(seg.text)
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			j := i + 1
+			if s[i] != '$' || (i > 0 && isIdentChar(s[i-1])) {
+				sb.WriteByte(s[i])
+				continue
+			}
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				sb.WriteByte(s[i])
+				continue
+			}
+			n, err := strconv.Atoi(s[i+1 : j])
+			if err != nil || n < 1 || n > len(literals) {
+				return "", fmt.Errorf("pq: no argument for placeholder %s", s[i:j])
+			}
+			sb.WriteString(literals[n-1])
+			i = j - 1
+		}
+	}
+	return sb.String(), nil
+}
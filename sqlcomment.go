@@ -0,0 +1,115 @@
+package pq
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SQLCommentPosition is where the comment carrying Config.SQLCommentTags and
+// the tags of WithSQLCommentTags is placed in statements.
+type SQLCommentPosition string
+
+const (
+	// SQLCommentAppend appends the comment to the statement, before any
+	// trailing semicolons and comments. It is the default.
+	SQLCommentAppend SQLCommentPosition = "append"
+	// SQLCommentPrepend puts the comment before the statement, where it is
+	// kept by servers and proxies that truncate long statements in logs.
+	// It is still appended to COPY statements.
+	SQLCommentPrepend SQLCommentPosition = "prepend"
+	// SQLCommentOff sends statements without the comment, whatever the tags.
+	SQLCommentOff SQLCommentPosition = "off"
+)
+
+type sqlCommentKey struct{}
+
+// WithSQLCommentTags returns a context that adds tags to the comment of the
+// statements run with it, e.g. the W3C traceparent of the current span, so
+// that the statements can be found in the server's logs from a client
+// trace:
+//
+//	ctx = pq.WithSQLCommentTags(ctx, map[string]string{
+//		"traceparent": "00-" + traceID + "-" + spanID + "-01",
+//	})
+//	db.ExecContext(ctx, "UPDATE accounts SET balance = 0 WHERE id = $1", id)
+//
+// sends
+//
+//	UPDATE accounts SET balance = 0 WHERE id = $1 /*traceparent='00-...-01'*/
+//
+// Tags override the tags of the same name of Config.SQLCommentTags and of
+// outer calls of WithSQLCommentTags.
+func WithSQLCommentTags(ctx context.Context, tags map[string]string) context.Context {
+	if outer, _ := ctx.Value(sqlCommentKey{}).(map[string]string); len(outer) > 0 {
+		merged := make(map[string]string, len(outer)+len(tags))
+		for k, v := range outer {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		tags = merged
+	}
+	return context.WithValue(ctx, sqlCommentKey{}, tags)
+}
+
+// sqlComment returns the comment for the tags of the config and of ctx in
+// the sqlcommenter format, keys and values URL-encoded and values quoted,
+// sorted by key, or "" if there are none.
+func (c *Config) sqlComment(ctx context.Context) string {
+	if c.SQLComment == SQLCommentOff {
+		return ""
+	}
+	ctxTags, _ := ctx.Value(sqlCommentKey{}).(map[string]string)
+	if len(c.SQLCommentTags) == 0 && len(ctxTags) == 0 {
+		return ""
+	}
+	tags := make(map[string]string, len(c.SQLCommentTags)+len(ctxTags))
+	for k, v := range c.SQLCommentTags {
+		tags[k] = v
+	}
+	for k, v := range ctxTags {
+		tags[k] = v
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("/*")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(sqlCommentEscape(k))
+		sb.WriteString("='")
+		sb.WriteString(sqlCommentEscape(tags[k]))
+		sb.WriteByte('\'')
+	}
+	sb.WriteString("*/")
+	return sb.String()
+}
+
+// sqlCommentEscape URL-encodes s, which leaves no quotes, backslashes or
+// comment delimiters in it.
+func sqlCommentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// addSQLComment adds the comment for ctx to query, if any.
+func (cn *conn) addSQLComment(ctx context.Context, query string) string {
+	comment := cn.config.sqlComment(ctx)
+	if comment == "" || strings.TrimSpace(query) == "" {
+		return query
+	}
+	// COPY statements are recognized by their first word
+	if cn.config.SQLComment == SQLCommentPrepend && !(len(query) >= 4 && strings.EqualFold(query[:4], "COPY")) {
+		return comment + " " + query
+	}
+	end := statementEnd(query, splitSQL(query, cn.backslashEscapes()))
+	return query[:end] + " " + comment + query[end:]
+}
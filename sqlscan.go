@@ -0,0 +1,225 @@
+package pq
+
+import (
+	"math"
+	"strings"
+)
+
+// sqlSegment is a piece of an SQL string. Code segments are outside string
+// literals, quoted identifiers and comments.
+type sqlSegment struct {
+	text string
+	code bool
+}
+
+// splitSQL splits q into code and non-code segments. backslashEscapes is
+// whether backslashes escape quotes in ordinary string literals, i.e.
+// standard_conforming_strings is off; they always do in escape string
+// literals such as E'\n'.
+func splitSQL(q string, backslashEscapes bool) []sqlSegment {
+	var segs []sqlSegment
+	start := 0
+	flush := func(end int, code bool) {
+		if end > start {
+			segs = append(segs, sqlSegment{text: q[start:end], code: code})
+		}
+		start = end
+	}
+
+	for i := 0; i < len(q); {
+		c := q[i]
+		var end int
+		switch {
+		case c == '\'':
+			escapes := backslashEscapes || (i > 0 && (q[i-1] == 'E' || q[i-1] == 'e') &&
+				(i == 1 || !isIdentChar(q[i-2])))
+			end = scanQuoted(q, i, '\'', escapes)
+		case c == '"' || c == '`':
+			end = scanQuoted(q, i, c, false)
+		case c == '-' && strings.HasPrefix(q[i:], "--"):
+			end = strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				end = len(q)
+			} else {
+				end += i + 1
+			}
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			end = scanBlockComment(q, i)
+		case c == '$' && (i == 0 || !isIdentChar(q[i-1])):
+			end = scanDollarQuoted(q, i)
+		}
+		if end == 0 {
+			i++
+			continue
+		}
+		flush(i, true)
+		flush(end, false)
+		i = end
+	}
+	flush(len(q), true)
+	return segs
+}
+
+// multipleStatements reports whether q contains more than one statement,
+// i.e. code after a semicolon outside literals and comments. Empty
+// statements, as in a trailing semicolon, do not count.
+func multipleStatements(q string, backslashEscapes bool) bool {
+	ended := false
+	for _, seg := range splitSQL(q, backslashEscapes) {
+		if !seg.code {
+			if ended && !isComment(seg.text) {
+				return true
+			}
+			continue
+		}
+		for i := 0; i < len(seg.text); i++ {
+			switch c := seg.text[i]; {
+			case c == ';':
+				ended = true
+			case ended && !isSpace(c):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxPlaceholder returns the highest $n parameter number in the code of q,
+// or 0 if there is none. Numbers too large for an int are returned as
+// math.MaxInt32.
+func maxPlaceholder(q string, backslashEscapes bool) int {
+	max := 0
+	for _, seg := range splitSQL(q, backslashEscapes) {
+		if !seg.code {
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			if s[i] != '$' || (i > 0 && isIdentChar(s[i-1])) {
+				continue
+			}
+			n := 0
+			for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				if n < math.MaxInt32/10 {
+					n = n*10 + int(s[i]-'0')
+				} else {
+					n = math.MaxInt32
+				}
+			}
+			i--
+			if n > max {
+				max = n
+			}
+		}
+	}
+	return max
+}
+
+// statementEnd returns the end of the last statement of q, split into segs,
+// before the semicolons, white space and comments that follow it.
+func statementEnd(q string, segs []sqlSegment) int {
+	end := len(q)
+	for i := len(segs) - 1; i >= 0; i-- {
+		s := segs[i].text
+		if !segs[i].code {
+			if !isComment(s) {
+				break
+			}
+			end -= len(s)
+			continue
+		}
+		t := strings.TrimRight(s, " \t\r\n\f\v;")
+		end -= len(s) - len(t)
+		if t != "" {
+			break
+		}
+	}
+	return end
+}
+
+// hasKeyword reports whether s contains the keyword kw, in any case, as a
+// word of its own.
+func hasKeyword(s, kw string) bool {
+	for i := 0; i+len(kw) <= len(s); i++ {
+		if (i == 0 || !isIdentChar(s[i-1])) && strings.EqualFold(s[i:i+len(kw)], kw) &&
+			(i+len(kw) == len(s) || !isIdentChar(s[i+len(kw)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isComment(s string) bool {
+	return strings.HasPrefix(s, "--") || strings.HasPrefix(s, "/*")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// scanQuoted returns the end of the quoted string starting at q[i]. A doubled
+// quote stands for itself.
+func scanQuoted(q string, i int, quote byte, backslashEscapes bool) int {
+	for j := i + 1; j < len(q); j++ {
+		switch q[j] {
+		case '\\':
+			if backslashEscapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(q) && q[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(q)
+}
+
+// scanBlockComment returns the end of the comment starting at q[i]. Block
+// comments nest.
+func scanBlockComment(q string, i int) int {
+	depth := 0
+	for j := i; j < len(q)-1; j++ {
+		switch {
+		case q[j] == '/' && q[j+1] == '*':
+			depth++
+			j++
+		case q[j] == '*' && q[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(q)
+}
+
+// scanDollarQuoted returns the end of the dollar-quoted string starting at
+// q[i], or 0 if q[i] does not start one, e.g. because it is a $1 parameter.
+func scanDollarQuoted(q string, i int) int {
+	j := i + 1
+	if j < len(q) && q[j] >= '0' && q[j] <= '9' {
+		return 0
+	}
+	for j < len(q) && q[j] != '$' {
+		if !isIdentChar(q[j]) {
+			return 0
+		}
+		j++
+	}
+	if j >= len(q) {
+		return 0
+	}
+	tag := q[i : j+1]
+	if k := strings.Index(q[j+1:], tag); k >= 0 {
+		return j + 1 + k + len(tag)
+	}
+	return len(q)
+}
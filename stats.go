@@ -0,0 +1,226 @@
+package pq
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationBuckets are the upper bounds of the histogram buckets used for
+// timing statistics. Observations above the last bound are counted in an
+// additional overflow bucket.
+var durationBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+type durationHistogram struct {
+	count   int64
+	sum     int64
+	buckets [9]int64 // len(durationBuckets) + overflow
+}
+
+func (h *durationHistogram) observe(d time.Duration) {
+	i := sort.Search(len(durationBuckets), func(i int) bool { return d <= durationBuckets[i] })
+	atomic.AddInt64(&h.buckets[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddInt64(&h.count, 1)
+}
+
+func (h *durationHistogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Count:   atomic.LoadInt64(&h.count),
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+		Bounds:  durationBuckets,
+		Buckets: make([]int64, len(h.buckets)),
+	}
+	for i := range h.buckets {
+		s.Buckets[i] = atomic.LoadInt64(&h.buckets[i])
+	}
+	return s
+}
+
+// HistogramSnapshot is a point-in-time copy of a timing histogram.
+// Buckets[i] counts the observations less than or equal to Bounds[i] and
+// greater than Bounds[i-1]; the last element of Buckets counts observations
+// greater than every bound.
+type HistogramSnapshot struct {
+	Count   int64
+	Sum     time.Duration
+	Bounds  []time.Duration
+	Buckets []int64
+}
+
+// StatsSnapshot is a point-in-time copy of the counters collected by Stats.
+type StatsSnapshot struct {
+	ConnectionsOpened int64
+	ConnectionsFailed int64
+	AuthTime          HistogramSnapshot
+	TLSHandshakeTime  HistogramSnapshot
+	// Commands counts completed commands by the first word of their command
+	// tag, e.g. "SELECT", "INSERT" or "COPY".
+	Commands           map[string]int64
+	RowsReturned       int64
+	BytesRead          int64
+	BytesWritten       int64
+	CancelRequestsSent int64
+	// NoticesReceived counts the notices received from the server, and
+	// NoticesSuppressed those of them dropped for being less severe than
+	// Config.MinNoticeSeverity.
+	NoticesReceived   int64
+	NoticesSuppressed int64
+}
+
+// Stats collects driver statistics for every connection created from the
+// Config it is assigned to. A single Stats may be shared by several configs.
+// The zero value is ready to use and all methods are safe for concurrent use.
+//
+// Stats implements the expvar.Var interface, so it can be exported with
+// expvar.Publish.
+type Stats struct {
+	connectionsOpened  int64
+	connectionsFailed  int64
+	rowsReturned       int64
+	bytesRead          int64
+	bytesWritten       int64
+	cancelRequestsSent int64
+	noticesReceived    int64
+	noticesSuppressed  int64
+
+	authTime         durationHistogram
+	tlsHandshakeTime durationHistogram
+
+	commandsMu sync.Mutex
+	commands   map[string]int64
+}
+
+// Snapshot returns a copy of the current statistics.
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		ConnectionsOpened:  atomic.LoadInt64(&s.connectionsOpened),
+		ConnectionsFailed:  atomic.LoadInt64(&s.connectionsFailed),
+		AuthTime:           s.authTime.snapshot(),
+		TLSHandshakeTime:   s.tlsHandshakeTime.snapshot(),
+		RowsReturned:       atomic.LoadInt64(&s.rowsReturned),
+		BytesRead:          atomic.LoadInt64(&s.bytesRead),
+		BytesWritten:       atomic.LoadInt64(&s.bytesWritten),
+		CancelRequestsSent: atomic.LoadInt64(&s.cancelRequestsSent),
+		NoticesReceived:    atomic.LoadInt64(&s.noticesReceived),
+		NoticesSuppressed:  atomic.LoadInt64(&s.noticesSuppressed),
+	}
+	s.commandsMu.Lock()
+	snap.Commands = make(map[string]int64, len(s.commands))
+	for k, v := range s.commands {
+		snap.Commands[k] = v
+	}
+	s.commandsMu.Unlock()
+	return snap
+}
+
+// String returns the statistics encoded as JSON.
+func (s *Stats) String() string {
+	b, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// The methods below are no-ops on a nil *Stats so call sites need not check
+// whether statistics are enabled.
+
+func (s *Stats) connectionOpened(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		atomic.AddInt64(&s.connectionsFailed, 1)
+	} else {
+		atomic.AddInt64(&s.connectionsOpened, 1)
+	}
+}
+
+func (s *Stats) observeAuth(d time.Duration) {
+	if s != nil {
+		s.authTime.observe(d)
+	}
+}
+
+func (s *Stats) observeTLSHandshake(d time.Duration) {
+	if s != nil {
+		s.tlsHandshakeTime.observe(d)
+	}
+}
+
+func (s *Stats) commandCompleted(tag string) {
+	if s == nil || tag == "" {
+		return
+	}
+	if i := strings.IndexByte(tag, ' '); i > 0 {
+		tag = tag[:i]
+	}
+	s.commandsMu.Lock()
+	if s.commands == nil {
+		s.commands = make(map[string]int64)
+	}
+	s.commands[tag]++
+	s.commandsMu.Unlock()
+}
+
+func (s *Stats) rowReturned() {
+	if s != nil {
+		atomic.AddInt64(&s.rowsReturned, 1)
+	}
+}
+
+func (s *Stats) cancelRequestSent() {
+	if s != nil {
+		atomic.AddInt64(&s.cancelRequestsSent, 1)
+	}
+}
+
+func (s *Stats) noticeReceived(suppressed bool) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.noticesReceived, 1)
+	if suppressed {
+		atomic.AddInt64(&s.noticesSuppressed, 1)
+	}
+}
+
+// wrapConn returns c wrapped so that bytes read and written are counted. It
+// returns c unchanged if s is nil.
+func (s *Stats) wrapConn(c net.Conn) net.Conn {
+	if s == nil {
+		return c
+	}
+	return &statsConn{Conn: c, stats: s}
+}
+
+type statsConn struct {
+	net.Conn
+	stats *Stats
+}
+
+func (c *statsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.stats.bytesRead, int64(n))
+	return n, err
+}
+
+func (c *statsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.stats.bytesWritten, int64(n))
+	return n, err
+}
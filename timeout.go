@@ -0,0 +1,169 @@
+package pq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// timeoutConn bounds every read and write on a network connection by
+// Config.ReadTimeout and Config.WriteTimeout, on top of the deadlines set on
+// it, e.g. for the context of a statement, so that a hung server or a
+// connection dropped by the network fails instead of blocking forever.
+type timeoutConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu sync.Mutex
+	// set through SetDeadline, SetReadDeadline and SetWriteDeadline
+	readDeadline  time.Time
+	writeDeadline time.Time
+	// the deadlines of the read and write in progress, if any
+	readLimit  time.Time
+	writeLimit time.Time
+	// set while waiting for messages the server sends on its own
+	readIdle bool
+}
+
+// timeoutError is returned when a read or write exceeded ReadTimeout or
+// WriteTimeout.
+type timeoutError struct {
+	op string
+	d  time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("pq: %s timed out after %s (%s_timeout)", e.op, e.d, e.op)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return false }
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.readTimeout > 0 && !c.readIdle {
+		c.readLimit = time.Now().Add(c.readTimeout)
+	}
+	d := earliest(c.readDeadline, c.readLimit)
+	err := c.Conn.SetReadDeadline(d)
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && !c.readLimit.IsZero() && isTimeout(err) &&
+		(c.readDeadline.IsZero() || c.readLimit.Before(c.readDeadline)) {
+		err = &timeoutError{op: "read", d: c.readTimeout}
+	}
+	c.readLimit = time.Time{}
+	return n, err
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.writeTimeout > 0 {
+		c.writeLimit = time.Now().Add(c.writeTimeout)
+	}
+	d := earliest(c.writeDeadline, c.writeLimit)
+	err := c.Conn.SetWriteDeadline(d)
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && !c.writeLimit.IsZero() && isTimeout(err) &&
+		(c.writeDeadline.IsZero() || c.writeLimit.Before(c.writeDeadline)) {
+		err = &timeoutError{op: "write", d: c.writeTimeout}
+	}
+	c.writeLimit = time.Time{}
+	return n, err
+}
+
+func (c *timeoutConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	if err := c.Conn.SetReadDeadline(earliest(t, c.readLimit)); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(earliest(t, c.writeLimit))
+}
+
+func (c *timeoutConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(earliest(t, c.readLimit))
+}
+
+func (c *timeoutConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return c.Conn.SetWriteDeadline(earliest(t, c.writeLimit))
+}
+
+// wrapTimeouts wraps the network connection of cn, before TLS, so that
+// Config.ReadTimeout and Config.WriteTimeout apply to it.
+func (cn *conn) wrapTimeouts() {
+	if cn.config.ReadTimeout <= 0 && cn.config.WriteTimeout <= 0 {
+		return
+	}
+	cn.timeouts = &timeoutConn{
+		Conn:         cn.c,
+		readTimeout:  cn.config.ReadTimeout,
+		writeTimeout: cn.config.WriteTimeout,
+	}
+	cn.c = cn.timeouts
+}
+
+// disable stops applying the timeouts, e.g. once the connection is
+// hijacked.
+func (c *timeoutConn) disable() {
+	c.mu.Lock()
+	c.readTimeout, c.writeTimeout = 0, 0
+	c.mu.Unlock()
+}
+
+// setReadIdle lifts ReadTimeout from the reads that follow while idle is
+// set.
+func (c *timeoutConn) setReadIdle(idle bool) {
+	c.mu.Lock()
+	c.readIdle = idle
+	c.mu.Unlock()
+}
+
+// idleReads lifts Config.ReadTimeout from the reads of cn until the returned
+// function is called, for reads waiting for messages that the server sends
+// on its own, such as notifications or the WAL of a replication stream.
+func (cn *conn) idleReads() func() {
+	tc := cn.timeouts
+	if tc == nil {
+		return func() {}
+	}
+	tc.setReadIdle(true)
+	return func() { tc.setReadIdle(false) }
+}
+
+// earliest returns the earlier of two deadlines, the zero time meaning
+// none.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,96 @@
+package pq
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// discoveredHosts holds the standbys of a primary/standby cluster found by
+// querying the cluster, so that standbys added after the connection string
+// was written are used too.
+type discoveredHosts struct {
+	mu    sync.RWMutex
+	hosts []*FallbackConfig
+}
+
+// appendNew appends the discovered hosts missing from configs.
+func (d *discoveredHosts) appendNew(configs []*FallbackConfig) []*FallbackConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, h := range d.hosts {
+		known := false
+		for _, c := range configs {
+			if (c.Host == h.Host || c.HostAddr == h.Host) && c.Port == h.Port {
+				known = true
+				break
+			}
+		}
+		if !known {
+			configs = append(configs, h)
+		}
+	}
+	return configs
+}
+
+// refreshHosts queries the standbys streaming from the server db connects to
+// every interval. Standbys are assumed to accept connections on the port of
+// the first host of the config. The list is refreshed until ctx is done,
+// i.e. the Connector is closed, and db is closed then.
+func (d *discoveredHosts) refreshHosts(ctx context.Context, db *sql.DB, cfg *Config, interval time.Duration) {
+	cfg.Log(ctx, LogLevelInfo, "Start the goroutine of refreshing the host list",
+		map[string]interface{}{"interval": interval})
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := d.refresh(ctx, db, cfg); err != nil {
+			cfg.Log(ctx, LogLevelWarn, "Failed to refresh the host list",
+				map[string]interface{}{"error": err})
+		}
+		select {
+		case <-ctx.Done():
+			_ = db.Close()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *discoveredHosts) refresh(ctx context.Context, db *sql.DB, cfg *Config) error {
+	rows, err := db.QueryContext(ctx,
+		"SELECT DISTINCT host(client_addr) FROM pg_stat_replication WHERE client_addr IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var hosts []*FallbackConfig
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return err
+		}
+		var tlsConfig *tls.Config
+		if cfg.TLSConfig != nil {
+			tlsConfig = cfg.TLSConfig.Clone()
+			if tlsConfig.ServerName != "" {
+				tlsConfig.ServerName = addr
+			}
+		}
+		hosts = append(hosts, &FallbackConfig{Host: addr, Port: cfg.Port, TLSConfig: tlsConfig})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		// connected to a standby, or no standby is streaming right now
+		return nil
+	}
+	d.mu.Lock()
+	d.hosts = hosts
+	d.mu.Unlock()
+	cfg.Log(ctx, LogLevelDebug, "Refreshed the host list", map[string]interface{}{"standbys": len(hosts)})
+	return nil
+}
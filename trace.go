@@ -0,0 +1,202 @@
+package pq
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TraceOp identifies the driver operation covered by a trace span.
+type TraceOp int
+
+const (
+	TraceOpConnect TraceOp = iota
+	TraceOpQuery
+	TraceOpExec
+	TraceOpPrepare
+	TraceOpCopy
+	TraceOpBegin
+	TraceOpCommit
+	TraceOpRollback
+)
+
+func (op TraceOp) String() string {
+	switch op {
+	case TraceOpConnect:
+		return "connect"
+	case TraceOpQuery:
+		return "query"
+	case TraceOpExec:
+		return "exec"
+	case TraceOpPrepare:
+		return "prepare"
+	case TraceOpCopy:
+		return "copy"
+	case TraceOpBegin:
+		return "begin"
+	case TraceOpCommit:
+		return "commit"
+	case TraceOpRollback:
+		return "rollback"
+	default:
+		return fmt.Sprintf("invalid op %d", int(op))
+	}
+}
+
+// TraceStartData is passed to Tracer.TraceStart when an operation begins.
+type TraceStartData struct {
+	Op        TraceOp
+	SQL       string // empty for connect, begin, commit and rollback
+	ArgsCount int
+	Host      string
+	Port      uint16
+	Database  string
+	User      string
+
+	// Fingerprint is QueryFingerprint(SQL), a label for per-statement
+	// metrics, or empty if SQL is.
+	Fingerprint string
+}
+
+// TraceEndData is passed to Tracer.TraceEnd when an operation completes.
+type TraceEndData struct {
+	Op         TraceOp
+	CommandTag string // command tag reported by the server, e.g. "INSERT" or "SELECT"
+	Duration   time.Duration
+	Err        error
+}
+
+// Tracer receives span start and end events around the operations performed
+// by the driver. TraceStart may return a derived context (e.g. one carrying a
+// span) which is passed back to the matching TraceEnd call.
+//
+// Tracer methods are called synchronously on the goroutine performing the
+// operation, so implementations should return quickly.
+type Tracer interface {
+	TraceStart(ctx context.Context, data TraceStartData) context.Context
+	TraceEnd(ctx context.Context, data TraceEndData)
+}
+
+// traceSpan tracks a single in-flight operation. A nil *traceSpan is valid
+// and all of its methods are no-ops, so callers need not check whether
+// tracing is enabled.
+type traceSpan struct {
+	config *Config
+	cn     *conn // nil for connect spans
+	ctx    context.Context
+	op     TraceOp
+	query  string
+	start  time.Time
+	ended  bool
+}
+
+func startSpan(ctx context.Context, config *Config, host string, port uint16, op TraceOp, query string, nargs int) *traceSpan {
+	if config == nil || (config.Tracer == nil && config.SlowQueryThreshold <= 0) {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if config.Tracer != nil {
+		data := TraceStartData{
+			Op:        op,
+			SQL:       query,
+			ArgsCount: nargs,
+			Host:      host,
+			Port:      port,
+			Database:  config.Database,
+			User:      config.User,
+		}
+		if query != "" {
+			data.Fingerprint = QueryFingerprint(query)
+		}
+		ctx = config.Tracer.TraceStart(ctx, data)
+	}
+	return &traceSpan{config: config, ctx: ctx, op: op, query: query, start: time.Now()}
+}
+
+// traceStart starts a span for an operation on an established connection.
+func (cn *conn) traceStart(ctx context.Context, op TraceOp, query string, nargs int) *traceSpan {
+	var host string
+	var port uint16
+	if cn.fallbackConfig != nil {
+		host, port = cn.fallbackConfig.Host, cn.fallbackConfig.Port
+	}
+	s := startSpan(ctx, cn.config, host, port, op, query, nargs)
+	if s != nil {
+		s.cn = cn
+		cn.lastCommandTag = ""
+	}
+	return s
+}
+
+// end finishes the span. If tag is empty, the last command tag seen on the
+// connection is reported instead.
+func (s *traceSpan) end(tag string, err error) {
+	if s == nil || s.ended {
+		return
+	}
+	s.ended = true
+	if tag == "" && s.cn != nil {
+		tag = s.cn.lastCommandTag
+	}
+	duration := time.Since(s.start)
+	if s.config.Tracer != nil {
+		s.config.Tracer.TraceEnd(s.ctx, TraceEndData{
+			Op:         s.op,
+			CommandTag: tag,
+			Duration:   duration,
+			Err:        err,
+		})
+	}
+	if t := s.config.SlowQueryThreshold; t > 0 && duration > t && (s.op == TraceOpQuery || s.op == TraceOpExec) {
+		s.reportSlowQuery(tag, duration, err)
+	}
+}
+
+// maxSlowQuerySQLLen is the length at which statements are truncated in slow
+// query reports.
+const maxSlowQuerySQLLen = 1024
+
+// SlowQuery describes a query or exec that exceeded Config.SlowQueryThreshold.
+type SlowQuery struct {
+	SQL        string // truncated to 1024 bytes
+	Duration   time.Duration
+	CommandTag string
+	PID        int // backend process ID
+	Err        error
+
+	// Fingerprint is QueryFingerprint of the whole statement, to group the
+	// reports of a statement run with different values.
+	Fingerprint string
+}
+
+func (s *traceSpan) reportSlowQuery(tag string, duration time.Duration, err error) {
+	q := SlowQuery{
+		SQL:         s.query,
+		Duration:    duration,
+		Fingerprint: QueryFingerprint(s.query),
+		CommandTag:  tag,
+		PID:         s.cn.processID,
+		Err:         err,
+	}
+	if len(q.SQL) > maxSlowQuerySQLLen {
+		q.SQL = q.SQL[:maxSlowQuerySQLLen] + "..."
+	}
+	if s.config.OnSlowQuery != nil {
+		s.config.OnSlowQuery(s.ctx, q)
+		return
+	}
+	data := map[string]interface{}{
+		"sql":         q.SQL,
+		"fingerprint": q.Fingerprint,
+		"duration":    q.Duration,
+	}
+	if q.CommandTag != "" {
+		data["commandTag"] = q.CommandTag
+	}
+	if q.Err != nil {
+		data["err"] = q.Err
+	}
+	s.cn.log(s.ctx, LogLevelWarn, "slow query", data)
+}
@@ -0,0 +1,106 @@
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TwoPhaseCommitter is implemented by the driver connection. Reach it through
+// database/sql's Conn.Raw while a transaction started on that Conn is open.
+type TwoPhaseCommitter interface {
+	// PrepareTransaction prepares the current transaction for two-phase
+	// commit under the global identifier gid, dissociating it from the
+	// session. The database/sql transaction must still be finished with
+	// Commit or Rollback, which do nothing further once the transaction has
+	// been prepared; use CommitPrepared or RollbackPrepared to complete it.
+	// If the transaction could not be prepared and was rolled back instead,
+	// Commit returns the error again.
+	//
+	// The server's max_prepared_transactions setting must be non-zero.
+	PrepareTransaction(gid string) error
+}
+
+var _ TwoPhaseCommitter = (*conn)(nil)
+
+func (cn *conn) PrepareTransaction(gid string) error {
+	cn.LockReaderMutex()
+	defer cn.UnlockReaderMutex()
+	if cn.getBad() {
+		return cn.errBadConn()
+	}
+	if !cn.isInTransaction() {
+		return errors.New("pq: PREPARE TRANSACTION is only allowed inside a transaction")
+	}
+	if cn.txnStatus == txnStatusInFailedTransaction {
+		if err := cn.rollback(); err != nil {
+			return err
+		}
+		cn.txnEndedErr = ErrInFailedTransaction
+		return ErrInFailedTransaction
+	}
+	_, commandTag, err := cn.simpleExec("PREPARE TRANSACTION " + QuoteLiteral(gid))
+	if err != nil {
+		err = fmt.Errorf("fail to simple exec: %w", err)
+		if cn.isInTransaction() {
+			cn.setBad()
+		} else {
+			// e.g. max_prepared_transactions is 0: the server rolled the
+			// transaction back
+			cn.txnEndedErr = err
+		}
+		return err
+	}
+	if commandTag != "PREPARE TRANSACTION" {
+		err := fmt.Errorf("unexpected command tag %s", commandTag)
+		if !cn.isInTransaction() {
+			// the server turns PREPARE TRANSACTION into a ROLLBACK if it
+			// fails, so there is nothing left for Commit to commit
+			cn.txnEndedErr = err
+		}
+		return err
+	}
+	cn.txnPrepared = true
+	return cn.checkIsInTransaction(false)
+}
+
+// Execer is implemented by *sql.DB and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// CommitPrepared commits the transaction prepared under gid. It may be run on
+// any connection to the same database, but not inside a transaction.
+func CommitPrepared(ctx context.Context, db Execer, gid string) error {
+	_, err := db.ExecContext(ctx, "COMMIT PREPARED "+QuoteLiteral(gid))
+	return err
+}
+
+// RollbackPrepared rolls back the transaction prepared under gid. It may be
+// run on any connection to the same database, but not inside a transaction.
+func RollbackPrepared(ctx context.Context, db Execer, gid string) error {
+	_, err := db.ExecContext(ctx, "ROLLBACK PREPARED "+QuoteLiteral(gid))
+	return err
+}
+
+// PreparedTransactions returns the global identifiers of the transactions
+// currently prepared in the database db is connected to, which a coordinator
+// needs in order to recover after a crash.
+func PreparedTransactions(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT gid FROM pg_prepared_xacts WHERE database = current_database()")
+	if err != nil {
+		return nil, fmt.Errorf("fail to query: %w", err)
+	}
+	defer rows.Close()
+
+	var gids []string
+	for rows.Next() {
+		var gid string
+		if err = rows.Scan(&gid); err != nil {
+			return nil, fmt.Errorf("cannot scan: %w", err)
+		}
+		gids = append(gids, gid)
+	}
+	return gids, rows.Err()
+}
@@ -0,0 +1,65 @@
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+// TestPrepareTransactionDisabled checks that when PREPARE TRANSACTION fails
+// because max_prepared_transactions is 0, Commit returns the error again and
+// the connection stays usable.
+func TestPrepareTransactionDisabled(t *testing.T) {
+	var dials int32
+	db := openFakeDB(t, "", &dials, func(s *fakeServer) {
+		for {
+			q, err := s.query('I')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(q, "BEGIN"):
+				s.complete("BEGIN")
+				s.ready('T')
+			case strings.HasPrefix(q, "PREPARE TRANSACTION"):
+				// the transaction is rolled back
+				s.error("55000", "prepared transactions are disabled")
+				s.ready('I')
+			default:
+				s.complete("SELECT 1")
+				s.ready('I')
+			}
+			if err := s.flush(); err != nil {
+				return
+			}
+		}
+	})
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Raw(func(dc interface{}) error {
+		return dc.(driver.Conn).(TwoPhaseCommitter).PrepareTransaction("gid")
+	})
+	if err == nil || !strings.Contains(err.Error(), "prepared transactions are disabled") {
+		t.Fatalf("PrepareTransaction: got %v, want the server error", err)
+	}
+	if err := tx.Commit(); err == nil || !strings.Contains(err.Error(), "prepared transactions are disabled") {
+		t.Fatalf("Commit: got %v, want the PrepareTransaction error", err)
+	}
+	if _, err := c.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if dials != 1 {
+		t.Errorf("%d connections opened, want 1", dials)
+	}
+}
@@ -0,0 +1,254 @@
+package pq
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SetWireTrace starts writing every protocol message exchanged on the given
+// connection to w, one line per message, similarly to libpq's PQtrace. A nil
+// w turns tracing off. A runtime panic occurs if c is not a pq connection.
+//
+// Each line holds a timestamp, the direction (F for frontend, B for backend),
+// the message length, the message name and a short decoded summary. Password
+// messages, and the statements of Conn.SetRole and
+// Conn.SetSessionAuthorization carrying a password, are never written.
+//
+// To trace connection startup as well, set Config.WireTrace instead.
+func SetWireTrace(c driver.Conn, w io.Writer) {
+	c.(*conn).wireTrace = newWireTracer(w)
+}
+
+type wireTracer struct {
+	w io.Writer
+}
+
+// wireTraceMu serializes trace output. Connections created from the same
+// Config share a writer, and COPY responses are read on a separate goroutine
+// from the one sending data.
+var wireTraceMu sync.Mutex
+
+func newWireTracer(w io.Writer) *wireTracer {
+	if w == nil {
+		return nil
+	}
+	return &wireTracer{w: w}
+}
+
+var frontendMessageNames = map[byte]string{
+	'B': "Bind",
+	'C': "Close",
+	'c': "CopyDone",
+	'd': "CopyData",
+	'D': "Describe",
+	'E': "Execute",
+	'f': "CopyFail",
+	'F': "FunctionCall",
+	'H': "Flush",
+	'P': "Parse",
+	'p': "PasswordMessage",
+	'Q': "Query",
+	'S': "Sync",
+	'U': "BatchBind",
+	'X': "Terminate",
+}
+
+var backendMessageNames = map[byte]string{
+	'1': "ParseComplete",
+	'2': "BindComplete",
+	'3': "CloseComplete",
+	'A': "NotificationResponse",
+	'c': "CopyDone",
+	'C': "CommandComplete",
+	'd': "CopyData",
+	'D': "DataRow",
+	'E': "ErrorResponse",
+	'G': "CopyInResponse",
+	'H': "CopyOutResponse",
+	'I': "EmptyQueryResponse",
+	'K': "BackendKeyData",
+	'n': "NoData",
+	'N': "NoticeResponse",
+	'R': "Authentication",
+	's': "PortalSuspended",
+	'S': "ParameterStatus",
+	't': "ParameterDescription",
+	'T': "RowDescription",
+	'W': "CopyBothResponse",
+	'Z': "ReadyForQuery",
+}
+
+// traceFrontend traces a message sent to the server. msg is the complete
+// message including the type byte and length.
+func (cn *conn) traceFrontend(msg []byte) {
+	if cn.wireTrace == nil || len(msg) < 5 {
+		return
+	}
+	t := msg[0]
+	name, ok := frontendMessageNames[t]
+	if !ok {
+		name = fmt.Sprintf("Unknown(%q)", t)
+	}
+	summary := frontendSummary(t, msg[5:])
+	if t == 'Q' && cn.redactQuery {
+		summary = "<redacted>"
+	}
+	cn.wireTrace.write('F', len(msg)-1, name, summary)
+}
+
+// traceUntyped traces a message without a type byte, such as StartupMessage
+// or SSLRequest.
+func (cn *conn) traceUntyped(name string, length int, summary string) {
+	if cn.wireTrace == nil {
+		return
+	}
+	cn.wireTrace.write('F', length, name, summary)
+}
+
+// traceBackend traces a message received from the server.
+func (cn *conn) traceBackend(t byte, body []byte) {
+	if cn.wireTrace == nil {
+		return
+	}
+	name, ok := backendMessageNames[t]
+	if !ok {
+		name = fmt.Sprintf("Unknown(%q)", t)
+	}
+	cn.wireTrace.write('B', len(body)+4, name, backendSummary(t, body))
+}
+
+func (wt *wireTracer) write(dir byte, length int, name, summary string) {
+	var b strings.Builder
+	b.WriteString(time.Now().Format("2006-01-02 15:04:05.000000"))
+	b.WriteByte('\t')
+	b.WriteByte(dir)
+	b.WriteByte('\t')
+	b.WriteString(strconv.Itoa(length))
+	b.WriteByte('\t')
+	b.WriteString(name)
+	if summary != "" {
+		b.WriteByte('\t')
+		b.WriteString(summary)
+	}
+	b.WriteByte('\n')
+
+	wireTraceMu.Lock()
+	_, _ = io.WriteString(wt.w, b.String())
+	wireTraceMu.Unlock()
+}
+
+// maxTraceStringLen bounds the length of strings included in summaries.
+const maxTraceStringLen = 256
+
+func traceString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	if len(b) > maxTraceStringLen {
+		return fmt.Sprintf("%q...", b[:maxTraceStringLen])
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+// cstrings splits a body into its leading NUL-terminated strings.
+func cstrings(b []byte, n int) []string {
+	var out []string
+	for len(out) < n {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			break
+		}
+		out = append(out, traceString(b[:i]))
+		b = b[i+1:]
+	}
+	return out
+}
+
+func frontendSummary(t byte, body []byte) (s string) {
+	// Malformed messages are the server's problem to report; never let
+	// tracing take the connection down.
+	defer func() {
+		if recover() != nil {
+			s = "<malformed>"
+		}
+	}()
+	switch t {
+	case 'Q':
+		return traceString(body)
+	case 'P':
+		return strings.Join(cstrings(body, 2), " ")
+	case 'B', 'U':
+		return "portal=" + strings.Join(cstrings(body, 2), " statement=")
+	case 'E':
+		r := readBuf(body)
+		portal, err := r.string()
+		if err != nil {
+			return "<malformed>"
+		}
+		return fmt.Sprintf("portal=%s maxRows=%d", traceString([]byte(portal)), r.int32())
+	case 'D', 'C':
+		if len(body) > 0 {
+			return fmt.Sprintf("%c %s", body[0], traceString(body[1:]))
+		}
+	case 'p':
+		return "<redacted>"
+	case 'd':
+		return fmt.Sprintf("%d bytes", len(body))
+	case 'f':
+		return traceString(body)
+	}
+	return ""
+}
+
+func backendSummary(t byte, body []byte) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = "<malformed>"
+		}
+	}()
+	r := readBuf(body)
+	switch t {
+	case 'R':
+		return fmt.Sprintf("code=%d", r.int32())
+	case 'K':
+		return fmt.Sprintf("pid=%d", r.int32())
+	case 'S':
+		return strings.Join(cstrings(body, 2), "=")
+	case 'C':
+		return traceString(body)
+	case 'Z':
+		return string(body[:1])
+	case 'T', 'D', 't':
+		return fmt.Sprintf("%d columns", r.int16())
+	case 'E', 'N':
+		var severity, code, msg string
+		for len(r) > 0 && r[0] != 0 {
+			f := r.byte()
+			v, err := r.string()
+			if err != nil {
+				break
+			}
+			switch f {
+			case 'S':
+				severity = v
+			case 'C':
+				code = v
+			case 'M':
+				msg = v
+			}
+		}
+		return fmt.Sprintf("%s %s %s", severity, code, traceString([]byte(msg)))
+	case 'A':
+		pid := r.int32()
+		return fmt.Sprintf("pid=%d %s", pid, strings.Join(cstrings(r, 2), " "))
+	case 'd':
+		return fmt.Sprintf("%d bytes", len(body))
+	}
+	return ""
+}